@@ -21,15 +21,39 @@ Step 1:#### <step 1 reasoning>
 Make sure to include #### to separate every step.`
 
 type Step struct {
-	Task         string
-	Format       string
-	OutputFormat string
+	Task  string
+	Field string
 }
 
 type ChainOfThought struct {
 	Steps []Step
 }
 
+// FileRelevance is the relevance of one file in a FindAnswer.
+type FileRelevance struct {
+	Filepath   string `json:"filepath"`
+	Relevance  string `json:"relevance" description:"how the file is relevant"`
+	Evaluation string `json:"evaluation" description:"evaluation of how it answers the query"`
+}
+
+// FileScore is the score of one file in a FindAnswer.
+type FileScore struct {
+	Filepath string `json:"filepath"`
+	Reason   string `json:"reason"`
+	Score    int    `json:"score" description:"0-100 based on relevance, higher is better"`
+}
+
+// FindAnswer is the JSON answer to FindChainOfThoughtPrompt. Each field holds the answer of one step.
+type FindAnswer struct {
+	Query       string          `json:"query"`
+	Reasoning   string          `json:"reasoning"`
+	Relevance   []FileRelevance `json:"relevance"`
+	Scores      []FileScore     `json:"scores"`
+	Critique    string          `json:"critique"`
+	Corrections []FileScore     `json:"corrections"`
+	Files       []string        `json:"files" description:"relevant filepaths ordered by score"`
+}
+
 func FindChainOfThoughtPrompt() string {
 	tmpl := template.Must(template.New("findchain").Funcs(template.FuncMap{"inc": func(i int) int {
 		return i + 1
@@ -38,46 +62,35 @@ The user will write a prompt and your task is to find the most relevant file the
 The user prompt is not directly related to the content found.
 You will answer briefly but each detail will be discussed. 
 
-Make sure to include #### to separate every step. Do not mention the step descriptions or formats.
-{{range $i, $step := .Steps}}
-Step {{inc $i}}:#### {{$step.Task}}. Format: {{$step.Format}}
-{{end}}
-
-Use the following output format:
+Answer with one JSON object. Put the answer of each step in its field. Do not mention the step descriptions.
 {{range $i, $step := .Steps}}
-Step {{inc $i}}:####<step {{$step.OutputFormat}}>
+Step {{inc $i}}: {{$step.Task}}. Field: {{$step.Field}}
 {{end}}`))
 
 	steps := []Step{
 		{
-			Task:         "What the user is asking for",
-			Format:       `{"query":"<query>"}`,
-			OutputFormat: "query json",
+			Task:  "What the user is asking for",
+			Field: "query",
 		},
 		{
-			Task:         "Walk through the results and reason how the found results might be relevant",
-			Format:       `{"reasoning":"<reasoning hypothesis>"}`,
-			OutputFormat: "reasoning json",
+			Task:  "Walk through the results and reason how the found results might be relevant",
+			Field: "reasoning",
 		},
 		{
-			Task:         "Walk through each file that exists and explain each of their relevance. Then evaluate the file relevance based on the query",
-			Format:       `[{"filepath":"<filepath>","relevance":"<how the file is relevance>","evaluation":"<evalutation of how it answers query>"},...]`,
-			OutputFormat: "relevance and evaluation json",
+			Task:  "Walk through each file that exists and explain each of their relevance. Then evaluate the file relevance based on the query",
+			Field: "relevance",
 		},
 		{
-			Task:         "Write a reasoning followed by a score 0-100 based on relevance, higher is better",
-			Format:       `[{"filepath":"<filepath>","reason":"<reason>", "score":"<score>"},...]`,
-			OutputFormat: "scores json",
+			Task:  "Write a reasoning followed by a score 0-100 based on relevance, higher is better",
+			Field: "scores",
 		},
 		{
-			Task:         "Criticize your answer so far",
-			Format:       `{"critique":"<critique>","correction":[{"filepath":"<filepath>","reason":"<reason>", "score":"<score>"},...]}`,
-			OutputFormat: "critique json",
+			Task:  "Criticize your answer so far and correct the scores",
+			Field: "critique and corrections",
 		},
 		{
-			Task:         "Order files based on score and only include relevant files",
-			Format:       `["<filepath>",...]`,
-			OutputFormat: "file order json",
+			Task:  "Order files based on score and only include relevant files",
+			Field: "files",
 		},
 	}
 	msg := ChainOfThought{Steps: steps}
@@ -1,6 +1,8 @@
 package action
 
 import (
+	"io"
+
 	"github.com/tzapio/tzap/cli/actionpb"
 	"github.com/tzapio/tzap/cli/cmd/cliworkflows"
 	"github.com/tzapio/tzap/cli/cmd/cmdutil"
@@ -20,6 +22,8 @@ type PromptWorkflowArgs struct {
 	DisableIndex     bool
 	Yes              bool
 	MessageThread    []types.Message
+	// Output receives the completion as it streams. Nil discards it.
+	Output io.Writer
 }
 
 // PromptWorkflow defines a workflow for generating code based on code-searching existing files and user input.
@@ -57,7 +61,7 @@ func PromptWorkflow(promptWorkflowArgs PromptWorkflowArgs) types.NamedWorkflow[*
 				// Find all embeddings search them, returning the top results.
 				ApplyWorkflow(LoadAndSearchEmbeddingsWorkflow(loadAndSearchEmbeddingsArgs)).
 				MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
-					searchResult := embedstore.TightenSearchResults(t.Data["searchResults"].(types.SearchResults).Results)
+					searchResult := embedstore.TightenSearchResults(embedworkflows.SearchResultsKey.Must(t).Results)
 					return t.
 						ApplyWorkflow(cliworkflows.PrintEmbeddings(searchResult)).
 						ApplyWorkflow(embedworkflows.EmbedWorkflow(searchResult))
@@ -67,8 +71,15 @@ func PromptWorkflow(promptWorkflowArgs PromptWorkflowArgs) types.NamedWorkflow[*
 				// Get Completion
 				MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
 					println(cmdutil.Bold("--- Completion"))
-					t = t.RequestChatCompletion()
+					t = t.RequestChatCompletionStream(func(delta string) error {
+						if promptWorkflowArgs.Output == nil {
+							return nil
+						}
+						_, err := io.WriteString(promptWorkflowArgs.Output, delta)
+						return err
+					})
 					println(cmdutil.Bold("\n---"))
+					cmdutil.PrintContextReport(t)
 					return t
 				})
 		},
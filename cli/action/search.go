@@ -4,6 +4,7 @@ import (
 	"github.com/tzapio/tzap/cli/actionpb"
 	"github.com/tzapio/tzap/cli/cmd/cliworkflows"
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/embed"
 	"github.com/tzapio/tzap/pkg/embed/embedstore"
 	"github.com/tzapio/tzap/pkg/types"
@@ -30,8 +31,8 @@ type LoadAndSearchEmbeddingsOutput struct {
 func LoadAndSearchEmbeddings(t *tzap.Tzap, args *actionpb.SearchArgs) *LoadAndSearchEmbeddingsOutput {
 	resultT := t.
 		ApplyWorkflow(LoadAndSearchEmbeddingsWorkflow(args))
-	searchResult := resultT.Data["searchResults"].(types.SearchResults)
-	queryResult := resultT.Data["queryResult"].(types.QueryRequest)
+	searchResult := embedworkflows.SearchResultsKey.Must(resultT)
+	queryResult := embedworkflows.QueryResultKey.Must(resultT)
 	return &LoadAndSearchEmbeddingsOutput{
 		SearchResults: searchResult,
 		QueryResult:   queryResult,
@@ -42,19 +43,48 @@ func LoadAndSearchEmbeddingsWorkflow(args *actionpb.SearchArgs) types.NamedWorkf
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "loadAndSearchEmbeddings",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
-			queryWait := singlewait.New(func() types.QueryRequest {
-				tl.Logger.Println("loadAndSearchEmbeddings: Getting query")
-				query, err := embed.NewQuery(t, args.SearchQuery)
-				if err != nil {
-					panic(err)
+			backend := embedworkflows.ResolveSearchBackend(t)
+			queryText, filter := embed.ParseQuery(args.SearchQuery)
+			filter.Exclude.Paths = append(filter.Exclude.Paths, args.ExcludeFiles...)
+			k, n := int(args.EmbedsCount), int(args.NCount)
+			rerank := config.FromContext(t.C).Rerank
+			if rerank && n > k && k > -1 {
+				// The search keeps all n candidates for the model to pick the k best from.
+				k = n
+			}
+			var searched *tzap.Tzap
+			if backend == config.SearchBackendBM25 {
+				searched = t.
+					ApplyWorkflow(cliworkflows.IndexFilesLexical(args.DisableIndex)).
+					ApplyWorkflow(embedworkflows.SearchLexicalWorkflow(queryText, filter, k, n))
+			} else {
+				queryWait := singlewait.New(func() types.QueryRequest {
+					tl.Logger.Println("loadAndSearchEmbeddings: Getting query")
+					query, err := embed.NewQuery(t, args.SearchQuery)
+					if err != nil {
+						panic(err)
+					}
+					for i := range query.Queries {
+						query.Queries[i].Filter = filter
+					}
+					tl.Logger.Println("loadAndSearchEmbeddings: Query received")
+					return query
+				})
+				if backend == config.SearchBackendHybrid {
+					searched = t.
+						ApplyWorkflow(cliworkflows.IndexFilesAndEmbeddings(args.DisableIndex, args.Yes)).
+						ApplyWorkflow(cliworkflows.IndexFilesLexical(args.DisableIndex)).
+						ApplyWorkflow(embedworkflows.HybridSearchWorkflow(queryWait.GetData(), queryText, k, n))
+				} else {
+					searched = t.
+						ApplyWorkflow(cliworkflows.IndexFilesAndEmbeddings(args.DisableIndex, args.Yes)).
+						ApplyWorkflow(embedworkflows.SearchFilesWorkflow(queryWait.GetData(), k, n))
 				}
-				tl.Logger.Println("loadAndSearchEmbeddings: Query received")
-				return query
-			})
-
-			return t.
-				ApplyWorkflow(cliworkflows.IndexFilesAndEmbeddings(args.DisableIndex, args.Yes)).
-				ApplyWorkflow(embedworkflows.SearchFilesWorkflow(queryWait.GetData(), args.ExcludeFiles, int(args.EmbedsCount), int(args.NCount)))
+			}
+			if rerank {
+				return searched.ApplyWorkflow(embedworkflows.RerankWorkflow(queryText, int(args.EmbedsCount)))
+			}
+			return searched
 		},
 	}
 }
@@ -83,7 +113,7 @@ func SearchWorkflow(promptWorkflowArgs PromptWorkflowArgs) types.NamedWorkflow[*
 				// Find all embeddings search them, returning the top results.
 				ApplyWorkflow(LoadAndSearchEmbeddingsWorkflow(loadAndSearchEmbeddingsArgs)).
 				MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
-					searchResult := embedstore.TightenSearchResults(t.Data["searchResults"].(types.SearchResults).Results)
+					searchResult := embedstore.TightenSearchResults(embedworkflows.SearchResultsKey.Must(t).Results)
 					return t.
 						ApplyWorkflow(cliworkflows.PrintEmbeddings(searchResult)).
 						ApplyWorkflow(embedworkflows.EmbedWorkflow(searchResult))
@@ -72,6 +72,31 @@ func IndexFilesAndEmbeddings(disableIndex, yes bool) types.NamedWorkflow[*tzap.T
 		},
 	}
 }
+
+// IndexFilesLexical updates the BM25 index of the project, without model calls.
+func IndexFilesLexical(disableIndex bool) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "indexFilesLexical",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			projectP := project.GetProjectFromContext(t.C)
+			projectP.GetLexicalIndex().StartInit()
+			if disableIndex || !projectP.CanIndex() {
+				return t
+			}
+
+			lexicalTimestamps := projectP.GetLexicalTimestampCache()
+			lexicalTimestamps.StartInit()
+			files, err := projectP.GetFiles()
+			if err != nil {
+				panic(err)
+			}
+
+			embedder := embed.NewEmbedder(nil, lexicalTimestamps)
+			println("Checking for file changes. " + cmdutil.Black("(use -d to disable this check)...\n"))
+			return t.ApplyWorkflow(embedworkflows.LoadLexicalIndex(files, embedder))
+		},
+	}
+}
 func PrintInspirationFiles(inspirationFiles []string) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "listInspirationFiles",
@@ -107,7 +132,11 @@ func PrintEmbeddings(searchResults types.SearchResults) types.NamedWorkflow[*tza
 					if err != nil {
 						panic(err)
 					}
-					fmt.Fprintf(os.Stderr, "\t"+cmdutil.Black("t:%d")+"\t%s\n", tokens, cmdutil.Cyan(cmdutil.FormatVectorToClickable(result.Vector)))
+					score := ""
+					if result.RerankScore != nil {
+						score = cmdutil.Black(fmt.Sprintf("\ts:%d", *result.RerankScore))
+					}
+					fmt.Fprintf(os.Stderr, "\t"+cmdutil.Black("t:%d")+"%s\t%s\n", tokens, score, cmdutil.Cyan(cmdutil.FormatVectorToClickable(result.Vector)))
 				}
 				println()
 			})
@@ -125,7 +154,7 @@ func PrintFileDiff(compareToFile string) types.NamedWorkflow[*tzap.Tzap, *tzap.T
 					oldContent = util.ReadFileP(compareToFile)
 				}
 				dmp := diffmatchpatch.New()
-				diffs := dmp.DiffPrettyText(dmp.DiffMain(oldContent, t.Data["content"].(string), false))
+				diffs := dmp.DiffPrettyText(dmp.DiffMain(oldContent, tzap.ContentKey.Must(t), false))
 				println(diffs)
 			})
 		},
@@ -15,6 +15,9 @@ type LibProject struct {
 	projectDir          string
 	baseDir             string
 	embeddingCollection types.DBCollectionInterface[types.Vector]
+	indexHeader         types.DBCollectionInterface[types.IndexHeader]
+	vectorIndex         types.VectorIndex
+	lexicalIndex        types.DBCollectionInterface[types.LexicalDocument]
 }
 
 func NewLocalLibProject(baseDir string, name project.ProjectName) (project.Project, error) {
@@ -26,11 +29,22 @@ func NewLocalLibProject(baseDir string, name project.ProjectName) (project.Proje
 	if err != nil {
 		return nil, err
 	}
+	indexHeader, err := NewIndexHeader(project.ProjectDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
+	lexicalIndex, err := NewLexicalIndex(project.ProjectDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
 	localProject := &LibProject{
 		projectName:         name,
 		baseDir:             baseDir,
 		projectDir:          projectDir,
 		embeddingCollection: embeddingCollection,
+		indexHeader:         indexHeader,
+		vectorIndex:         NewVectorIndex(project.ProjectDir(projectDir), embeddingCollection),
+		lexicalIndex:        lexicalIndex,
 	}
 	return localProject, nil
 }
@@ -44,7 +58,7 @@ func (*LibProject) GetEmbeddingsCache() types.DBCollectionInterface[string] {
 }
 
 // GetTimestampCache implements project.Project
-func (*LibProject) GetTimestampCache() types.DBCollectionInterface[int64] {
+func (*LibProject) GetTimestampCache() types.DBCollectionInterface[types.FileStamp] {
 	panic("Local LibProject does not implement GetTimestampCache() - Do not index libproject")
 }
 
@@ -52,6 +66,26 @@ func (l *LibProject) GetEmbeddingCollection() types.DBCollectionInterface[types.
 	return l.embeddingCollection
 }
 
+// GetIndexHeader implements project.Project
+func (l *LibProject) GetIndexHeader() types.DBCollectionInterface[types.IndexHeader] {
+	return l.indexHeader
+}
+
+// GetVectorIndex implements project.Project
+func (l *LibProject) GetVectorIndex() types.VectorIndex {
+	return l.vectorIndex
+}
+
+// GetLexicalIndex implements project.Project
+func (l *LibProject) GetLexicalIndex() types.DBCollectionInterface[types.LexicalDocument] {
+	return l.lexicalIndex
+}
+
+// GetLexicalTimestampCache implements project.Project
+func (*LibProject) GetLexicalTimestampCache() types.DBCollectionInterface[types.FileStamp] {
+	panic("Local LibProject does not implement GetLexicalTimestampCache() - Do not index libproject")
+}
+
 // GetFiles implements project.Project
 func (*LibProject) GetFiles() ([]types.FileReader, error) {
 	panic("Local LibProject does not implement GetFiles() - Do not index libproject")
@@ -6,6 +6,7 @@ import (
 	"github.com/tzapio/tzap/cli/cmd/cmdinstance/localwalker"
 	"github.com/tzapio/tzap/cli/cmd/cmdutil/fileevaluator"
 
+	"github.com/tzapio/tzap/pkg/embed/hnsw"
 	"github.com/tzapio/tzap/pkg/embed/localdb"
 	"github.com/tzapio/tzap/pkg/project"
 	"github.com/tzapio/tzap/pkg/types"
@@ -16,13 +17,17 @@ type LocalProject struct {
 	projectDir               string
 	baseDir                  string
 	embeddingCollection      types.DBCollectionInterface[types.Vector]
-	filestampsDB             types.DBCollectionInterface[int64]
+	indexHeader              types.DBCollectionInterface[types.IndexHeader]
+	vectorIndex              types.VectorIndex
+	lexicalIndex             types.DBCollectionInterface[types.LexicalDocument]
+	lexicalTimestamps        types.DBCollectionInterface[types.FileStamp]
+	filestampsDB             types.DBCollectionInterface[types.FileStamp]
 	embeddingCacheDB         types.DBCollectionInterface[string]
 	*localwalker.LocalWalker //GetFiles() @TODO: Refactor to FS interface?
 }
 
-func NewFilestampCache(projectDir project.ProjectDir) (types.DBCollectionInterface[int64], error) {
-	return localdb.NewFileDB[int64](path.Join(string(projectDir), "filesTimestamps.db"))
+func NewFilestampCache(projectDir project.ProjectDir) (types.DBCollectionInterface[types.FileStamp], error) {
+	return localdb.NewFileDB[types.FileStamp](path.Join(string(projectDir), "filestamps.db"))
 }
 func NewEmbeddingsCache(projectDir project.ProjectDir) (types.DBCollectionInterface[string], error) {
 	return localdb.NewFileDB[string](path.Join(string(projectDir), "embeddingsCache.db"))
@@ -30,6 +35,18 @@ func NewEmbeddingsCache(projectDir project.ProjectDir) (types.DBCollectionInterf
 func NewEmbeddingsCollection(projectDir project.ProjectDir) (types.DBCollectionInterface[types.Vector], error) {
 	return localdb.NewFileDB[types.Vector](path.Join(string(projectDir), "fileembeddings.db"))
 }
+func NewIndexHeader(projectDir project.ProjectDir) (types.DBCollectionInterface[types.IndexHeader], error) {
+	return localdb.NewFileDB[types.IndexHeader](path.Join(string(projectDir), "fileembeddings.header.db"))
+}
+func NewVectorIndex(projectDir project.ProjectDir, embeddingCollection types.DBCollectionInterface[types.Vector]) types.VectorIndex {
+	return hnsw.NewStore(path.Join(string(projectDir), "fileembeddings.hnsw"), embeddingCollection, hnsw.Options{})
+}
+func NewLexicalIndex(projectDir project.ProjectDir) (types.DBCollectionInterface[types.LexicalDocument], error) {
+	return localdb.NewFileDB[types.LexicalDocument](path.Join(string(projectDir), "filebm25.db"))
+}
+func NewLexicalTimestampCache(projectDir project.ProjectDir) (types.DBCollectionInterface[types.FileStamp], error) {
+	return localdb.NewFileDB[types.FileStamp](path.Join(string(projectDir), "filebm25stamps.db"))
+}
 func NewLocalProject(baseDir string) (project.Project, error) {
 	filesStampsDB, err := NewFilestampCache("./.tzap-data")
 	if err != nil {
@@ -50,6 +67,18 @@ func NewLocalProject(baseDir string) (project.Project, error) {
 	if err != nil {
 		return nil, err
 	}
+	indexHeader, err := NewIndexHeader(project.ProjectDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
+	lexicalIndex, err := NewLexicalIndex(project.ProjectDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
+	lexicalTimestamps, err := NewLexicalTimestampCache(project.ProjectDir(projectDir))
+	if err != nil {
+		return nil, err
+	}
 	localProject := &LocalProject{
 		projectName:         project.LOCALPROJECTNAME,
 		baseDir:             baseDir,
@@ -57,6 +86,10 @@ func NewLocalProject(baseDir string) (project.Project, error) {
 		embeddingCacheDB:    embeddingCacheDB,
 		projectDir:          projectDir,
 		embeddingCollection: embeddingCollection,
+		indexHeader:         indexHeader,
+		vectorIndex:         NewVectorIndex(project.ProjectDir(projectDir), embeddingCollection),
+		lexicalIndex:        lexicalIndex,
+		lexicalTimestamps:   lexicalTimestamps,
 		LocalWalker:         localWalker,
 	}
 
@@ -65,7 +98,7 @@ func NewLocalProject(baseDir string) (project.Project, error) {
 func (l *LocalProject) CanIndex() bool {
 	return true
 }
-func (l *LocalProject) GetTimestampCache() types.DBCollectionInterface[int64] {
+func (l *LocalProject) GetTimestampCache() types.DBCollectionInterface[types.FileStamp] {
 	return l.filestampsDB
 }
 
@@ -78,6 +111,26 @@ func (l *LocalProject) GetEmbeddingCollection() types.DBCollectionInterface[type
 	return l.embeddingCollection
 }
 
+// GetIndexHeader implements project.Project
+func (l *LocalProject) GetIndexHeader() types.DBCollectionInterface[types.IndexHeader] {
+	return l.indexHeader
+}
+
+// GetVectorIndex implements project.Project
+func (l *LocalProject) GetVectorIndex() types.VectorIndex {
+	return l.vectorIndex
+}
+
+// GetLexicalIndex implements project.Project
+func (l *LocalProject) GetLexicalIndex() types.DBCollectionInterface[types.LexicalDocument] {
+	return l.lexicalIndex
+}
+
+// GetLexicalTimestampCache implements project.Project
+func (l *LocalProject) GetLexicalTimestampCache() types.DBCollectionInterface[types.FileStamp] {
+	return l.lexicalTimestamps
+}
+
 // GetProjectName implements project.Project
 func (l *LocalProject) GetProjectName() project.ProjectName {
 	return l.projectName
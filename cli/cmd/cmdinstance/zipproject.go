@@ -16,8 +16,12 @@ type ZipProject struct {
 	projectDir           project.ProjectDir
 	baseDir              string
 	embeddingCollection  types.DBCollectionInterface[types.Vector]
+	indexHeader          types.DBCollectionInterface[types.IndexHeader]
+	vectorIndex          types.VectorIndex
+	lexicalIndex         types.DBCollectionInterface[types.LexicalDocument]
+	lexicalTimestamps    types.DBCollectionInterface[types.FileStamp]
 	embeddingsCache      types.DBCollectionInterface[string]
-	filestampsCache      types.DBCollectionInterface[int64]
+	filestampsCache      types.DBCollectionInterface[types.FileStamp]
 	*zipwalker.ZipWalker //GetFiles() @TODO: Refactor to FS interface?
 }
 
@@ -31,6 +35,18 @@ func NewLocalZipProject(name, relativeDirInZip, url string) (project.Project, er
 	if err != nil {
 		return nil, err
 	}
+	indexHeader, err := NewIndexHeader(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	lexicalIndex, err := NewLexicalIndex(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	lexicalTimestamps, err := NewLexicalTimestampCache(projectDir)
+	if err != nil {
+		return nil, err
+	}
 	embeddingsCache, err := NewEmbeddingsCache(projectDir)
 	if err != nil {
 		return nil, err
@@ -44,6 +60,10 @@ func NewLocalZipProject(name, relativeDirInZip, url string) (project.Project, er
 		baseDir:             relativeDirInZip,
 		projectDir:          projectDir,
 		embeddingCollection: embeddingCollection,
+		indexHeader:         indexHeader,
+		vectorIndex:         NewVectorIndex(projectDir, embeddingCollection),
+		lexicalIndex:        lexicalIndex,
+		lexicalTimestamps:   lexicalTimestamps,
 		embeddingsCache:     embeddingsCache,
 		filestampsCache:     filestampCache,
 		ZipWalker:           zipwalker,
@@ -60,7 +80,7 @@ func (l *ZipProject) GetEmbeddingsCache() types.DBCollectionInterface[string] {
 }
 
 // GetTimestampCache implements project.Project
-func (l *ZipProject) GetTimestampCache() types.DBCollectionInterface[int64] {
+func (l *ZipProject) GetTimestampCache() types.DBCollectionInterface[types.FileStamp] {
 	return l.filestampsCache
 }
 
@@ -69,6 +89,26 @@ func (l *ZipProject) GetEmbeddingCollection() types.DBCollectionInterface[types.
 	return l.embeddingCollection
 }
 
+// GetIndexHeader implements project.Project
+func (l *ZipProject) GetIndexHeader() types.DBCollectionInterface[types.IndexHeader] {
+	return l.indexHeader
+}
+
+// GetVectorIndex implements project.Project
+func (l *ZipProject) GetVectorIndex() types.VectorIndex {
+	return l.vectorIndex
+}
+
+// GetLexicalIndex implements project.Project
+func (l *ZipProject) GetLexicalIndex() types.DBCollectionInterface[types.LexicalDocument] {
+	return l.lexicalIndex
+}
+
+// GetLexicalTimestampCache implements project.Project
+func (l *ZipProject) GetLexicalTimestampCache() types.DBCollectionInterface[types.FileStamp] {
+	return l.lexicalTimestamps
+}
+
 // GetProjectName implements project.Project
 func (l *ZipProject) GetProjectName() project.ProjectName {
 	return l.projectName
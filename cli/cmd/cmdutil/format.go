@@ -4,8 +4,19 @@ import (
 	"fmt"
 
 	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
 )
 
 func FormatVectorToClickable(v types.Vector) string {
+	if symbol := v.Metadata.QualifiedSymbol(); symbol != "" {
+		return fmt.Sprintf("%s:%d %s", v.Metadata.Filename, v.Metadata.LineStart, symbol)
+	}
 	return fmt.Sprintf("%s:%d", v.Metadata.Filename, v.Metadata.LineStart)
 }
+
+// PrintContextReport prints what the context strategy removed from the thread of the completion of t, if anything.
+func PrintContextReport(t *tzap.Tzap) {
+	if report, ok := tzap.ContextReportKey.Lookup(t); ok && report.Changed() {
+		println(Yellow(report.String()))
+	}
+}
@@ -54,6 +54,9 @@ var editdirCmd = &cobra.Command{
 					Yes:              tzapCliSettings.Yes,
 					MessageThread:    []types.Message{},
 				}
+				if !tzapCliSettings.ApiMode {
+					promptWorkflowArgs.Output = cmd.OutOrStdout()
+				}
 				t.
 					ApplyWorkflow(action.PromptWorkflow(promptWorkflowArgs)).
 					ApplyWorkflowFN(codegeneration.MakeCode(config)).
@@ -37,7 +37,7 @@ var findCmd = &cobra.Command{
 			t := cmdutil.GetTzapFromContext(cmd.Context())
 			defer t.HandleShutdown()
 			actionArgs := &actionpb.SearchArgs{
-				ExcludeFiles: []string{},
+				ExcludeFiles: ignoreFiles,
 				SearchQuery:  findQuery,
 				EmbedsCount:  -1,
 				NCount:       -1,
@@ -48,7 +48,7 @@ var findCmd = &cobra.Command{
 				AddSystemMessage(action.FindChainOfThoughtPrompt()).
 				ApplyWorkflow(action.LoadAndSearchEmbeddingsWorkflow(actionArgs)).
 				MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
-					original := t.Data["searchResults"].(types.SearchResults)
+					original := embedworkflows.SearchResultsKey.Must(t)
 					filenames := map[string]struct{}{}
 					for _, result := range original.Results {
 						filenames[result.Vector.Metadata.Filename] = struct{}{}
@@ -67,12 +67,17 @@ var findCmd = &cobra.Command{
 				}).
 				WorkTzap(func(t *tzap.Tzap) {
 					println("---")
-					t = t.
-						AddUserMessage("####Find files for:\n" + findQuery).
-						RequestChatCompletion()
+					answer, _, err := tzap.RequestJSON(t.AddUserMessage("Find files for:\n"+findQuery), tzap.JSONOptions[action.FindAnswer]{})
+					if err != nil {
+						panic(err)
+					}
 					println("\n---\n")
-					println(t.Data["content"].(string))
-
+					println(cmdutil.Bold("Query: "), answer.Query)
+					println(answer.Reasoning)
+					println(cmdutil.Bold("\nFiles:"))
+					for _, filepath := range answer.Files {
+						println(filepath)
+					}
 				})
 
 		})
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,8 +9,8 @@ import (
 
 	"github.com/spf13/cobra"
 	"github.com/tzapio/tzap/cli/cmd/cmdutil"
+	"github.com/tzapio/tzap/pkg/tzap"
 	"github.com/tzapio/tzap/pkg/util/stdin"
-	"github.com/tzapio/tzap/workflows/stdinworkflows"
 )
 
 const (
@@ -37,6 +36,12 @@ func gitTagExists(tag string) (bool, error) {
 	return false, nil
 }
 
+// githubRelease is the JSON answer asked for by ghrelease.
+type githubRelease struct {
+	Title string `json:"title"`
+	Notes string `json:"notes" description:"release notes in markdown"`
+}
+
 var ghrelease = &cobra.Command{
 	Use:    "ghrelease <tag>",
 	Short:  "Generate a GitHub release",
@@ -81,27 +86,20 @@ The release is then created on GitHub using the title and notes generated by Cha
 		}
 
 		t := cmdutil.GetTzapFromContext(cmd.Context()).
-			AddSystemMessage(fmt.Sprintf(`Be creative and output a GitHub release using the JSON Template. Use titles: Use cases, Features, Changes. Please include the compare tag URL.
+			AddSystemMessage(`Be creative and output a GitHub release. Use titles: Use cases, Features, Changes. Please include the compare tag URL.
 
-Template:
-{"title":{title},"notes":{release notes in markdown}}
-
-Repository: ` + string(url))).
+Repository: ` + string(url)).
 			AddUserMessage(fmt.Sprintf("Title: %s\n\nGit Commits:\n%s", title, summary))
 
-		res := t.RequestChatCompletion().
-			ApplyWorkflow(stdinworkflows.BeforeProceedingWorkflow())
-
-		// Parse the JSON object
-		var data map[string]string
-		err = json.Unmarshal([]byte(res.Data["content"].(string)), &data)
+		release, _, err := tzap.RequestJSON(t, tzap.JSONOptions[githubRelease]{})
 		if err != nil {
-			cmd.Println("Could not parse JSON object:", err)
+			cmd.Println("Could not generate release notes:", err)
 			return
 		}
 
-		// Get title and notes from the JSON object
-		notes := data["notes"]
+		notes := release.Notes
+		cmd.Println(cmdutil.Bold("Title: "), release.Title)
+		cmd.Println(notes)
 		if !stdin.ConfirmPrompt("Continue with release?") {
 			return
 		}
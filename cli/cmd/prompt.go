@@ -10,8 +10,9 @@ import (
 	"github.com/tzapio/tzap/cli/action"
 	"github.com/tzapio/tzap/cli/cmd/cmdui"
 	"github.com/tzapio/tzap/cli/cmd/cmdutil"
-	"github.com/tzapio/tzap/internal/logging/tl"
 
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/types/openai"
 	"github.com/tzapio/tzap/pkg/tzap"
@@ -37,6 +38,17 @@ func init() {
 		"For large projects disabling indexing speeds up the process.")
 	promptCmd.Flags().StringVarP(&promptFile, "promptfile", "f", "", "Read from file instead of prompt")
 	promptCmd.Flags().StringVarP(&lib, "lib", "l", "", "BETA: select library to search.")
+	promptCmd.Flags().BoolVar(&tzapCliSettings.Rerank, "rerank", false, "Have --rerankmodel score the -n best search results and keep the -k best by score.")
+}
+
+// promptThreadTokens limits the conversation kept by prompt to --truncate tokens, or without it to the context window
+// of the chat model. Models missing from the models registry keep the whole conversation.
+func promptThreadTokens(t *tzap.Tzap) int {
+	conf := config.FromContext(t.C)
+	if conf.TruncateLimit > 0 {
+		return conf.TruncateLimit
+	}
+	return models.ContextWindow(conf.OpenAIModel, 0)
 }
 
 var promptCmd = &cobra.Command{
@@ -51,7 +63,6 @@ var promptCmd = &cobra.Command{
 }
 
 func promptFunc(cmd *cobra.Command, args []string) {
-	tl.EnableUICompletionLogger()
 	t := cmdutil.GetTzapFromContext(cmd.Context())
 
 	embedsCount := embedsCountFlag
@@ -93,7 +104,13 @@ func promptFunc(cmd *cobra.Command, args []string) {
 				continue
 			}
 			searchQuery = messageThread.LastMessage().Content
-			truncThread := tzap.TruncateToMaxTokens(t.TG, messageThread.GetMessages(), 4000)
+			truncThread, report, err := t.FitContextTokens(tzap.ContextMessages(messageThread.GetMessages()), promptThreadTokens(t))
+			if err != nil {
+				panic(err)
+			}
+			if report.Changed() {
+				cmd.Println(cmdutil.Yellow(report.String()))
+			}
 
 			promptWorkflowArgs := action.PromptWorkflowArgs{
 				InspirationFiles: inspirationFiles,
@@ -104,6 +121,9 @@ func promptFunc(cmd *cobra.Command, args []string) {
 				Yes:              tzapCliSettings.Yes,
 				MessageThread:    truncThread,
 			}
+			if !tzapCliSettings.ApiMode {
+				promptWorkflowArgs.Output = cmd.OutOrStdout()
+			}
 
 			cmd.Println(cmdutil.Bold("\nSearch query: "), cmdutil.Yellow(searchQuery))
 			t.WorkTzap(func(t *tzap.Tzap) {
@@ -12,11 +12,18 @@ var ResetCmd = &cobra.Command{
 	Short: "Resetting embeddings and other files",
 
 	Run: func(cmd *cobra.Command, args []string) {
-		// delete .tzap-data/embeddingsCache.db, fileembeddings.db, and filesTimestamps.db
+		// delete .tzap-data/embeddingsCache.db, fileembeddings.db, fileembeddings.header.db, fileembeddings.hnsw, the file stamps and the bm25 index.
+		// filesTimestamps.db and filebm25Timestamps.db hold the edit times of older versions.
 		tzapDataFilesToDelete := []string{
 			"embeddingsCache.db",
 			"fileembeddings.db",
+			"fileembeddings.header.db",
+			"fileembeddings.hnsw",
+			"filestamps.db",
 			"filesTimestamps.db",
+			"filebm25.db",
+			"filebm25stamps.db",
+			"filebm25Timestamps.db",
 		}
 
 		for _, file := range tzapDataFilesToDelete {
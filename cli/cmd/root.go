@@ -3,37 +3,62 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/tzapio/tzap/cli/cmd/cmdinstance"
 	"github.com/tzapio/tzap/cli/cmd/cmdutil"
 	"github.com/tzapio/tzap/internal/logging/tl"
 	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/project"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/types/openai"
 	"github.com/tzapio/tzap/pkg/tzap"
 	"github.com/tzapio/tzap/pkg/tzapconnect"
+	"github.com/tzapio/tzap/pkg/tzapconnect/cassetteconnector"
 	"github.com/tzapio/tzap/pkg/tzapconnect/stubconnector"
 )
 
 var tzapCliSettings struct {
-	Model         string
-	AutoMode      bool
-	TruncateLimit int
-	ConfigPath    string
-	MD5Rewrites   bool
-	DisableLogs   bool
-	LoggerOutput  string
-	Stub          bool
-	Temperature   float32
-	Verbose       bool
-	ApiMode       bool
-	Yes           bool
-	Editor        string
-	EmbeddingURL  string
-	CompletionURL string
+	Model            string
+	EmbedModel       string
+	EmbedDimensions  int
+	EmbedWorkers     int
+	EmbedBatchTokens int
+	Backend          string
+	HybridWeight     float64
+	ExactSearch      bool
+	Diversity        float64
+	MaxPerFile       int
+	Rerank           bool
+	RerankModel      string
+	HyDE             bool
+	SubQueries       int
+	ExpandModel      string
+	AutoMode         bool
+	TruncateLimit    int
+	ContextStrategy  string
+	ConfigPath       string
+	MD5Rewrites      bool
+	DisableLogs      bool
+	LoggerOutput     string
+	Stub             bool
+	Cassette         string
+	Record           bool
+	Temperature      float32
+	Verbose          bool
+	ApiMode          bool
+	Yes              bool
+	Editor           string
+	EmbeddingURL     string
+	CompletionURL    string
+	// Chunkers are the "chunkers" of .tzap-data/config.json.
+	Chunkers map[string]config.ChunkerOptions
 }
 
 var RootCmd = &cobra.Command{
@@ -48,11 +73,23 @@ var RootCmd = &cobra.Command{
 			tl.EnableUICompletionLogger()
 			tl.EnableUILogger()
 		}
+		if !tzapCliSettings.ApiMode {
+			// Completions stream to the terminal. In api mode stdout only carries the result.
+			tl.EnableUICompletionLogger()
+		}
 		//check subcommand if init or help
 		if cmd.Name() == "init" || cmd.Name() == "help" || cmd.Name() == "install" {
 			return nil
 		}
 
+		if tzapCliSettings.Cassette != "" {
+			// The cassette path is relative to where tzap was started, not the project root it changes to.
+			cassette, err := filepath.Abs(tzapCliSettings.Cassette)
+			if err != nil {
+				return err
+			}
+			tzapCliSettings.Cassette = cassette
+		}
 		baseDir, err := cmdutil.SearchForTzapincludeAndGetRootDir()
 		if err != nil {
 			println("Warning: No .tzapinclude file found. Run 'tzap init' Using current directory as root.", err)
@@ -61,11 +98,30 @@ var RootCmd = &cobra.Command{
 		}
 		data, err := os.ReadFile(".tzap-data/config.json")
 		if err == nil {
-			var cfg map[string]interface{}
+			var cfg map[string]json.RawMessage
 			if err := json.Unmarshal(data, &cfg); err == nil {
-				if editor, ok := cfg["editor"].(string); ok {
+				var editor string
+				if err := json.Unmarshal(cfg["editor"], &editor); err == nil {
 					tzapCliSettings.Editor = editor
 				}
+				if projectModels, ok := cfg["models"]; ok {
+					if err := models.Load(projectModels); err != nil {
+						return fmt.Errorf(".tzap-data/config.json: %w", err)
+					}
+				}
+				if chunkers, ok := cfg["chunkers"]; ok {
+					if err := json.Unmarshal(chunkers, &tzapCliSettings.Chunkers); err != nil {
+						return fmt.Errorf(".tzap-data/config.json chunkers: %w", err)
+					}
+					if _, err := embed.NewChunkers(tzapCliSettings.Chunkers); err != nil {
+						return fmt.Errorf(".tzap-data/config.json: %w", err)
+					}
+				}
+				if contextStrategy, ok := cfg["contextStrategy"]; ok && !cmd.Flags().Changed("context-strategy") {
+					if err := json.Unmarshal(contextStrategy, &tzapCliSettings.ContextStrategy); err != nil {
+						return fmt.Errorf(".tzap-data/config.json contextStrategy: %w", err)
+					}
+				}
 			}
 		} else {
 			tl.Logger.Println("No config.json found")
@@ -110,31 +166,110 @@ var RootCmd = &cobra.Command{
 }
 
 func initializeTzap() (*tzap.Tzap, error) {
+	chatModel, err := models.Resolve(tzapCliSettings.Model, models.KindChat)
+	if err != nil {
+		return nil, err
+	}
+	embedModel, err := models.Resolve(tzapCliSettings.EmbedModel, models.KindEmbedding)
+	if err != nil {
+		return nil, err
+	}
+	rerankModel, err := models.Resolve(tzapCliSettings.RerankModel, models.KindChat)
+	if err != nil {
+		return nil, err
+	}
+	expandModel, err := models.Resolve(tzapCliSettings.ExpandModel, models.KindChat)
+	if err != nil {
+		return nil, err
+	}
+	if tzapCliSettings.EmbedWorkers < 1 {
+		return nil, fmt.Errorf("--embedworkers must be at least 1, got %d", tzapCliSettings.EmbedWorkers)
+	}
+	if tzapCliSettings.SubQueries < 0 || tzapCliSettings.SubQueries > 4 {
+		return nil, fmt.Errorf("--subqueries must be between 0 and 4, got %d", tzapCliSettings.SubQueries)
+	}
+	if _, err := tzap.GetContextStrategy(tzapCliSettings.ContextStrategy); err != nil {
+		return nil, fmt.Errorf("--context-strategy: %w", err)
+	}
+	if tzapCliSettings.HybridWeight <= 0 || tzapCliSettings.HybridWeight >= 1 {
+		return nil, fmt.Errorf("--hybridweight must be between 0 and 1 exclusive, got %v. Use --backend embedding or --backend bm25 to search one index only",
+			tzapCliSettings.HybridWeight)
+	}
+	switch tzapCliSettings.Backend {
+	case config.SearchBackendAuto, config.SearchBackendEmbedding, config.SearchBackendBM25, config.SearchBackendHybrid:
+	default:
+		return nil, fmt.Errorf("unknown --backend %q. Available: %s, %s, %s, %s", tzapCliSettings.Backend,
+			config.SearchBackendAuto, config.SearchBackendEmbedding, config.SearchBackendBM25, config.SearchBackendHybrid)
+	}
 	config := config.Configuration{
-		OpenAIModel:   modelMap[tzapCliSettings.Model],
-		AutoMode:      tzapCliSettings.Yes, // automode == yes
-		TruncateLimit: tzapCliSettings.TruncateLimit,
-		MD5Rewrites:   tzapCliSettings.MD5Rewrites,
-		EnableLogs:    !tzapCliSettings.DisableLogs,
-		LoggerOutput:  tzapCliSettings.LoggerOutput,
-		Temperature:   tzapCliSettings.Temperature,
-		EmbeddingURL:  tzapCliSettings.EmbeddingURL,
-		CompletionURL: tzapCliSettings.CompletionURL,
+		OpenAIModel:      chatModel.ID,
+		EmbedModel:       embedModel.ID,
+		EmbedDimensions:  tzapCliSettings.EmbedDimensions,
+		EmbedWorkers:     tzapCliSettings.EmbedWorkers,
+		EmbedBatchTokens: tzapCliSettings.EmbedBatchTokens,
+		SearchBackend:    tzapCliSettings.Backend,
+		HybridWeight:     tzapCliSettings.HybridWeight,
+		ExactSearch:      tzapCliSettings.ExactSearch,
+		Diversity:        tzapCliSettings.Diversity,
+		MaxChunksPerFile: tzapCliSettings.MaxPerFile,
+		Rerank:           tzapCliSettings.Rerank,
+		RerankModel:      rerankModel.ID,
+		HyDE:             tzapCliSettings.HyDE,
+		SubQueries:       tzapCliSettings.SubQueries,
+		ExpandModel:      expandModel.ID,
+		Chunkers:         tzapCliSettings.Chunkers,
+		AutoMode:         tzapCliSettings.Yes, // automode == yes
+		TruncateLimit:    tzapCliSettings.TruncateLimit,
+		ContextStrategy:  tzapCliSettings.ContextStrategy,
+		MD5Rewrites:      tzapCliSettings.MD5Rewrites,
+		EnableLogs:       !tzapCliSettings.DisableLogs,
+		LoggerOutput:     tzapCliSettings.LoggerOutput,
+		UsageLog:         ".tzap-data/usage.jsonl",
+		Temperature:      tzapCliSettings.Temperature,
+		EmbeddingURL:     tzapCliSettings.EmbeddingURL,
+		CompletionURL:    tzapCliSettings.CompletionURL,
 	}
 
-	var connector types.TzapConnector
+	connector, err := newConnector(config)
+	if err != nil {
+		return nil, err
+	}
+	t := tzap.NewWithConnector(connector)
+
+	return t, nil
+}
+
+// newConnector returns the live connector, or with --stub an offline one. --stub --cassette replays a cassette
+// recorded with --record --cassette and fails on calls missing from it, so e2e tests run without network.
+func newConnector(conf config.Configuration) (types.TzapConnector, error) {
 	if tzapCliSettings.Stub {
-		connector = stubconnector.StubWithConfig(config)
-	} else {
-		apikey, err := tzapconnect.LoadOPENAI_API_KEY()
+		if tzapCliSettings.Cassette == "" {
+			return tzapconnect.WrapConnector(stubconnector.StubWithConfig(conf), tzapconnect.Log()), nil
+		}
+		cassette, err := cassetteconnector.LoadCassette(tzapCliSettings.Cassette)
 		if err != nil {
 			return nil, err
 		}
-		connector = tzapconnect.WithConfig(apikey, config)
+		replay := cassetteconnector.ReplayWithConfig(tzapconnect.PartialComposite{}, cassette, conf)
+		return tzapconnect.WrapConnector(replay, tzapconnect.Log()), nil
 	}
-	t := tzap.NewWithConnector(connector)
-
-	return t, nil
+	apikey, err := tzapconnect.LoadOPENAI_API_KEY()
+	// The bm25 backend searches without the API, so search runs without a key. Commands calling a model fail later.
+	if err != nil && conf.SearchBackend != config.SearchBackendBM25 {
+		return nil, err
+	}
+	connector := tzapconnect.WithConfig(apikey, conf)
+	if tzapCliSettings.Record {
+		if tzapCliSettings.Cassette == "" {
+			return nil, fmt.Errorf("--record needs --cassette")
+		}
+		cassette, err := cassetteconnector.OpenCassette(tzapCliSettings.Cassette)
+		if err != nil {
+			return nil, err
+		}
+		connector = cassetteconnector.RecordWithConfig(connector, cassette)
+	}
+	return connector, nil
 }
 func Execute() {
 	err := RootCmd.Execute()
@@ -143,28 +278,38 @@ func Execute() {
 	}
 }
 
-var modelMap map[string]string = map[string]string{
-	"gpt35":    openai.GPT3Dot5Turbo,
-	"gpt356":   openai.GPT3Dot5Turbo0613,
-	"gpt3516":  openai.GPT16,
-	"gpt3516k": openai.GPT16,
-	"gpt16":    openai.GPT16,
-	"gpt4":     openai.GPT4,
-}
-
 func init() {
 	RootCmd.CompletionOptions.HiddenDefaultCmd = true
 	tzapCliSettings.MD5Rewrites = true
 
-	RootCmd.PersistentFlags().StringVarP(&tzapCliSettings.Model, "model", "m", "gpt35", "Define what openai model to use. (Available gpt35 gpt356 (june model) gpt3516 (alias gpt16) gpt4).")
+	RootCmd.PersistentFlags().StringVarP(&tzapCliSettings.Model, "model", "m", "gpt35", "Chat model id or alias. Add models under \"models\" in .tzap-data/config.json. (Available "+strings.Join(models.Names(models.KindChat), ", ")+").")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.EmbedModel, "embedmodel", openai.AdaEmbeddingV2, "Embedding model id or alias. Changing it requires tzap reset. (Available "+strings.Join(models.Names(models.KindEmbedding), ", ")+").")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.EmbedWorkers, "embedworkers", 4, "Number of embedding requests sent at once while indexing.")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.EmbedBatchTokens, "embedbatchtokens", 0, "Token limit of one embedding request while indexing. 0 uses the limit of --embedmodel.")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.Backend, "backend", config.SearchBackendAuto, "Search backend: embedding, bm25 to search a local index without an embedding API, or hybrid to fuse both. auto is hybrid once a bm25 index exists.")
+	RootCmd.PersistentFlags().Float64Var(&tzapCliSettings.HybridWeight, "hybridweight", 0.5, "Weight of the embedding ranking in hybrid search, between 0 and 1. The bm25 ranking gets the rest.")
+	RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.ExactSearch, "exactsearch", false, "Compare the query with every embedding instead of using the nearest neighbour index. Slower, used to check the index.")
+	RootCmd.PersistentFlags().Float64Var(&tzapCliSettings.Diversity, "diversity", 0, "Trade relevance of search results for variety, between 0 and 1. Picks from the -n best results with maximal marginal relevance.")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.RerankModel, "rerankmodel", "gpt-3.5-turbo", "Chat model scoring search results for --rerank.")
+	RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.HyDE, "hyde", false, "Also search with a hypothetical code snippet answering the query, written by --expandmodel.")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.SubQueries, "subqueries", 0, "Also search with up to 4 rephrasings of the query, written by --expandmodel.")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.ExpandModel, "expandmodel", "gpt-3.5-turbo", "Chat model expanding search queries for --hyde and --subqueries.")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.MaxPerFile, "maxperfile", 0, "Return at most this many chunks of one file per search. 0 means no limit.")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.EmbedDimensions, "embeddimensions", 0, "Shorten embeddings to this many dimensions, for models that support it. Changing it requires tzap reset.")
 	RootCmd.PersistentFlags().StringVarP(&tzapCliSettings.CompletionURL, "baseurl", "b", "", "Completion URL")
 	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.EmbeddingURL, "embeddingbaseurl", "", "Embedding URL")
 	//RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.AutoMode, "automode", false, "Some but not all functions prompt if you want to overwrite an existing file. Putting automode to true enaled overwriting for those cases. Setting this to false does not disable anything.")
-	//RootCmd.PersistentFlags().IntVar(&tzapCliSettings.TruncateLimit, "truncate", 0, "Truncate limit for the interaction.")
+	RootCmd.PersistentFlags().IntVar(&tzapCliSettings.TruncateLimit, "truncate", 0, "Token limit for the request thread. 0 sends the whole thread.")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.ContextStrategy, "context-strategy", tzap.ContextStrategyNewest, "How to fit the thread into --truncate tokens (newest, relevance, summarize). Defaults to \"contextStrategy\" of .tzap-data/config.json.")
 	//RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.MD5Rewrites, "md5rewrites", true, "For some functions, this flag enables overwriting files with the same MD5 hash.")
 	//RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.DisableLogs, "disablelogs", false, "Whether to disable logging.")
 	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.LoggerOutput, "loggeroutput", ".tzap-data/logs/", "Path and name of the log file.")
-	//RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.Stub, "stub", false, "Test non-live mode")
+	RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.Stub, "stub", false, "Test non-live mode. Replays --cassette when set.")
+	RootCmd.PersistentFlags().StringVar(&tzapCliSettings.Cassette, "cassette", "", "Cassette file to record to with --record or replay with --stub.")
+	RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.Record, "record", false, "Record model calls to --cassette.")
+	RootCmd.PersistentFlags().MarkHidden("stub")
+	RootCmd.PersistentFlags().MarkHidden("cassette")
+	RootCmd.PersistentFlags().MarkHidden("record")
 	RootCmd.PersistentFlags().Float32VarP(&tzapCliSettings.Temperature, "temperature", "t", 1.0, "Temperature for the interaction.")
 	RootCmd.PersistentFlags().BoolVarP(&tzapCliSettings.Verbose, "verbose", "v", false, "Enable verbose logging")
 	RootCmd.PersistentFlags().BoolVar(&tzapCliSettings.ApiMode, "api", false, "ALPHA: Enable clean stdout outputs. Also turns off editor mode.")
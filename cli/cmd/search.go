@@ -25,13 +25,18 @@ func init() {
 	searchCmd.Flags().StringSliceVarP(&ignoreFiles, "ignore", "i", []string{}, "Files to exclude from search")
 	searchCmd.Flags().BoolVarP(&disableIndex, "disableindex", "d", false, "For large projects disabling indexing speeds up the process.")
 	searchCmd.Flags().StringVarP(&lib, "lib", "l", "", "BETA: select library to search.")
+	searchCmd.Flags().BoolVar(&tzapCliSettings.Rerank, "rerank", false, "Have --rerankmodel score the -n best search results and keep the -k best by score.")
 }
 
 var searchCmd = &cobra.Command{
 	Aliases: []string{"s"},
 	Use:     "search <query>",
 	Short:   "Search for relevant embeddings using the query",
-	Args:    cobra.MinimumNArgs(1),
+	Long: `Search for relevant embeddings using the query.
+
+The query can restrict the files searched with path:, dir:, lang: and ext: terms, and exclude files by prefixing
+them with -. For example: tzap search 'path:pkg/embed lang:go -path:*_test.go cosine distance'`,
+	Args: cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		tl.Logger.Println("Cobra CLI Search start")
 		searchQuery := strings.Join(args, " ")
@@ -42,7 +47,7 @@ var searchCmd = &cobra.Command{
 			defer t.HandleShutdown()
 
 			output := action.LoadAndSearchEmbeddings(t, &actionpb.SearchArgs{
-				ExcludeFiles: []string{},
+				ExcludeFiles: ignoreFiles,
 				SearchQuery:  searchQuery,
 				EmbedsCount:  embedsCountFlag,
 				NCount:       nCountFlag,
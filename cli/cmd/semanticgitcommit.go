@@ -15,6 +15,10 @@ import (
 )
 
 var showDiff bool
+
+// extraPromptKey holds the clarifying prompt given as command arguments.
+var extraPromptKey = tzap.Key[string]("extraPrompt")
+
 var semanticGitcommitCmd = &cobra.Command{
 	Aliases: []string{"c", "commit"},
 	Use:     "commit [clarifying prompt]",
@@ -25,12 +29,12 @@ var semanticGitcommitCmd = &cobra.Command{
 			t := cmdutil.GetTzapFromContext(cmd.Context())
 			defer t.HandleShutdown()
 			t.
-				ApplyWorkflow(gocode.DeserializedArguments("extraPrompt", args)).
+				ApplyWorkflow(gocode.DeserializedArguments(string(extraPromptKey), args)).
 				ApplyErrorWorkflow(git.GitDiff(), func(et *tzap.ErrorTzap) error {
 					return et.Err
 				}).
 				WorkTzap(func(t *tzap.Tzap) {
-					diff := t.Data["git-diff"].(string)
+					diff := git.GitDiffKey.Must(t)
 					cmd.Print("Reading staged git commit diffs")
 					if !showDiff {
 						cmd.Println(" (Use --show-diff to show the git diff)")
@@ -80,14 +84,24 @@ func RequestChat() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
 		Name: "RequestChat",
 		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
-			extraPrompt := t.Data["extraPrompt"].(string)
-			diff := t.Data["git-diff"].(string)
+			extraPrompt, err := extraPromptKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
+			diff, err := git.GitDiffKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
 			t = t.AddSystemMessage(`Write one commit using semantic commit specification. \n\n` + CV100)
 			if extraPrompt != "" {
 				t = t.AddUserMessage(extraPrompt)
 			}
 			return t.AddUserMessage(diff).
 				RequestChatCompletion().
+				MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
+					cmdutil.PrintContextReport(t)
+					return t
+				}).
 				ApplyWorkflow(stdinworkflows.BeforeProceedingWorkflow()).
 				ErrorTzap(nil)
 		}}
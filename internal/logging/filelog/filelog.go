@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"sync"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
 	"github.com/tzapio/tzap/pkg/config"
@@ -20,11 +21,16 @@ const (
 	DotLog      LogType = "dotlog"
 )
 
+// logLock serializes the search for a free log file so concurrent writers do not pick the same file.
+var logLock sync.Mutex
+
 func LogData(ctx context.Context, data interface{}, logType LogType) {
 	config := config.FromContext(ctx)
 	if config.LoggerOutput == "" {
 		return
 	}
+	logLock.Lock()
+	defer logLock.Unlock()
 	for i := 100000; i >= 0; i-- {
 		dir := path.Join(config.LoggerOutput, string(logType))
 		filename := path.Join(dir, fmt.Sprintf("out_%d.log", i))
@@ -8,22 +8,101 @@ import (
 
 type configKey struct{}
 
+// Search backends.
+const (
+	// SearchBackendEmbedding searches the embedding index, fetching embeddings of the files and the query.
+	SearchBackendEmbedding = "embedding"
+	// SearchBackendBM25 searches a BM25 index built locally, without model calls.
+	SearchBackendBM25 = "bm25"
+	// SearchBackendHybrid searches both indexes and fuses their rankings.
+	SearchBackendHybrid = "hybrid"
+	// SearchBackendAuto searches hybrid when the project has a BM25 index, and the embedding index otherwise.
+	SearchBackendAuto = "auto"
+)
+
+// Chunking strategies, see ChunkerOptions.
+const (
+	// ChunkerLegacy cuts files into windows of 200 tokens, the chunks of indexes built before chunkers were configurable.
+	ChunkerLegacy = "legacy"
+	// ChunkerWindow cuts files into windows of Size tokens overlapping by Overlap tokens.
+	ChunkerWindow = "window"
+	// ChunkerGo cuts Go files along their top-level declarations.
+	ChunkerGo = "go"
+	// ChunkerMarkdown cuts markdown files along their headings.
+	ChunkerMarkdown = "markdown"
+	// ChunkerBlocks cuts source files of any language along unindented lines following blank lines.
+	ChunkerBlocks = "blocks"
+)
+
+// ChunkerOptions select how the files of an extension are cut into chunks.
+type ChunkerOptions struct {
+	// Strategy is one of the Chunker constants.
+	Strategy string `json:"strategy"`
+	// Size is the number of tokens of a window, or up to which small declarations and sections are packed together.
+	// 0 means 200.
+	Size int `json:"size,omitempty"`
+	// Overlap is the number of tokens a window shares with the previous one.
+	Overlap int `json:"overlap,omitempty"`
+}
+
 type Configuration struct {
-	OpenAIModel    string
-	EmbedModel     string
-	CompletionURL  string
-	EmbeddingURL   string
-	AutoMode       bool
-	TruncateLimit  int
-	MD5Rewrites    bool
-	MD5IncludeList []string
-	EnableLogs     bool
-	LoggerOutput   string
-	Temperature    float32
+	OpenAIModel string
+	EmbedModel  string
+	// EmbedDimensions shortens the vectors of embedding models that support it, such as text-embedding-3-small. 0 means the full length.
+	EmbedDimensions int
+	// EmbedWorkers is how many embedding requests indexing sends at once. 0 means 4.
+	EmbedWorkers int
+	// EmbedBatchTokens caps the tokens of one embedding request. 0 means the MaxRequestTokens of EmbedModel.
+	EmbedBatchTokens int
+	// SearchBackend is the index searched for context, one of the SearchBackend constants. Empty means SearchBackendAuto.
+	SearchBackend string
+	// HybridWeight is the weight of the embedding ranking in hybrid search, the BM25 ranking gets the rest. 0 means 0.5.
+	HybridWeight float64
+	// ExactSearch compares the query with every embedding instead of searching the nearest neighbour index.
+	// It is slower, and used to verify the recall of the index.
+	ExactSearch bool
+	// Diversity trades the relevance of search results for variety, between 0 and 1. 0 ranks by relevance only.
+	Diversity float64
+	// MaxChunksPerFile caps how many chunks of one file a search returns. 0 means no cap.
+	MaxChunksPerFile int
+	// Rerank has the search actions ask RerankModel to re-rank the results of a search.
+	Rerank bool
+	// RerankModel is the chat model scoring search results when re-ranking. Empty means OpenAIModel.
+	RerankModel string
+	// HyDE adds a hypothetical snippet of the code searched for to embedding queries, and SubQueries up to 4 rephrasings
+	// of the query. Both are written by ExpandModel, empty meaning OpenAIModel.
+	HyDE        bool
+	SubQueries  int
+	ExpandModel string
+	// Chunkers select the chunker of files by extension, without the dot, and "*" the chunker of other files.
+	// Extensions missing use the default chunkers of package embed.
+	Chunkers      map[string]ChunkerOptions
+	CompletionURL string
+	EmbeddingURL  string
+	AutoMode      bool
+	TruncateLimit int
+	// ContextStrategy names the tzap.ContextStrategy that fits the thread into TruncateLimit. Empty means "newest".
+	ContextStrategy string
+	// MaxToolIterations bounds how many tool calls RequestChatCompletion runs before giving up. 0 means 10.
+	MaxToolIterations int
+	// MaxRetries bounds how often tzapconnect retries a model call failing with a temporary error. 0 means 3, below 0 means none.
+	MaxRetries int
+	// RequestsPerMinute and TokensPerMinute limit the model calls made through tzapconnect. 0 means unlimited.
+	RequestsPerMinute int
+	TokensPerMinute   int
+	MD5Rewrites       bool
+	MD5IncludeList    []string
+	EnableLogs        bool
+	LoggerOutput      string
+	// UsageLog is the JSON lines file Tzap.HandleShutdown appends the token usage of the session to. Empty disables it.
+	UsageLog    string
+	Temperature float32
 }
 
 var defaultConfig = Configuration{
 	OpenAIModel:    openai.GPT3Dot5Turbo,
+	EmbedModel:     openai.AdaEmbeddingV2,
+	SearchBackend:  SearchBackendAuto,
 	AutoMode:       false,
 	TruncateLimit:  0,
 	MD5Rewrites:    false,
@@ -52,17 +131,46 @@ func withDefaults(userConfig Configuration) Configuration {
 	if userConfig.OpenAIModel == "" {
 		userConfig.OpenAIModel = defaults.OpenAIModel
 	}
+	if userConfig.EmbedModel == "" {
+		userConfig.EmbedModel = defaults.EmbedModel
+	}
+	if userConfig.SearchBackend == "" {
+		userConfig.SearchBackend = defaults.SearchBackend
+	}
 	if userConfig.MD5IncludeList == nil {
 		userConfig.MD5IncludeList = defaults.MD5IncludeList
 	}
 	return Configuration{
-		OpenAIModel:    userConfig.OpenAIModel,
-		AutoMode:       userConfig.AutoMode || defaults.AutoMode,
-		TruncateLimit:  userConfig.TruncateLimit,
-		MD5Rewrites:    userConfig.MD5Rewrites || defaults.MD5Rewrites,
-		MD5IncludeList: userConfig.MD5IncludeList,
-		EnableLogs:     userConfig.EnableLogs || defaults.EnableLogs,
-		LoggerOutput:   userConfig.LoggerOutput,
-		Temperature:    userConfig.Temperature,
+		OpenAIModel:       userConfig.OpenAIModel,
+		EmbedModel:        userConfig.EmbedModel,
+		EmbedDimensions:   userConfig.EmbedDimensions,
+		EmbedWorkers:      userConfig.EmbedWorkers,
+		EmbedBatchTokens:  userConfig.EmbedBatchTokens,
+		SearchBackend:     userConfig.SearchBackend,
+		HybridWeight:      userConfig.HybridWeight,
+		ExactSearch:       userConfig.ExactSearch,
+		Diversity:         userConfig.Diversity,
+		MaxChunksPerFile:  userConfig.MaxChunksPerFile,
+		Rerank:            userConfig.Rerank,
+		RerankModel:       userConfig.RerankModel,
+		HyDE:              userConfig.HyDE,
+		SubQueries:        userConfig.SubQueries,
+		ExpandModel:       userConfig.ExpandModel,
+		Chunkers:          userConfig.Chunkers,
+		CompletionURL:     userConfig.CompletionURL,
+		EmbeddingURL:      userConfig.EmbeddingURL,
+		AutoMode:          userConfig.AutoMode || defaults.AutoMode,
+		TruncateLimit:     userConfig.TruncateLimit,
+		ContextStrategy:   userConfig.ContextStrategy,
+		MaxToolIterations: userConfig.MaxToolIterations,
+		MaxRetries:        userConfig.MaxRetries,
+		RequestsPerMinute: userConfig.RequestsPerMinute,
+		TokensPerMinute:   userConfig.TokensPerMinute,
+		MD5Rewrites:       userConfig.MD5Rewrites || defaults.MD5Rewrites,
+		MD5IncludeList:    userConfig.MD5IncludeList,
+		EnableLogs:        userConfig.EnableLogs || defaults.EnableLogs,
+		LoggerOutput:      userConfig.LoggerOutput,
+		UsageLog:          userConfig.UsageLog,
+		Temperature:       userConfig.Temperature,
 	}
 }
@@ -0,0 +1,89 @@
+package openaiconnector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+type retryAfterKey struct{}
+
+// retryAfterHint receives the Retry-After header of the response to a request made with its context.
+// go-openai drops the response headers, so retryAfterTransport hands it over this way.
+type retryAfterHint struct {
+	lock  sync.Mutex
+	value time.Duration
+}
+
+func withRetryAfterHint(ctx context.Context) (context.Context, *retryAfterHint) {
+	hint := &retryAfterHint{}
+	return context.WithValue(ctx, retryAfterKey{}, hint), hint
+}
+
+func (h *retryAfterHint) get() time.Duration {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.value
+}
+
+type retryAfterTransport struct {
+	http.RoundTripper
+}
+
+func (t retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.RoundTripper.RoundTrip(req)
+	if err != nil {
+		return res, err
+	}
+	hint, ok := req.Context().Value(retryAfterKey{}).(*retryAfterHint)
+	if !ok {
+		return res, err
+	}
+	if retryAfter := parseRetryAfter(res.Header.Get("Retry-After")); retryAfter > 0 {
+		hint.lock.Lock()
+		hint.value = retryAfter
+		hint.lock.Unlock()
+	}
+	return res, err
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(header, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if date, err := http.ParseTime(header); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
+
+// apiError converts go-openai errors to *types.APIError so retry middleware can handle them.
+func apiError(err error, hint *retryAfterHint) error {
+	apiErr := &openai.APIError{}
+	if errors.As(err, &apiErr) {
+		return newAPIError(apiErr.HTTPStatusCode, hint.get(), err)
+	}
+	requestErr := &openai.RequestError{}
+	if errors.As(err, &requestErr) {
+		return newAPIError(requestErr.HTTPStatusCode, hint.get(), err)
+	}
+	return err
+}
+
+func newAPIError(statusCode int, retryAfter time.Duration, err error) error {
+	if statusCode == http.StatusUnauthorized {
+		err = fmt.Errorf("invalid openai key. Please check your key and try again. %w", err)
+	}
+	return &types.APIError{StatusCode: statusCode, RetryAfter: retryAfter, Err: err}
+}
@@ -1,83 +1,131 @@
 package openaiconnector
 
 import (
+	"bytes"
 	"context"
-	"errors"
-	"time"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
 
 	"github.com/sashabaranov/go-openai"
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/models"
+	"github.com/tzapio/tzap/pkg/types"
 )
 
-const maxRequestsPerMin = 7
+// embeddingClient calls the embeddings endpoint directly, as go-openai only knows the ada models
+// and has no dimensions parameter.
+type embeddingClient struct {
+	baseURL string
+	apikey  string
+	client  *http.Client
+}
+
+func newEmbeddingClient(baseurl, apikey string) *embeddingClient {
+	if baseurl == "" {
+		baseurl = openai.DefaultConfig(apikey).BaseURL
+	}
+	return &embeddingClient{baseURL: strings.TrimSuffix(baseurl, "/"), apikey: apikey, client: &http.Client{}}
+}
 
-type rateLimiter struct {
-	lastRequest         time.Time
-	requestsInPastCount int
+type embeddingRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
 }
 
-func newRateLimiter() *rateLimiter {
-	return &rateLimiter{lastRequest: time.Now(), requestsInPastCount: 0}
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
 }
-func (r *rateLimiter) canMakeRequest() bool {
-	if r.requestsInPastCount <= maxRequestsPerMin {
-		tl.Logger.Println("rateLimiter - Fetched", r.requestsInPastCount, maxRequestsPerMin, time.Since(r.lastRequest).Seconds())
-		r.requestsInPastCount = r.requestsInPastCount + 1
-		return true
+
+func (c *embeddingClient) createEmbeddings(ctx context.Context, request embeddingRequest) (embeddingResponse, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return embeddingResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return embeddingResponse{}, err
 	}
-	if time.Since(r.lastRequest) >= time.Minute {
-		tl.Logger.Println("rateLimiter - Reset Fetch", r.requestsInPastCount, maxRequestsPerMin, time.Since(r.lastRequest).Seconds())
-		r.requestsInPastCount = 1
-		r.lastRequest = time.Now()
-		return true
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.apikey)
+	res, err := c.client.Do(req)
+	if err != nil {
+		return embeddingResponse{}, err
 	}
-	tl.Logger.Println("rateLimiter - Throttled", r.requestsInPastCount, maxRequestsPerMin, time.Since(r.lastRequest).Seconds())
-	return false
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return embeddingResponse{}, err
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		retryAfter := parseRetryAfter(res.Header.Get("Retry-After"))
+		var errorResponse openai.ErrorResponse
+		if json.Unmarshal(data, &errorResponse) != nil || errorResponse.Error == nil {
+			return embeddingResponse{}, newAPIError(res.StatusCode, retryAfter, fmt.Errorf("status %d: %s", res.StatusCode, data))
+		}
+		errorResponse.Error.HTTPStatusCode = res.StatusCode
+		return embeddingResponse{}, newAPIError(res.StatusCode, retryAfter, errorResponse.Error)
+	}
+	var response embeddingResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return embeddingResponse{}, fmt.Errorf("error decoding embeddings: %w", err)
+	}
+	return response, nil
 }
 
-var rl *rateLimiter = newRateLimiter()
-
-func (ot *OpenaiTgenerator) FetchEmbedding(ctx context.Context, content ...string) ([][1536]float32, error) {
+// FetchEmbedding fetches one embedding per content string with the EmbedModel of the configuration, shortened to
+// EmbedDimensions when set. Failed requests are returned as *types.APIError; retrying and rate limiting are left
+// to middleware such as tzapconnect.Retry and tzapconnect.RateLimit.
+func (ot *OpenaiTgenerator) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
 	tl.Logger.Println("Fetching embeddings for", len(content), "strings")
-	request := openai.EmbeddingRequest{
-		Model: openai.AdaEmbeddingV2,
-		Input: content,
+	conf := config.FromContext(ctx)
+	model, err := embeddingModel(conf.EmbedModel, conf.EmbedDimensions)
+	if err != nil {
+		return nil, err
 	}
-	retries := 3
-	for i := 0; i < retries; i++ {
-		for {
-			if !rl.canMakeRequest() {
-				time.Sleep(time.Second)
-				continue
-			} else {
-				break
-			}
-		}
-		response, err := ot.embeddingClient.CreateEmbeddings(ctx, request)
-		if err != nil {
-			e := &openai.APIError{}
-			if errors.As(err, &e) {
-				switch e.HTTPStatusCode {
-				case 401:
-					panic("invalid open ai api key")
-				case 429:
-					println(err.Error())
-				case 500:
-					// openai server error (retry)
-				default:
-					println("unknown error", e.HTTPStatusCode, err.Error())
-					return nil, errors.New("embedding failed")
-				}
-			}
-			println("Fetching embedding failed. Retry in 2 seconds.", err.Error())
-			time.Sleep(2 * time.Second)
-			continue
-		}
-		embeddings := [][1536]float32{}
-		for _, embedding := range response.Data {
-			embeddings = append(embeddings, [1536]float32(embedding.Embedding))
+	request := embeddingRequest{Model: model.ID, Input: content}
+	if model.Capabilities.Dimensions {
+		request.Dimensions = conf.EmbedDimensions
+	}
+	response, err := ot.embeddingClient.createEmbeddings(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %w", err)
+	}
+	types.ReportUsage(ctx, types.NewUsage("FetchEmbedding", model.ID, response.Usage.PromptTokens, 0))
+	embeddings := make([][]float32, len(content))
+	for _, embedding := range response.Data {
+		if embedding.Index < 0 || embedding.Index >= len(embeddings) {
+			return nil, fmt.Errorf("embedding failed: unexpected index %d", embedding.Index)
 		}
-		return embeddings, nil
+		embeddings[embedding.Index] = embedding.Embedding
+	}
+	return embeddings, nil
+}
+
+// embeddingModel resolves name in the models registry to an openai embedding model able to return vectors of dimensions.
+func embeddingModel(name string, dimensions int) (models.Model, error) {
+	model, err := models.Resolve(name, models.KindEmbedding)
+	if err != nil {
+		return models.Model{}, err
+	}
+	if model.Provider != "openai" {
+		return models.Model{}, fmt.Errorf("embedding model %s is not supported by the openai connector", model.ID)
+	}
+	if dimensions > 0 && dimensions != model.Dimensions && !model.Capabilities.Dimensions {
+		return models.Model{}, fmt.Errorf("embedding model %s only returns vectors of %d dimensions", model.ID, model.Dimensions)
+	}
+	if dimensions > model.Dimensions {
+		return models.Model{}, fmt.Errorf("embedding model %s returns at most %d dimensions", model.ID, model.Dimensions)
 	}
-	return nil, errors.New("embedding failed")
+	return model, nil
 }
@@ -2,9 +2,11 @@ package openaiconnector
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"time"
 
@@ -13,119 +15,214 @@ import (
 	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/connectors/openaiconnector/output"
 	"github.com/tzapio/tzap/pkg/connectors/openaiconnector/tokenizer"
+	"github.com/tzapio/tzap/pkg/models"
 
 	"github.com/tzapio/tzap/pkg/types"
 )
 
 func InitiateOpenaiClient(apikey string, conf config.Configuration) *OpenaiTgenerator {
 	tl.Logger.Println("Initiating OpenAI Client")
-	tokenizer := tokenizer.NewTokenizer()
+	tokenizer := newTokenizer(conf.OpenAIModel)
 
-	return &OpenaiTgenerator{completionClient: getClient(conf.CompletionURL, apikey), embeddingClient: getClient(conf.EmbeddingURL, apikey), Tokenizer: tokenizer}
+	return &OpenaiTgenerator{completionClient: getClient(conf.CompletionURL, apikey), embeddingClient: newEmbeddingClient(conf.EmbeddingURL, apikey), Tokenizer: tokenizer}
 }
 
-func getClient(baseurl, apikey string) *openai.Client {
-	if baseurl == "" {
-		return openai.NewClient(apikey)
+// newTokenizer returns the tokenizer of the encoding of model in the models registry, cl100k_base for unknown models.
+func newTokenizer(model string) *tokenizer.Tokenizer {
+	encoding := models.EncodingCL100kBase
+	if registered, ok := models.Get(model); ok && registered.Encoding != "" {
+		encoding = registered.Encoding
+	}
+	t, err := tokenizer.NewTokenizerForEncoding(encoding)
+	if err != nil {
+		tl.Logger.Println("Falling back to", models.EncodingCL100kBase, "for", model+":", err)
+		return tokenizer.NewTokenizer()
 	}
+	return t
+}
 
+func getClient(baseurl, apikey string) *openai.Client {
 	config := openai.DefaultConfig(apikey)
-	config.BaseURL = baseurl
-	client := openai.NewClientWithConfig(config)
-
-	return client
+	if baseurl != "" {
+		config.BaseURL = baseurl
+	}
+	config.HTTPClient = &http.Client{Transport: retryAfterTransport{http.DefaultTransport}}
+	return openai.NewClientWithConfig(config)
 }
 func (ot *OpenaiTgenerator) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
 	config := config.FromContext(ctx)
-	content, err := ot.fetchChatResponse(ctx, config.OpenAIModel, stream, messages)
+	response, err := ot.fetchChatResponse(ctx, config.OpenAIModel, stream, messages, nil)
+	if err != nil {
+		return "", fmt.Errorf("error generating chat prompt result: %w", err)
+	}
+	return response.Content, nil
+}
+
+// GenerateChatStream streams the completion and calls onDelta with each part as it arrives.
+// An error returned by onDelta cancels the request and is returned.
+func (ot *OpenaiTgenerator) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	config := config.FromContext(ctx)
+	response, err := ot.streamCompletion(ctx, newChatCompletionRequest(ctx, config.OpenAIModel, messages, nil), onDelta)
+	if err != nil {
+		return "", fmt.Errorf("error generating chat prompt result: %w", err)
+	}
+	return response.Content, nil
+}
+
+// GenerateChatWithTools offers tools to the model as functions and returns either its answer or the function it calls.
+func (ot *OpenaiTgenerator) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	config := config.FromContext(ctx)
+	response, err := ot.fetchChatResponse(ctx, config.OpenAIModel, stream, messages, tools)
+	if err != nil {
+		return types.ChatResponse{}, fmt.Errorf("error generating chat prompt result: %w", err)
+	}
+	return response, nil
+}
+
+// jsonFunctionName is the function GenerateChatJSON makes the model call to answer with JSON.
+const jsonFunctionName = "respond"
+
+// GenerateChatJSON makes the model answer by calling a function whose parameters are schema, and returns the arguments.
+func (ot *OpenaiTgenerator) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	config := config.FromContext(ctx)
+	request := newChatCompletionRequest(ctx, config.OpenAIModel, messages, []types.ToolDefinition{{
+		Name:        jsonFunctionName,
+		Description: "Answer with JSON.",
+		Parameters:  schema,
+	}})
+	request.FunctionCall = map[string]string{"name": jsonFunctionName}
+	response, err := ot.completeChat(ctx, request, stream)
 	if err != nil {
-		return "", fmt.Errorf("error generating chat prompt result: %v", err)
+		return "", fmt.Errorf("error generating chat prompt result: %w", err)
+	}
+	if response.ToolCall == nil {
+		return response.Content, nil
 	}
-	return content, nil
+	return response.ToolCall.Arguments, nil
 }
 
 // fetchChatResponse requests openai-chat completion for the given Tzap and returns the modified content.
-func (ot *OpenaiTgenerator) fetchChatResponse(ctx context.Context, gptmodel string, stream bool, messages []types.Message) (string, error) {
-	// Create a context with a timeout
+func (ot *OpenaiTgenerator) fetchChatResponse(ctx context.Context, gptmodel string, stream bool, messages []types.Message, tools []types.ToolDefinition) (types.ChatResponse, error) {
+	return ot.completeChat(ctx, newChatCompletionRequest(ctx, gptmodel, messages, tools), stream)
+}
+
+func newChatCompletionRequest(ctx context.Context, gptmodel string, messages []types.Message, tools []types.ToolDefinition) openai.ChatCompletionRequest {
 	config := config.FromContext(ctx)
-	request := openai.ChatCompletionRequest{
+	return openai.ChatCompletionRequest{
 		Model:       gptmodel,
 		Messages:    output.GetOpenAICompletionMessage(messages),
 		Temperature: config.Temperature,
+		Functions:   output.GetOpenAIFunctionDefinitions(tools),
 	}
-	var content string
+}
+
+func (ot *OpenaiTgenerator) completeChat(ctx context.Context, request openai.ChatCompletionRequest, stream bool) (types.ChatResponse, error) {
+	var response types.ChatResponse
 	if stream {
-		streamContent, err := ot.streamCompletion(ctx, request)
+		streamResponse, err := ot.streamCompletion(ctx, request, nil)
 		if err != nil {
-			return "", fmt.Errorf("chatcompletion error: %v", err)
+			return types.ChatResponse{}, fmt.Errorf("chatcompletion error: %w", err)
 		}
-		content = streamContent
+		response = streamResponse
 	} else {
-		responseContent, err := ot.createChatCompletion(ctx, request)
+		completionResponse, err := ot.createChatCompletion(ctx, request)
 		if err != nil {
-			return "", fmt.Errorf("chatcompletion error: %v", err)
+			return types.ChatResponse{}, fmt.Errorf("chatcompletion error: %w", err)
 		}
-		content = responseContent
+		response = completionResponse
 	}
-	return content, nil
+	return response, nil
 }
 
-func (ot *OpenaiTgenerator) streamCompletion(ctx context.Context, request openai.ChatCompletionRequest) (string, error) {
+// streamCompletion consumes a stream completion, passing the content to onDelta when it is set.
+// Failed requests are returned as *types.APIError; retrying is left to middleware such as tzapconnect.Retry.
+func (ot *OpenaiTgenerator) streamCompletion(ctx context.Context, request openai.ChatCompletionRequest, onDelta func(delta string) error) (types.ChatResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
 	defer cancel()
-	// Create a stream completion
-	retries := 3
-	for i := 0; i < retries; i++ {
-		s, err := ot.completionClient.CreateChatCompletionStream(ctx, request)
+	ctx, hint := withRetryAfterHint(ctx)
+	s, err := ot.completionClient.CreateChatCompletionStream(ctx, request)
+	if err != nil {
+		return types.ChatResponse{}, fmt.Errorf("stream error: %w", apiError(err, hint))
+	}
+	defer s.Close()
+
+	var resultBuilder strings.Builder
+	var toolCall *types.ToolCall
+	// Consume the stream completion
+	for {
+		// Read the next token from the stream
+		response, err := s.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
 		if err != nil {
-			e := &openai.APIError{}
-			if errors.As(err, &e) {
-				switch e.HTTPStatusCode {
-				case 401:
-					panic(fmt.Errorf("invalid openai key. Please check your key and try again. %v", err))
-				case 429:
-					// rate limiting or engine overload (wait and retry)
-					continue
-				case 500:
-					// openai server error (retry)
-					continue
-				default:
-					return "", fmt.Errorf("stream error: %v", err)
-				}
-			}
+			return types.ChatResponse{Content: resultBuilder.String()}, fmt.Errorf("stream error: %w", apiError(err, hint))
 		}
 
-		var resultBuilder strings.Builder
-		// Consume the stream completion
-
-		for {
-			// Read the next token from the stream
-			response, err := s.Recv()
-			if errors.Is(err, io.EOF) {
-				//	fmt.Println("\nStream finished")
-				//	fmt.Printf("%+v\n", response)
-				break
+		delta := response.Choices[0].Delta
+		if delta.FunctionCall != nil {
+			// Function calls arrive in parts: the name first, then the arguments a few characters at a time.
+			if toolCall == nil {
+				toolCall = &types.ToolCall{}
 			}
-			if err != nil {
-				return resultBuilder.String(), fmt.Errorf("stream error: %v", err)
+			toolCall.Name += delta.FunctionCall.Name
+			toolCall.Arguments += delta.FunctionCall.Arguments
+			continue
+		}
+		token := delta.Content
+		resultBuilder.WriteString(token)
+		if onDelta != nil && token != "" {
+			if err := onDelta(token); err != nil {
+				// Cancelling the context aborts the upstream request.
+				cancel()
+				ot.reportStreamUsage(ctx, request, resultBuilder.String(), toolCall)
+				return types.ChatResponse{Content: resultBuilder.String()}, fmt.Errorf("stream callback: %w", err)
 			}
+		}
+	}
+	ot.reportStreamUsage(ctx, request, resultBuilder.String(), toolCall)
+	return types.ChatResponse{Content: resultBuilder.String(), ToolCall: toolCall}, nil
+}
 
-			token := response.Choices[0].Delta.Content
-			print(token)
-			resultBuilder.WriteString(token)
+// reportStreamUsage reports the usage of a streamed completion counted with the tokenizer, since streams do not report it.
+func (ot *OpenaiTgenerator) reportStreamUsage(ctx context.Context, request openai.ChatCompletionRequest, content string, toolCall *types.ToolCall) {
+	prompt := 0
+	for _, message := range request.Messages {
+		prompt += ot.countTokens(message.Content)
+		if message.FunctionCall != nil {
+			prompt += ot.countTokens(message.FunctionCall.Arguments)
 		}
-		response := resultBuilder.String()
-		return response, nil
 	}
-	return "", errors.New("stream error: retries exceeded")
+	completion := ot.countTokens(content)
+	if toolCall != nil {
+		completion += ot.countTokens(toolCall.Arguments)
+	}
+	usage := types.NewUsage("GenerateChat", request.Model, prompt, completion)
+	usage.Estimated = true
+	types.ReportUsage(ctx, usage)
 }
 
-func (ot *OpenaiTgenerator) createChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (string, error) {
+func (ot *OpenaiTgenerator) countTokens(content string) int {
+	count, err := ot.Tokenizer.CountTokens(content)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+func (ot *OpenaiTgenerator) createChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (types.ChatResponse, error) {
 	ctx, cancel := context.WithTimeout(ctx, 15*time.Minute)
 	defer cancel()
+	ctx, hint := withRetryAfterHint(ctx)
 	response, err := ot.completionClient.CreateChatCompletion(ctx, request)
 	if err != nil {
-		return "", fmt.Errorf("chatcompletion error: %v", err)
+		return types.ChatResponse{}, fmt.Errorf("chatcompletion error: %w", apiError(err, hint))
+	}
+	types.ReportUsage(ctx, types.NewUsage("GenerateChat", request.Model, response.Usage.PromptTokens, response.Usage.CompletionTokens))
+	message := response.Choices[0].Message
+	chatResponse := types.ChatResponse{Content: message.Content}
+	if message.FunctionCall != nil {
+		chatResponse.ToolCall = &types.ToolCall{Name: message.FunctionCall.Name, Arguments: message.FunctionCall.Arguments}
 	}
-	return response.Choices[0].Message.Content, nil
+	return chatResponse, nil
 }
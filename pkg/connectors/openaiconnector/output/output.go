@@ -11,7 +11,29 @@ func GetOpenAICompletionMessage(messages []types.Message) []openai.ChatCompletio
 		requestMessages[i] = openai.ChatCompletionMessage{
 			Role:    message.Role,
 			Content: message.Content,
+			Name:    message.Name,
+		}
+		if message.ToolCall != nil {
+			requestMessages[i].FunctionCall = &openai.FunctionCall{
+				Name:      message.ToolCall.Name,
+				Arguments: message.ToolCall.Arguments,
+			}
 		}
 	}
 	return requestMessages
 }
+
+func GetOpenAIFunctionDefinitions(tools []types.ToolDefinition) []openai.FunctionDefinition {
+	if len(tools) == 0 {
+		return nil
+	}
+	definitions := make([]openai.FunctionDefinition, len(tools))
+	for i, tool := range tools {
+		definitions[i] = openai.FunctionDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		}
+	}
+	return definitions
+}
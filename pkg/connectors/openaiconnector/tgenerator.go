@@ -12,7 +12,7 @@ type openaiconnectorKey struct{}
 
 type OpenaiTgenerator struct {
 	completionClient *openai.Client
-	embeddingClient  *openai.Client
+	embeddingClient  *embeddingClient
 	*tokenizer.Tokenizer
 }
 
@@ -2,11 +2,13 @@ package tokenizer
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 
 	"github.com/tzapio/tokenizer/codec"
 	enc "github.com/tzapio/tokenizer/codec/cl100k_base"
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/util/singlewait"
 )
 
@@ -31,6 +33,15 @@ func NewTokenizer() *Tokenizer {
 
 	return t
 }
+
+// NewTokenizerForEncoding returns the tokenizer of a models registry encoding.
+func NewTokenizerForEncoding(encoding string) (*Tokenizer, error) {
+	if encoding != models.EncodingCL100kBase {
+		return nil, fmt.Errorf("unsupported tokenizer encoding %q", encoding)
+	}
+	return NewTokenizer(), nil
+}
+
 func (t *Tokenizer) CountTokens(content string) (int, error) {
 	ids, _, err := t.tokenizer.GetData().Encode(content)
 	if err != nil {
@@ -7,7 +7,7 @@ import (
 	"github.com/tzapio/tzap/pkg/types"
 )
 
-func (idx *RedisembedTgenerator) AddEmbeddingDocument(ctx context.Context, docID string, embedding [1536]float32, metadata types.Metadata) error {
+func (idx *RedisembedTgenerator) AddEmbeddingDocument(ctx context.Context, docID string, embedding []float32, metadata types.Metadata) error {
 	doc := redisearch.NewDocument(docID, 1.0).
 		Set("oaiemb", toBytes(embedding))
 
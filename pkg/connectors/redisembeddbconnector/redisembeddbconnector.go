@@ -11,8 +11,9 @@ type RedisembedTgenerator struct {
 	client *redisearch.Client
 }
 
-func InitiateRedisClient(addr string) (types.TGenerator, error) {
-	client, err := NewEmbeddingIndex(addr, "files", "oaiemb", 1536)
+// InitiateRedisClient connects to the index of vectors with the given dimensions, creating it if needed.
+func InitiateRedisClient(addr string, dimensions int) (types.TGenerator, error) {
+	client, err := NewEmbeddingIndex(addr, "files", "oaiemb", dimensions)
 	if err != nil {
 		println("Cannot connect to " + addr + " - redis disabled")
 		return nil, nil
@@ -31,7 +32,7 @@ func NewEmbeddingIndex(addr string, indexName, fieldName string, dimensions int)
 			Algorithm: redisearch.HNSW,
 			Attributes: map[string]interface{}{
 				"TYPE":            "FLOAT32",
-				"DIM":             1536,
+				"DIM":             dimensions,
 				"DISTANCE_METRIC": "L2",
 			},
 		}),
@@ -0,0 +1,63 @@
+package embed
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// BlockChunker cuts source of languages without a parser here along their top-level blocks, which start at an
+// unindented line following a blank line. Blocks smaller than Size tokens are packed together, blocks larger than
+// 4*Size are split at their blank lines. A block is named after the first definition it starts with, such as a
+// def, class or function.
+type BlockChunker struct {
+	Size int
+}
+
+// definitionRegexp matches the definition keywords of common languages, followed by the defined name.
+var definitionRegexp = regexp.MustCompile(`^(?:[\w@]+\s+)*?(?:def|class|function|func|fn|interface|struct|enum|trait|impl|module|type|object)\s+([A-Za-z_$][\w$]*)`)
+
+func (c BlockChunker) Version() string {
+	return fmt.Sprintf("blocks1-%d", c.Size)
+}
+
+func (c BlockChunker) Chunk(t *tzap.Tzap, filename string, content string) ([]*types.Vector, error) {
+	spans := []span{}
+	start := 0
+	for _, end := range append(paragraphCuts(content, span{start: 0, end: len(content)}, true), len(content)) {
+		if end <= start {
+			continue
+		}
+		block, err := countSpan(t, content, span{start: start, end: end, symbol: blockSymbol(content[start:end])})
+		if err != nil {
+			return nil, err
+		}
+		start = end
+		if block.tokens <= 4*c.Size {
+			spans = append(spans, block)
+			continue
+		}
+		split, err := splitSpan(t, content, block, paragraphCuts(content, block, false), 4*c.Size)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, split...)
+	}
+	return spanVectors(filename, content, c.Version(), packSpans(spans, c.Size)), nil
+}
+
+// blockSymbol returns the name of the first definition on an unindented line of block.
+func blockSymbol(block string) string {
+	for _, line := range strings.Split(block, "\n") {
+		if startsIndented(line) {
+			continue
+		}
+		if match := definitionRegexp.FindStringSubmatch(line); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
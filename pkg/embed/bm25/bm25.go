@@ -0,0 +1,109 @@
+// Package bm25 is a lexical search backend: an Okapi BM25 index over the same chunks the embedding index
+// is built from. It makes no model calls, so search works without an embedding API.
+package bm25
+
+import (
+	"math"
+	"sort"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Parameters of the BM25 ranking: k1 saturates the term frequency and b normalizes for the chunk length.
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// NewDocument returns the lexical document of a chunk. Its terms include the file name heading the chunk.
+func NewDocument(metadata types.Metadata) types.LexicalDocument {
+	tokens := Tokenize(metadata.SplitPart)
+	terms := map[string]int{}
+	for _, token := range tokens {
+		terms[token]++
+	}
+	return types.LexicalDocument{Metadata: metadata, Terms: terms, Length: len(tokens)}
+}
+
+// Index is an inverted index of lexical documents.
+type Index struct {
+	documents []types.LexicalDocument
+	postings  map[string][]int
+	avgLength float64
+}
+
+// NewIndex builds the index of documents.
+func NewIndex(documents []types.LexicalDocument) *Index {
+	idx := &Index{documents: documents, postings: map[string][]int{}}
+	totalLength := 0
+	for i, document := range documents {
+		totalLength += document.Length
+		for term := range document.Terms {
+			idx.postings[term] = append(idx.postings[term], i)
+		}
+	}
+	if len(documents) > 0 {
+		idx.avgLength = float64(totalLength) / float64(len(documents))
+	}
+	return idx
+}
+
+// Search returns the k documents selected by filter scoring highest for query, all matching documents for k below 0.
+// The Similarity of the results is their BM25 score. The term statistics are those of all documents.
+func (idx *Index) Search(query string, filter types.MetadataFilter, k int) types.SearchResults {
+	var selected []bool
+	if !filter.IsEmpty() {
+		selected = make([]bool, len(idx.documents))
+		for i, document := range idx.documents {
+			selected[i] = filter.Match(document.Metadata.Filename)
+		}
+	}
+	scores := map[int]float64{}
+	seen := map[string]bool{}
+	n := float64(len(idx.documents))
+	for _, term := range Tokenize(query) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+		postings := idx.postings[term]
+		if len(postings) == 0 {
+			continue
+		}
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, i := range postings {
+			if selected != nil && !selected[i] {
+				continue
+			}
+			document := idx.documents[i]
+			tf := float64(document.Terms[term])
+			norm := 1 - b + b*float64(document.Length)/idx.avgLength
+			scores[i] += idf * tf * (k1 + 1) / (tf + k1*norm)
+		}
+	}
+
+	matches := make([]int, 0, len(scores))
+	for i := range scores {
+		matches = append(matches, i)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if scores[matches[i]] != scores[matches[j]] {
+			return scores[matches[i]] > scores[matches[j]]
+		}
+		return idx.documents[matches[i]].Metadata.ID < idx.documents[matches[j]].Metadata.ID
+	})
+	if k > -1 && len(matches) > k {
+		matches = matches[:k]
+	}
+
+	results := types.SearchResults{}
+	for _, i := range matches {
+		metadata := idx.documents[i].Metadata
+		results.Results = append(results.Results, types.SearchResult{
+			Vector:     types.Vector{ID: metadata.ID, Metadata: metadata},
+			Similarity: float32(scores[i]),
+		})
+	}
+	return results
+}
@@ -0,0 +1,85 @@
+package bm25_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed/bm25"
+	"github.com/tzapio/tzap/pkg/embed/localdb"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{"parseHTTPRequest", []string{"parsehttprequest", "parse", "http", "request"}},
+		{"load_api_key()", []string{"load_api_key", "load", "api", "key"}},
+		{"func (t *Tzap) AddTzap(x int)", []string{"func", "tzap", "addtzap", "add", "tzap", "int"}},
+		{"utf8 Decode2D", []string{"utf8", "decode2d", "decode2"}},
+		{"Where is the key?", []string{"key"}},
+	}
+	for _, test := range tests {
+		if got := bm25.Tokenize(test.input); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func chunk(id, filename, content string) *types.Vector {
+	return &types.Vector{ID: id, Metadata: types.Metadata{ID: id, Filename: filename, SplitPart: "####embedding from file: " + filename + "\n" + content}}
+}
+
+func TestSearch_givenQuery_expectBestMatchFirst(t *testing.T) {
+	documents := []types.LexicalDocument{
+		bm25.NewDocument(chunk("a", "apikey.go", "func LoadOpenAIKey() (string, error) { return loadAPIKey(\"OPENAI_APIKEY\") }").Metadata),
+		bm25.NewDocument(chunk("b", "chat.go", "func GenerateChat(messages []Message) string").Metadata),
+		bm25.NewDocument(chunk("c", "readme.md", "Set the key in the environment").Metadata),
+	}
+	results := bm25.NewIndex(documents).Search("where is the api key loaded", types.MetadataFilter{}, 2)
+	if len(results.Results) != 2 {
+		t.Fatalf("expected two results, got %+v", results.Results)
+	}
+	if results.Results[0].Vector.ID != "a" || results.Results[1].Vector.ID != "c" {
+		t.Errorf("expected a then c, got %s then %s", results.Results[0].Vector.ID, results.Results[1].Vector.ID)
+	}
+	if results.Results[0].Similarity <= results.Results[1].Similarity {
+		t.Errorf("expected descending scores, got %f and %f", results.Results[0].Similarity, results.Results[1].Similarity)
+	}
+	if results := bm25.NewIndex(documents).Search("nothing matches", types.MetadataFilter{}, -1); len(results.Results) != 0 {
+		t.Errorf("expected no results, got %+v", results.Results)
+	}
+}
+
+func TestUpdate_givenChangedAndDeletedFiles_expectOldChunksRemoved(t *testing.T) {
+	collection, err := localdb.NewFileDB[types.LexicalDocument]("@MEMORY/" + t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := bm25.Update(collection, []*types.Vector{
+		chunk("a-0", "a.go", "old content"),
+		chunk("a-1", "a.go", "more old content"),
+		chunk("b-0", "b.go", "kept content"),
+		chunk("c-0", "c.go", "deleted content"),
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// a.go changed to a single chunk, b.go is unchanged and c.go was deleted.
+	added, removed, err := bm25.Update(collection, []*types.Vector{chunk("a-0", "a.go", "new content")}, map[string]int64{"b.go": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 1 || removed != 2 {
+		t.Errorf("expected 1 added and 2 removed, got %d and %d", added, removed)
+	}
+	results := bm25.Search(collection, "content", types.MetadataFilter{}, -1)
+	var ids []string
+	for _, result := range results.Results {
+		ids = append(ids, result.Vector.ID)
+	}
+	if len(ids) != 2 || !(ids[0] == "a-0" && ids[1] == "b-0" || ids[0] == "b-0" && ids[1] == "a-0") {
+		t.Errorf("expected a-0 and b-0 to remain, got %v", ids)
+	}
+}
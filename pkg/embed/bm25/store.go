@@ -0,0 +1,39 @@
+package bm25
+
+import (
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Update stores the documents of the chunks in vectors and removes the documents of files that are neither
+// in vectors nor in unchangedFiles, such as deleted files and old chunks of changed files.
+// It returns the number of documents written and removed.
+func Update(collection types.DBCollectionInterface[types.LexicalDocument], vectors []*types.Vector, unchangedFiles map[string]int64) (int, int, error) {
+	current := map[string]struct{}{}
+	var pairs []types.KeyValue[types.LexicalDocument]
+	for _, vector := range vectors {
+		current[vector.ID] = struct{}{}
+		pairs = append(pairs, types.KeyValue[types.LexicalDocument]{Key: vector.ID, Value: NewDocument(vector.Metadata)})
+	}
+	removed := 0
+	for _, stored := range collection.GetAll() {
+		if _, unchanged := unchangedFiles[stored.Value.Metadata.Filename]; unchanged {
+			continue
+		}
+		if _, exists := current[stored.Key]; !exists {
+			pairs = append(pairs, types.KeyValue[types.LexicalDocument]{Key: stored.Key})
+			removed++
+		}
+	}
+	written, err := collection.BatchSet(pairs)
+	return written - removed, removed, err
+}
+
+// Search searches the documents of collection. See Index.Search.
+func Search(collection types.DBCollectionInterface[types.LexicalDocument], query string, filter types.MetadataFilter, k int) types.SearchResults {
+	stored := collection.GetAll()
+	documents := make([]types.LexicalDocument, len(stored))
+	for i, kv := range stored {
+		documents[i] = kv.Value
+	}
+	return NewIndex(documents).Search(query, filter, k)
+}
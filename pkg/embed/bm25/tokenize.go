@@ -0,0 +1,59 @@
+package bm25
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopWords are common English words dropped from queries and documents.
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "be": true, "by": true, "do": true, "does": true,
+	"for": true, "from": true, "how": true, "in": true, "is": true, "it": true, "of": true, "on": true, "or": true,
+	"that": true, "the": true, "this": true, "to": true, "what": true, "where": true, "which": true, "with": true,
+}
+
+// Tokenize splits text into lowercase terms for the index. Identifiers are split at underscores and
+// camelCase boundaries and also kept whole, so "parseHTTPRequest" gives "parsehttprequest", "parse",
+// "http" and "request". Single characters and stop words are dropped.
+func Tokenize(text string) []string {
+	var terms []string
+	add := func(term string) {
+		if len(term) > 1 && !stopWords[term] {
+			terms = append(terms, term)
+		}
+	}
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}) {
+		parts := splitIdentifier(word)
+		if len(parts) > 1 {
+			add(strings.ToLower(strings.Trim(word, "_")))
+		}
+		for _, part := range parts {
+			add(strings.ToLower(part))
+		}
+	}
+	return terms
+}
+
+// splitIdentifier splits snake_case and camelCase words, keeping acronyms together: "HTTPServer_test" gives "HTTP", "Server", "test".
+func splitIdentifier(word string) []string {
+	var parts []string
+	for _, snake := range strings.Split(word, "_") {
+		runes := []rune(snake)
+		start := 0
+		for i := 1; i < len(runes); i++ {
+			prev, cur := runes[i-1], runes[i]
+			lowerToUpper := (unicode.IsLower(prev) || unicode.IsDigit(prev)) && unicode.IsUpper(cur)
+			acronymEnd := unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if lowerToUpper || acronymEnd {
+				parts = append(parts, string(runes[start:i]))
+				start = i
+			}
+		}
+		if start < len(runes) {
+			parts = append(parts, string(runes[start:]))
+		}
+	}
+	return parts
+}
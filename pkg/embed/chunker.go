@@ -0,0 +1,284 @@
+package embed
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// Chunker cuts the content of a file into the chunks that are embedded and searched.
+type Chunker interface {
+	// Version identifies the strategy and its options. It is part of the ids of the chunks and is stored in their
+	// Metadata.Chunker, so that a file is chunked again when its chunker changes. The legacy chunker has version "".
+	Version() string
+	Chunk(t *tzap.Tzap, filename string, content string) ([]*types.Vector, error)
+}
+
+// defaultChunkers are the chunkers of the extensions the configuration does not set.
+var defaultChunkers = map[string]config.ChunkerOptions{
+	"go":       {Strategy: config.ChunkerGo},
+	"md":       {Strategy: config.ChunkerMarkdown},
+	"markdown": {Strategy: config.ChunkerMarkdown},
+	"*":        {Strategy: config.ChunkerLegacy},
+}
+
+// defaultChunkSize is the Size of chunker options without one.
+const defaultChunkSize = 200
+
+// Chunkers picks the Chunker of a file by its extension.
+type Chunkers struct {
+	byExtension map[string]Chunker
+}
+
+// NewChunkers returns the chunkers of options, which are keyed by extension like config.Configuration.Chunkers,
+// on top of the default chunkers.
+func NewChunkers(options map[string]config.ChunkerOptions) (*Chunkers, error) {
+	c := &Chunkers{byExtension: map[string]Chunker{}}
+	for _, all := range []map[string]config.ChunkerOptions{defaultChunkers, options} {
+		for extension, o := range all {
+			chunker, err := NewChunker(o)
+			if err != nil {
+				return nil, fmt.Errorf("chunker of %q: %w", extension, err)
+			}
+			c.byExtension[strings.ToLower(strings.TrimPrefix(extension, "."))] = chunker
+		}
+	}
+	return c, nil
+}
+
+// NewChunker returns the chunker of options.
+func NewChunker(options config.ChunkerOptions) (Chunker, error) {
+	size := options.Size
+	if size == 0 {
+		size = defaultChunkSize
+	}
+	if size < 0 || options.Overlap < 0 {
+		return nil, fmt.Errorf("size and overlap may not be negative")
+	}
+	if options.Overlap != 0 && options.Strategy != config.ChunkerWindow {
+		return nil, fmt.Errorf("only the %s chunker overlaps chunks", config.ChunkerWindow)
+	}
+	switch options.Strategy {
+	case config.ChunkerLegacy:
+		if options.Size != 0 {
+			return nil, fmt.Errorf("the %s chunker has a fixed size", config.ChunkerLegacy)
+		}
+		return WindowChunker{Size: defaultChunkSize, legacy: true}, nil
+	case config.ChunkerWindow:
+		if options.Overlap >= size {
+			return nil, fmt.Errorf("overlap %d must be smaller than size %d", options.Overlap, size)
+		}
+		return WindowChunker{Size: size, Overlap: options.Overlap}, nil
+	case config.ChunkerGo:
+		return GoChunker{Size: size}, nil
+	case config.ChunkerMarkdown:
+		return MarkdownChunker{Size: size}, nil
+	case config.ChunkerBlocks:
+		return BlockChunker{Size: size}, nil
+	}
+	return nil, fmt.Errorf("unknown strategy %q. Available: %s, %s, %s, %s, %s", options.Strategy,
+		config.ChunkerLegacy, config.ChunkerWindow, config.ChunkerGo, config.ChunkerMarkdown, config.ChunkerBlocks)
+}
+
+// For returns the chunker of filename.
+func (c *Chunkers) For(filename string) Chunker {
+	if chunker, ok := c.byExtension[strings.ToLower(strings.TrimPrefix(path.Ext(filename), "."))]; ok {
+		return chunker
+	}
+	return c.byExtension["*"]
+}
+
+// CheckChunkers moves the files of unchangedFiles that stored, the chunks indexed, shows were chunked by another
+// chunker than the one configured now to changedFiles, reading them from files. Like CheckFileCache it leaves out
+// files it cannot read.
+func (fe *Embedder) CheckChunkers(t *tzap.Tzap, files []types.FileReader, stored []types.Metadata, changedFiles map[string]string, unchangedFiles map[string]int64) error {
+	chunkers, err := NewChunkers(config.FromContext(t.C).Chunkers)
+	if err != nil {
+		return err
+	}
+	stale := map[string]bool{}
+	for _, metadata := range stored {
+		if _, unchanged := unchangedFiles[metadata.Filename]; unchanged && metadata.Chunker != chunkers.For(metadata.Filename).Version() {
+			stale[metadata.Filename] = true
+		}
+	}
+	for _, file := range files {
+		filename := file.FilePath()
+		if !stale[filename] {
+			continue
+		}
+		delete(unchangedFiles, filename)
+		content, err := readFile(file)
+		if err != nil {
+			println(err.Error())
+			continue
+		}
+		tl.Logger.Println("File", filename, "has a new chunker", chunkers.For(filename).Version())
+		changedFiles[filename] = string(content)
+	}
+	return nil
+}
+
+// chunkID returns the id of the chunk [start, end) of filename cut by a chunker of version.
+func chunkID(filename string, version string, start int, end int) string {
+	if version == "" {
+		return fmt.Sprintf("%s-%d-%d", filename, start, end)
+	}
+	return fmt.Sprintf("%s-%s-%d-%d", filename, version, start, end)
+}
+
+// span is the part [start, end) in bytes of a file holding the declaration or section symbol, a method of receiver.
+type span struct {
+	start, end int
+	symbol     string
+	receiver   string
+	tokens     int
+	// split is set on the parts of a declaration or section too large for one chunk.
+	split bool
+}
+
+// countSpan sets the tokens of s.
+func countSpan(t *tzap.Tzap, content string, s span) (span, error) {
+	tokens, err := t.TG.CountTokens(t.C, content[s.start:s.end])
+	s.tokens = tokens
+	return s, err
+}
+
+// splitSpan returns s as parts of at most maxTokens, cutting it at cuts and then at lines, and packing the parts
+// back together up to maxTokens. A single line larger than maxTokens is kept whole.
+func splitSpan(t *tzap.Tzap, content string, s span, cuts []int, maxTokens int) ([]span, error) {
+	if len(cuts) == 0 {
+		cuts = lineCuts(content, s)
+	}
+	if len(cuts) == 0 {
+		return []span{s}, nil
+	}
+	s.split = true
+	parts := []span{}
+	start := s.start
+	for _, end := range append(cuts, s.end) {
+		if end <= start {
+			continue
+		}
+		part := s
+		part.start, part.end = start, end
+		start = end
+		part, err := countSpan(t, content, part)
+		if err != nil {
+			return nil, err
+		}
+		if lines := lineCuts(content, part); part.tokens > maxTokens && len(lines) > 0 {
+			split, err := splitSpan(t, content, part, lines, maxTokens)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, split...)
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	packed := []span{}
+	for _, part := range parts {
+		if last := len(packed) - 1; last >= 0 && packed[last].tokens+part.tokens <= maxTokens {
+			packed[last].end = part.end
+			packed[last].tokens += part.tokens
+			continue
+		}
+		packed = append(packed, part)
+	}
+	return packed, nil
+}
+
+// packSpans joins consecutive spans while they fit in size tokens, naming the joined span after all their symbols.
+// The parts of a split declaration or section are not joined with others.
+func packSpans(spans []span, size int) []span {
+	packed := []span{}
+	for _, s := range spans {
+		last := len(packed) - 1
+		if last >= 0 && !s.split && !packed[last].split && packed[last].tokens+s.tokens <= size {
+			if s.symbol != "" {
+				if packed[last].symbol == "" {
+					packed[last].symbol, packed[last].receiver = s.symbol, s.receiver
+				} else {
+					packed[last].symbol += ", " + s.symbol
+					packed[last].receiver = ""
+				}
+			}
+			packed[last].end = s.end
+			packed[last].tokens += s.tokens
+			continue
+		}
+		packed = append(packed, s)
+	}
+	return packed
+}
+
+// spanVectors returns the chunks of the spans of content, whose Start and End are byte offsets.
+func spanVectors(filename string, content string, version string, spans []span) []*types.Vector {
+	vectors := []*types.Vector{}
+	lineStart := 1
+	offset := 0
+	for _, s := range spans {
+		lineStart += strings.Count(content[offset:s.start], "\n")
+		offset = s.start
+		part := content[s.start:s.end]
+		id := chunkID(filename, version, s.start, s.end)
+		vectors = append(vectors, &types.Vector{
+			ID: id,
+			Metadata: types.Metadata{
+				ID:            id,
+				Filename:      filename,
+				Start:         s.start,
+				End:           s.end,
+				LineStart:     lineStart,
+				LineEnd:       lineStart + strings.Count(strings.TrimSuffix(part, "\n"), "\n"),
+				TruncatedEnd:  s.end,
+				Symbol:        s.symbol,
+				Receiver:      s.receiver,
+				Chunker:       version,
+				SplitPart:     AddEmbedHeader(filename, part),
+				RealSplitPart: part,
+			},
+		})
+	}
+	return vectors
+}
+
+// lineStart returns the offset of the start of the line holding offset.
+func lineStart(content string, offset int) int {
+	return strings.LastIndex(content[:offset], "\n") + 1
+}
+
+// lineEnd returns the offset after the end of the line holding offset, including its newline.
+func lineEnd(content string, offset int) int {
+	if i := strings.Index(content[offset:], "\n"); i >= 0 {
+		return offset + i + 1
+	}
+	return len(content)
+}
+
+// lineCuts returns the starts of the lines of s after its first line.
+func lineCuts(content string, s span) []int {
+	cuts := []int{}
+	for offset := s.start; ; {
+		i := strings.Index(content[offset:s.end], "\n")
+		if i < 0 || offset+i+1 >= s.end {
+			return cuts
+		}
+		offset += i + 1
+		cuts = append(cuts, offset)
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,203 @@
+package embed_test
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+func TestNewChunkers_givenOptions_expectChunkerPerExtension(t *testing.T) {
+	chunkers, err := embed.NewChunkers(map[string]config.ChunkerOptions{
+		".py": {Strategy: config.ChunkerBlocks, Size: 300},
+		"*":   {Strategy: config.ChunkerWindow, Size: 100, Overlap: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for filename, want := range map[string]string{
+		"a.py":      "blocks1-300",
+		"A.PY":      "blocks1-300",
+		"main.go":   "go1-200",
+		"README.md": "md1-200",
+		"a.txt":     "window1-100-20",
+		"Makefile":  "window1-100-20",
+	} {
+		if got := chunkers.For(filename).Version(); got != want {
+			t.Errorf("Expected chunker %s for %s, got %s", want, filename, got)
+		}
+	}
+
+	legacy, err := embed.NewChunkers(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := legacy.For("a.txt").Version(); got != "" {
+		t.Errorf("Expected the legacy chunker by default, got %q", got)
+	}
+}
+
+func TestNewChunkers_givenInvalidOptions_expectError(t *testing.T) {
+	for _, options := range []config.ChunkerOptions{
+		{Strategy: "sentences"},
+		{Strategy: config.ChunkerWindow, Size: 100, Overlap: 100},
+		{Strategy: config.ChunkerMarkdown, Overlap: 10},
+		{Strategy: config.ChunkerLegacy, Size: 300},
+	} {
+		if _, err := embed.NewChunkers(map[string]config.ChunkerOptions{"txt": options}); err == nil {
+			t.Errorf("Expected an error for %+v", options)
+		}
+	}
+}
+
+func TestWindowChunker_givenOverlap_expectOverlappingWindows(t *testing.T) {
+	content := strings.Repeat("word ", 25)
+	vectors, err := embed.WindowChunker{Size: 10, Overlap: 4}.Chunk(newWordTzap(), "a.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 5 {
+		t.Fatalf("Expected windows starting every 6 words, got %d", len(vectors))
+	}
+	var joined strings.Builder
+	for i, v := range vectors {
+		m := v.Metadata
+		if m.Start != i*6 || m.End != i*6+6 || m.Chunker != "window1-10-4" || v.ID != fmt.Sprintf("a.txt-window1-10-4-%d-%d", m.Start, m.End) {
+			t.Errorf("Unexpected window %d: %s %+v", i, v.ID, m)
+		}
+		if words := len(strings.Fields(embed.StripEmbedHeader(m.SplitPart))); i < 3 && words != 10 {
+			t.Errorf("Expected window %d to have 10 words, got %d", i, words)
+		}
+		joined.WriteString(m.RealSplitPart)
+	}
+	if strings.TrimSpace(joined.String()) != strings.TrimSpace(content) {
+		t.Errorf("Expected the windows without overlap to cover the file, got %q", joined.String())
+	}
+}
+
+func TestWindowChunker_givenLegacy_expectUnversionedIDs(t *testing.T) {
+	chunkers, _ := embed.NewChunkers(nil)
+	vectors, err := chunkers.For("a.txt").Chunk(newWordTzap(), "a.txt", strings.Repeat("word ", 450))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 3 || vectors[1].ID != "a.txt-200-400" || vectors[1].Metadata.Chunker != "" {
+		t.Errorf("Expected legacy windows of 200 tokens, got %d starting with %s", len(vectors), vectors[0].ID)
+	}
+}
+
+func TestEmbedder_givenDeprecatedProcessFileOffsets_expectLegacyWindows(t *testing.T) {
+	content := strings.Repeat("word ", 450)
+	legacy, _ := embed.NewChunkers(nil)
+	want, err := legacy.For("a.txt").Chunk(newWordTzap(), "a.txt", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embeddings, err := (&embed.Embedder{}).ProcessFileOffsets(newWordTzap(), "a.txt", content, 450)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(embeddings.Vectors) != len(want) {
+		t.Fatalf("Expected %d windows, got %d", len(want), len(embeddings.Vectors))
+	}
+	for i, vector := range embeddings.Vectors {
+		if vector.ID != want[i].ID || vector.Metadata.SplitPart != want[i].Metadata.SplitPart {
+			t.Errorf("Expected window %s, got %s", want[i].ID, vector.ID)
+		}
+	}
+
+	vector, err := (&embed.Embedder{}).ProcessOffset(newWordTzap(), "a.txt", content, 200, 400, 200, 0, 1, 450)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vector.ID != "a.txt-200-400" || vector.Metadata.SplitPart != want[1].Metadata.SplitPart {
+		t.Errorf("Expected the second legacy window, got %s", vector.ID)
+	}
+}
+
+func TestMarkdownChunker_givenSections_expectChunksAlongHeadings(t *testing.T) {
+	paragraph := strings.Repeat("Run it. ", 20) + "\n\n"
+	content := "# Tzap\n\nIntro.\n\n## Usage\n\n" + strings.Repeat(paragraph, 3) + "```sh\n# not a heading\n```\n\n## Install\n\nGo get.\n"
+	vectors, err := embed.MarkdownChunker{Size: 25}.Chunk(newWordTzap(), "README.md", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := []string{}
+	for _, v := range vectors {
+		symbols = append(symbols, v.Metadata.Symbol)
+	}
+	want := []string{"Tzap", "Tzap > Usage", "Tzap > Usage", "Tzap > Install"}
+	if strings.Join(symbols, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected sections %v, got %v", want, symbols)
+	}
+	if !strings.Contains(vectors[2].Metadata.RealSplitPart, "# not a heading") {
+		t.Errorf("Expected the code block kept in its section, got %q", vectors[2].Metadata.RealSplitPart)
+	}
+}
+
+func TestBlockChunker_givenPython_expectBlocksNamedAfterDefinitions(t *testing.T) {
+	content := "import os\n\n\ndef load(path):\n    data = open(path)\n\n    return data\n\n\nclass Store:\n    pass\n"
+	vectors, err := embed.BlockChunker{Size: 5}.Chunk(newWordTzap(), "store.py", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	symbols := []string{}
+	for _, v := range vectors {
+		symbols = append(symbols, v.Metadata.Symbol)
+	}
+	if strings.Join(symbols, "|") != "|load|Store" {
+		t.Errorf("Expected the import, load and Store blocks, got %v", symbols)
+	}
+	if vectors[1].Metadata.LineStart != 4 || vectors[1].Metadata.LineEnd != 9 {
+		t.Errorf("Expected load on lines 4 to 9, got %+v", vectors[1].Metadata)
+	}
+}
+
+func TestCheckChunkers_givenChunkerChanged_expectOnlyAffectedFilesChanged(t *testing.T) {
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return &wordTG{}, config.Configuration{Chunkers: map[string]config.ChunkerOptions{"py": {Strategy: config.ChunkerBlocks}}}
+	})
+	files := []types.FileReader{fileReader{path: "a.py", content: "def a(): pass\n"}, fileReader{path: "b.txt", content: "b"}}
+	stored := []types.Metadata{{Filename: "a.py"}, {Filename: "b.txt"}}
+	changed := map[string]string{}
+	unchanged := map[string]int64{"a.py": 1, "b.txt": 1}
+
+	if err := (&embed.Embedder{}).CheckChunkers(tz, files, stored, changed, unchanged); err != nil {
+		t.Fatal(err)
+	}
+	if changed["a.py"] != "def a(): pass\n" || len(changed) != 1 {
+		t.Errorf("Expected a.py chunked again, got %v", changed)
+	}
+	if _, ok := unchanged["b.txt"]; !ok || len(unchanged) != 1 {
+		t.Errorf("Expected b.txt unchanged, got %v", unchanged)
+	}
+}
+
+// fileReader is a file of content edited at modTime, in seconds.
+type fileReader struct {
+	path    string
+	content string
+	modTime int64
+}
+
+func (f fileReader) FilePath() string { return f.path }
+func (f fileReader) Open() (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader(f.content)), nil
+}
+func (f fileReader) Stat() (fs.FileInfo, error) { return fileInfo{f}, nil }
+
+type fileInfo struct{ f fileReader }
+
+func (i fileInfo) Name() string       { return i.f.path }
+func (i fileInfo) Size() int64        { return int64(len(i.f.content)) }
+func (i fileInfo) Mode() fs.FileMode  { return 0644 }
+func (i fileInfo) ModTime() time.Time { return time.Unix(i.f.modTime, 0) }
+func (i fileInfo) IsDir() bool        { return false }
+func (i fileInfo) Sys() interface{}   { return nil }
@@ -1,11 +1,11 @@
 package cosine
 
 import (
+	"container/heap"
 	"math"
-	"sort"
 )
 
-func dotProduct(a, b [1536]float32) float32 {
+func dotProduct(a, b []float32) float32 {
 	if len(a) != len(b) {
 		panic("Vectors must have the same dimensions")
 	}
@@ -17,7 +17,7 @@ func dotProduct(a, b [1536]float32) float32 {
 	return dot
 }
 
-func magnitude(x [1536]float32) float32 {
+func magnitude(x []float32) float32 {
 	var mag float32 = 0.0
 	for _, v := range x {
 		mag += v * v
@@ -25,7 +25,7 @@ func magnitude(x [1536]float32) float32 {
 	return float32(math.Sqrt(float64(mag)))
 }
 
-func CosineSimilarity(a, b [1536]float32) float32 {
+func CosineSimilarity(a, b []float32) float32 {
 	return dotProduct(a, b) / (magnitude(a) * magnitude(b))
 }
 
@@ -34,17 +34,45 @@ type Result struct {
 	Similarity float32
 }
 
-func SearchByCosineSimilarity(results [][1536]float32, query [1536]float32) []Result {
-	similarities := make([]Result, len(results))
+func SearchByCosineSimilarity(results [][]float32, query []float32) []Result {
+	return SearchTopK(results, query, -1)
+}
 
+// SearchTopK returns the k results most similar to query, best first, all of them for k below 0.
+// The magnitude of query is computed once, and only the best k are kept sorted.
+func SearchTopK(results [][]float32, query []float32, k int) []Result {
+	if k < 0 || k > len(results) {
+		k = len(results)
+	}
+	queryMagnitude := magnitude(query)
+	top := make(resultHeap, 0, k+1)
 	for i, result := range results {
-		similarity := CosineSimilarity(result, query)
-		similarities[i] = Result{Index: i, Similarity: similarity}
+		similarity := dotProduct(result, query) / (magnitude(result) * queryMagnitude)
+		if len(top) == k && (k == 0 || similarity <= top[0].Similarity) {
+			continue
+		}
+		heap.Push(&top, Result{Index: i, Similarity: similarity})
+		if len(top) > k {
+			heap.Pop(&top)
+		}
+	}
+	similarities := make([]Result, len(top))
+	for i := len(similarities) - 1; i >= 0; i-- {
+		similarities[i] = heap.Pop(&top).(Result)
 	}
+	return similarities
+}
 
-	sort.Slice(similarities, func(i, j int) bool {
-		return similarities[i].Similarity > similarities[j].Similarity
-	})
+// resultHeap is a min-heap of results, its root is the least similar of the best results found so far.
+type resultHeap []Result
 
-	return similarities
+func (h resultHeap) Len() int            { return len(h) }
+func (h resultHeap) Less(i, j int) bool  { return h[i].Similarity < h[j].Similarity }
+func (h resultHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *resultHeap) Push(x interface{}) { *h = append(*h, x.(Result)) }
+func (h *resultHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
 }
@@ -1,10 +1,11 @@
 package embed
 
 import (
-	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
 )
@@ -15,7 +16,7 @@ type Embedder struct {
 	EmbedCleaner
 }
 
-func NewEmbedder(embeddingCacheDB types.DBCollectionInterface[string], filesTimestampsDB types.DBCollectionInterface[int64]) *Embedder {
+func NewEmbedder(embeddingCacheDB types.DBCollectionInterface[string], filesTimestampsDB types.DBCollectionInterface[types.FileStamp]) *Embedder {
 	embeddingCache := NewEmbeddingCache(embeddingCacheDB)
 	filestampCache := NewFilestampCache(filesTimestampsDB)
 	return &Embedder{EmbeddingCache: embeddingCache, EmbedCleaner: EmbedCleaner{}, FilestampCache: filestampCache}
@@ -31,13 +32,26 @@ func (fe *Embedder) PrepareEmbeddingsFromFiles(t *tzap.Tzap, changedFileContents
 	return rawFileEmbeddings
 }
 
+// ProcessFileContents cuts the files of changedFiles, sorted by name, into chunks with the chunkers the configuration
+// of t selects.
 func (fe *Embedder) ProcessFileContents(t *tzap.Tzap, changedFiles map[string]string) (*types.Embeddings, error) {
 	tl.Logger.Println("Processing files", len(changedFiles))
+	chunkers, err := NewChunkers(config.FromContext(t.C).Chunkers)
+	if err != nil {
+		return &types.Embeddings{}, err
+	}
 	totalTokens := 0
 	totalLines := 0
 
+	// Files are chunked in order, so that the embedding requests of a run are the same each time.
+	files := make([]string, 0, len(changedFiles))
+	for file := range changedFiles {
+		files = append(files, file)
+	}
+	sort.Strings(files)
 	embeddings := &types.Embeddings{}
-	for file, content := range changedFiles {
+	for _, file := range files {
+		content := changedFiles[file]
 		fileTokens, lines, err := fe.ProcessFileContent(t, content)
 		if err != nil {
 			panic(err)
@@ -47,53 +61,25 @@ func (fe *Embedder) ProcessFileContents(t *tzap.Tzap, changedFiles map[string]st
 
 		tl.Logger.Printf("File: %s - Tokens: %d, Lines: %d\n", file, fileTokens, lines)
 
-		fileEmbeddings, err := fe.ProcessFileOffsets(t, file, content, fileTokens)
+		vectors, err := chunkers.For(file).Chunk(t, file, content)
 		if err != nil {
 			return &types.Embeddings{}, err
 		}
 
-		embeddings.Vectors = append(embeddings.Vectors, fileEmbeddings.Vectors...)
+		embeddings.Vectors = append(embeddings.Vectors, vectors...)
 	}
 	tl.Logger.Println("Processed files", len(changedFiles), "Total Embeddings", len(embeddings.Vectors), "Total Tokens", totalTokens, "Total Lines", totalLines)
 	return embeddings, nil
 }
 
+// ProcessFileOffsets cuts file into the windows of 200 tokens of the legacy chunker.
+//
+// Deprecated: Use ProcessFileContents, which cuts files with the configured chunkers, or the Chunk method of a Chunker.
 func (fe *Embedder) ProcessFileOffsets(t *tzap.Tzap, file string, content string, fileTokens int) (*types.Embeddings, error) {
-	vectors := []*types.Vector{}
-	baseStep := 200
-	step := 4000
-	chunkStart := 0
-	chunkEnd := step
-
-	lineStart := 1
-	for chunkStart < fileTokens {
-		// Process file in chunks of 32k tokens to avoid loading whole file
-		tl.Logger.Println("Processing file", file, "chunk", chunkStart, "to", chunkEnd, "tokens", fileTokens)
-		chunkContent, c, err := t.TG.OffsetTokens(t.C, content, chunkStart, chunkEnd)
-		if err != nil {
-			return &types.Embeddings{}, err
-		}
-
-		start := 0
-		end := baseStep
-		for start < c {
-			partialVector, err := fe.ProcessOffset(t, file, chunkContent, start, end, baseStep, chunkStart, lineStart, c)
-			if err != nil {
-				return &types.Embeddings{}, err
-			}
-			vectors = append(vectors, partialVector)
-
-			start += baseStep
-			end += baseStep
-
-			lines := strings.Count(partialVector.Metadata.RealSplitPart, "\n")
-			lineStart += lines
-		}
-
-		chunkStart += step
-		chunkEnd += step
+	vectors, err := WindowChunker{Size: defaultChunkSize, legacy: true}.Chunk(t, file, content)
+	if err != nil {
+		return &types.Embeddings{}, err
 	}
-
 	return &types.Embeddings{Vectors: vectors}, nil
 }
 
@@ -108,45 +94,15 @@ func (fe *Embedder) ProcessFileContent(t *tzap.Tzap, content string) (int, int,
 	return fileTokens, lines, nil
 }
 
+// ProcessOffset cuts the window of the legacy chunker from start to end tokens into content, the fileTokens tokens
+// of a file starting at chunkStart. The next window starts step tokens after start.
+//
+// Deprecated: Use the Chunk method of a Chunker.
 func (fe *Embedder) ProcessOffset(t *tzap.Tzap, filename, content string, start int, end int, step int, chunkStart int, lineStart int, fileTokens int) (*types.Vector, error) {
-	truncatedEnd := end
-	if end > fileTokens {
-		truncatedEnd = fileTokens
-	}
-	tl.Logger.Println("Filename", filename, "Processing offset", start, truncatedEnd, "of", fileTokens, "tokens")
-	splitPart, _, err := t.TG.OffsetTokens(t.C, content, start, truncatedEnd)
-	if err != nil {
-		return &types.Vector{}, err
-	}
-	truncatedRealEnd := start + step
-	if truncatedRealEnd > fileTokens {
-		truncatedRealEnd = fileTokens
-	}
-
-	realSplitPart, _, err := t.TG.OffsetTokens(t.C, content, start, truncatedRealEnd)
+	vector, err := WindowChunker{Size: end - start, Overlap: end - start - step, legacy: true}.
+		window(t, filename, content, chunkStart, start, fileTokens, chunkStart+fileTokens, lineStart)
 	if err != nil {
 		return &types.Vector{}, err
 	}
-
-	splitPart = "####embedding from file: " + filename + "\n" + splitPart
-	metadataStart := chunkStart + start
-	metadataEnd := chunkStart + end
-	metadataLineStart := lineStart
-	metadataTruncatedEnd := truncatedEnd
-
-	id := fmt.Sprintf("%s-%d-%d", filename, metadataStart, metadataEnd)
-
-	return &types.Vector{
-		ID: id,
-		Metadata: types.Metadata{
-			ID:            id,
-			Filename:      filename,
-			Start:         metadataStart,
-			End:           metadataEnd,
-			LineStart:     metadataLineStart,
-			TruncatedEnd:  metadataTruncatedEnd,
-			SplitPart:     splitPart,
-			RealSplitPart: realSplitPart,
-		},
-	}, nil
+	return vector, nil
 }
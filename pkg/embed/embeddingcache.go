@@ -1,10 +1,16 @@
 package embed
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"sync"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
 	"github.com/tzapio/tzap/pkg/tzap"
 	"github.com/tzapio/tzap/pkg/util/reflectutil"
 )
@@ -17,22 +23,36 @@ func NewEmbeddingCache(embeddingCacheDB types.DBCollectionInterface[string]) *Em
 	return &EmbeddingCache{embeddingCacheDB}
 }
 
-func (ec *EmbeddingCache) GetCachedEmbeddings(files []types.FileReader, embeddings *types.Embeddings) (*types.Embeddings, error) {
+// cacheKey is the key of the cached embedding of splitPart. Embeddings of other models than ada-002, or
+// shortened ones, are cached under the model id and length so that switching models does not reuse vectors of another model.
+func cacheKey(t *tzap.Tzap, splitPart string) string {
+	conf := config.FromContext(t.C)
+	model := models.ID(conf.EmbedModel)
+	if conf.EmbedDimensions > 0 {
+		model = fmt.Sprintf("%s/%d", model, conf.EmbedDimensions)
+	}
+	if model == openai.AdaEmbeddingV2 {
+		return splitPart
+	}
+	return model + "\n" + splitPart
+}
+
+func (ec *EmbeddingCache) GetCachedEmbeddings(t *tzap.Tzap, files []types.FileReader, embeddings *types.Embeddings) (*types.Embeddings, error) {
 	tl.Logger.Println("Getting cached embeddings", len(embeddings.Vectors))
 	var cachedEmbeddings []*types.Vector
 
 	for _, vector := range embeddings.Vectors {
 		splitPart := vector.Metadata.SplitPart
-		kv, exists := ec.embeddingCacheDB.ScanGet(splitPart)
+		kv, exists := ec.embeddingCacheDB.ScanGet(cacheKey(t, splitPart))
 		if exists {
 			if !reflectutil.IsZero(kv.Value) {
-				var float32Vector [1536]float32
+				var float32Vector []float32
 				err := json.Unmarshal([]byte(kv.Value), &float32Vector)
 				if err != nil {
 					return nil, err
 				}
 
-				if len(float32Vector) == 1536 {
+				if len(float32Vector) > 0 {
 					cachedVector := &types.Vector{
 						ID:        vector.ID,
 						TimeStamp: 0,
@@ -55,12 +75,12 @@ func (ec *EmbeddingCache) GetCachedEmbeddings(files []types.FileReader, embeddin
 	return &types.Embeddings{Vectors: cachedEmbeddings}, nil
 }
 
-func (ec *EmbeddingCache) GetUncachedEmbeddings(embeddings *types.Embeddings) *types.Embeddings {
+func (ec *EmbeddingCache) GetUncachedEmbeddings(t *tzap.Tzap, embeddings *types.Embeddings) *types.Embeddings {
 	var uncachedEmbeddings []*types.Vector
 
 	for _, vector := range embeddings.Vectors {
 		splitPart := vector.Metadata.SplitPart
-		kv, exists := ec.embeddingCacheDB.ScanGet(splitPart)
+		kv, exists := ec.embeddingCacheDB.ScanGet(cacheKey(t, splitPart))
 		if !exists || reflectutil.IsZero(kv.Value) {
 			uncachedEmbeddings = append(uncachedEmbeddings, vector)
 		}
@@ -69,47 +89,137 @@ func (ec *EmbeddingCache) GetUncachedEmbeddings(embeddings *types.Embeddings) *t
 	return &types.Embeddings{Vectors: uncachedEmbeddings}
 }
 
-func (ec *EmbeddingCache) FetchThenCacheNewEmbeddings(t *tzap.Tzap, files []types.FileReader, uncachedEmbeddings *types.Embeddings) error {
-	storedFiles := map[string]struct{}{}
-
-	if len(uncachedEmbeddings.Vectors) > 0 {
-		batchSize := 100
-
-		for i := 0; i < len(uncachedEmbeddings.Vectors); i += batchSize {
-			end := i + batchSize
-			if end > len(uncachedEmbeddings.Vectors) {
-				end = len(uncachedEmbeddings.Vectors)
-			}
-
-			batch := uncachedEmbeddings.Vectors[i:end]
-			var inputStrings []string
-			for _, vector := range batch {
-				storedFiles[vector.Metadata.Filename] = struct{}{}
-				inputStrings = append(inputStrings, vector.Metadata.SplitPart)
-			}
+// defaultEmbedWorkers is the EmbedWorkers of configurations without one.
+const defaultEmbedWorkers = 4
 
-			embeddingsResult, err := t.TG.FetchEmbedding(t.C, inputStrings...)
-			if err != nil {
-				return err
-			}
+// embeddingBatch holds the inputs of one embedding request and their tokens.
+type embeddingBatch struct {
+	inputs []string
+	tokens int
+}
 
-			cacheKeyVal := make([]types.KeyValue[string], len(embeddingsResult))
-			for i, embedding := range embeddingsResult {
-				embBytes, err := json.Marshal(embedding)
-				if err != nil {
-					return err
+// FetchThenCacheNewEmbeddings fetches the embeddings of uncachedEmbeddings in batches of at most EmbedBatchTokens
+// tokens, sent by EmbedWorkers concurrent workers through t.TG, whose middleware rate limits all of them together.
+// Each batch is cached as soon as it is fetched, so a run that fails or is interrupted keeps the completed batches
+// and the next run only fetches the rest.
+func (ec *EmbeddingCache) FetchThenCacheNewEmbeddings(t *tzap.Tzap, files []types.FileReader, uncachedEmbeddings *types.Embeddings) error {
+	if len(uncachedEmbeddings.Vectors) == 0 {
+		return nil
+	}
+	conf := config.FromContext(t.C)
+	maxTokens, maxInputs := embeddingBatchLimits(conf)
+	batches, total, err := embeddingBatches(t, uncachedEmbeddings, maxTokens, maxInputs)
+	if err != nil {
+		return err
+	}
+	workers := conf.EmbedWorkers
+	if workers <= 0 {
+		workers = defaultEmbedWorkers
+	}
+	workers = min(workers, len(batches))
+	tl.Logger.Println("Fetching", total, "embeddings in", len(batches), "batches with", workers, "workers")
+
+	ctx, cancel := context.WithCancel(t.UsageContext())
+	defer cancel()
+	queue := make(chan embeddingBatch)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	done := 0
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range queue {
+				err := ec.fetchThenCache(ctx, t, batch)
+				lock.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+					cancel()
 				}
-				cacheKeyVal[i] = types.KeyValue[string]{Key: inputStrings[i], Value: string(embBytes)}
-			}
-
-			added, err := ec.embeddingCacheDB.BatchSet(cacheKeyVal)
-			if err != nil {
-				return err
+				if err == nil {
+					done += len(batch.inputs)
+					tl.UILogger.Println("Embedded", done, "of", total, "chunks")
+				}
+				lock.Unlock()
 			}
-
-			tl.UILogger.Println("Added", added, "embeddings to cache")
+		}()
+	}
+feed:
+	for _, batch := range batches {
+		select {
+		case queue <- batch:
+		case <-ctx.Done():
+			break feed
 		}
 	}
+	close(queue)
+	wg.Wait()
+	return firstErr
+}
 
+// fetchThenCache fetches the embeddings of batch and caches them.
+func (ec *EmbeddingCache) fetchThenCache(ctx context.Context, t *tzap.Tzap, batch embeddingBatch) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	embeddingsResult, err := t.TG.FetchEmbedding(ctx, batch.inputs...)
+	if err != nil {
+		return err
+	}
+	if len(embeddingsResult) != len(batch.inputs) {
+		return fmt.Errorf("embedding failed: got %d embeddings for %d inputs", len(embeddingsResult), len(batch.inputs))
+	}
+	cacheKeyVal := make([]types.KeyValue[string], len(embeddingsResult))
+	for i, embedding := range embeddingsResult {
+		embBytes, err := json.Marshal(embedding)
+		if err != nil {
+			return err
+		}
+		cacheKeyVal[i] = types.KeyValue[string]{Key: cacheKey(t, batch.inputs[i]), Value: string(embBytes)}
+	}
+	added, err := ec.embeddingCacheDB.BatchSet(cacheKeyVal)
+	if err != nil {
+		return err
+	}
+	tl.Logger.Println("Added", added, "embeddings to cache")
 	return nil
+}
+
+// embeddingBatchLimits returns the tokens and inputs allowed in one embedding request, 0 meaning no limit.
+// EmbedBatchTokens may lower the token limit of the embedding model, but not raise it.
+func embeddingBatchLimits(conf config.Configuration) (int, int) {
+	model, _ := models.Get(conf.EmbedModel)
+	maxTokens := model.MaxRequestTokens
+	if conf.EmbedBatchTokens > 0 && (maxTokens == 0 || conf.EmbedBatchTokens < maxTokens) {
+		maxTokens = conf.EmbedBatchTokens
+	}
+	return maxTokens, model.MaxRequestInputs
+}
+
+// embeddingBatches packs the distinct SplitParts of embeddings, in order, into batches of at most maxTokens tokens
+// and maxInputs inputs, 0 meaning no limit. A part larger than maxTokens gets a batch of its own. It also returns
+// the number of parts.
+func embeddingBatches(t *tzap.Tzap, embeddings *types.Embeddings, maxTokens int, maxInputs int) ([]embeddingBatch, int, error) {
+	batches := []embeddingBatch{}
+	seen := map[string]bool{}
+	for _, vector := range embeddings.Vectors {
+		input := vector.Metadata.SplitPart
+		if seen[input] {
+			continue
+		}
+		seen[input] = true
+		tokens, err := t.TG.CountTokens(t.C, input)
+		if err != nil {
+			return nil, 0, err
+		}
+		last := len(batches) - 1
+		if last < 0 || maxTokens > 0 && batches[last].tokens+tokens > maxTokens || maxInputs > 0 && len(batches[last].inputs) >= maxInputs {
+			batches = append(batches, embeddingBatch{})
+			last++
+		}
+		batches[last].inputs = append(batches[last].inputs, input)
+		batches[last].tokens += tokens
+	}
+	return batches, len(seen), nil
 }
\ No newline at end of file
@@ -0,0 +1,143 @@
+package embed_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/embed/localdb"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// embeddingTG records the batches fetched and fails on batches holding the input fail.
+type embeddingTG struct {
+	wordTG
+	fail  string
+	delay time.Duration
+
+	lock        sync.Mutex
+	batches     [][]string
+	inFlight    int
+	maxInFlight int
+}
+
+func (tg *embeddingTG) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	tg.lock.Lock()
+	tg.batches = append(tg.batches, content)
+	tg.inFlight++
+	if tg.inFlight > tg.maxInFlight {
+		tg.maxInFlight = tg.inFlight
+	}
+	tg.lock.Unlock()
+	defer func() {
+		tg.lock.Lock()
+		tg.inFlight--
+		tg.lock.Unlock()
+	}()
+	time.Sleep(tg.delay)
+
+	embeddings := [][]float32{}
+	for _, input := range content {
+		if input == tg.fail {
+			return nil, fmt.Errorf("embedding failed: %s", input)
+		}
+		embeddings = append(embeddings, []float32{float32(len(input))})
+	}
+	return embeddings, nil
+}
+
+func newEmbeddingTzap(tg *embeddingTG, conf config.Configuration) *tzap.Tzap {
+	return tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, conf
+	})
+}
+
+func splitParts(parts ...string) *types.Embeddings {
+	embeddings := &types.Embeddings{}
+	for i, part := range parts {
+		id := fmt.Sprintf("a.txt-%d", i)
+		embeddings.Vectors = append(embeddings.Vectors, &types.Vector{ID: id, Metadata: types.Metadata{ID: id, Filename: "a.txt", SplitPart: part}})
+	}
+	return embeddings
+}
+
+func TestFetchThenCacheNewEmbeddings_givenTokenBudget_expectBatchesWithinBudget(t *testing.T) {
+	db, _ := localdb.NewFileDB[string]("@MEMORY/embeddingcache")
+	cache := embed.NewEmbeddingCache(db)
+	tg := &embeddingTG{}
+	tz := newEmbeddingTzap(tg, config.Configuration{EmbedBatchTokens: 10})
+	parts := []string{"a b c", "d e f", "g h i", "j k l", "m n o o o o o o o o o o", "p q", "a b c"}
+
+	if err := cache.FetchThenCacheNewEmbeddings(tz, nil, splitParts(parts...)); err != nil {
+		t.Fatal(err)
+	}
+	sizes := []string{}
+	for _, batch := range tg.batches {
+		sizes = append(sizes, strings.Join(batch, "|"))
+	}
+	// The batches are sent by concurrent workers, so in any order.
+	for _, want := range []string{"a b c|d e f|g h i", "j k l", "m n o o o o o o o o o o", "p q"} {
+		found := false
+		for _, got := range sizes {
+			found = found || got == want
+		}
+		if !found {
+			t.Errorf("Expected batch %q, got %q", want, sizes)
+		}
+	}
+	if len(tg.batches) != 4 {
+		t.Errorf("Expected 4 batches, the repeated part fetched once, got %q", sizes)
+	}
+	if uncached := cache.GetUncachedEmbeddings(tz, splitParts(parts...)); len(uncached.Vectors) != 0 {
+		t.Errorf("Expected all embeddings cached, got %d uncached", len(uncached.Vectors))
+	}
+}
+
+func TestFetchThenCacheNewEmbeddings_givenWorkers_expectConcurrentRequests(t *testing.T) {
+	db, _ := localdb.NewFileDB[string]("@MEMORY/embeddingcache")
+	tg := &embeddingTG{delay: 20 * time.Millisecond}
+	tz := newEmbeddingTzap(tg, config.Configuration{EmbedBatchTokens: 1, EmbedWorkers: 3})
+
+	if err := embed.NewEmbeddingCache(db).FetchThenCacheNewEmbeddings(tz, nil, splitParts("a", "b", "c", "d", "e", "f", "g", "h", "i")); err != nil {
+		t.Fatal(err)
+	}
+	if len(tg.batches) != 9 {
+		t.Errorf("Expected a batch per part, got %d", len(tg.batches))
+	}
+	if tg.maxInFlight < 2 || tg.maxInFlight > 3 {
+		t.Errorf("Expected up to 3 requests at once, got %d", tg.maxInFlight)
+	}
+}
+
+func TestFetchThenCacheNewEmbeddings_givenFailedBatch_expectResumeFetchesOnlyTheRest(t *testing.T) {
+	db, _ := localdb.NewFileDB[string]("@MEMORY/embeddingcache")
+	cache := embed.NewEmbeddingCache(db)
+	tg := &embeddingTG{fail: "c"}
+	tz := newEmbeddingTzap(tg, config.Configuration{EmbedBatchTokens: 1, EmbedWorkers: 1})
+	embeddings := splitParts("a", "b", "c", "d")
+
+	if err := cache.FetchThenCacheNewEmbeddings(tz, nil, embeddings); err == nil {
+		t.Fatal("Expected the failed batch to fail the run")
+	}
+	uncached := cache.GetUncachedEmbeddings(tz, embeddings)
+	if len(uncached.Vectors) != 2 {
+		t.Fatalf("Expected the batches before the failure cached, got %d uncached", len(uncached.Vectors))
+	}
+
+	resumed := &embeddingTG{}
+	if err := cache.FetchThenCacheNewEmbeddings(newEmbeddingTzap(resumed, config.Configuration{EmbedBatchTokens: 1}), nil, uncached); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.batches) != 2 || resumed.batches[0][0] == "a" || resumed.batches[1][0] == "a" {
+		t.Errorf("Expected only c and d fetched, got %q", resumed.batches)
+	}
+	if cached, err := cache.GetCachedEmbeddings(tz, nil, embeddings); err != nil || len(cached.Vectors) != 4 {
+		t.Errorf("Expected all 4 embeddings cached, got %v %v", cached, err)
+	}
+}
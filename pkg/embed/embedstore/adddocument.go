@@ -7,36 +7,57 @@ import (
 	"github.com/tzapio/tzap/pkg/types"
 )
 
-func (idx *embedStore) AddEmbeddingDocument(ctx context.Context, docID string, embedding [1536]float32, metadata types.Metadata) error {
+// AddEmbeddingDocument stores the embedding of a document. The first embedding of an empty collection sets its
+// types.IndexHeader; embeddings of another model or length fail with types.ErrReindexRequired.
+func (idx *embedStore) AddEmbeddingDocument(ctx context.Context, docID string, embedding []float32, metadata types.Metadata) error {
+	if err := checkIndexHeader(ctx, len(embedding), true); err != nil {
+		return err
+	}
 	v := types.Vector{
 		ID:        docID,
 		TimeStamp: 0,
 		Metadata:  metadata,
 		Values:    embedding,
 	}
-	embeddingCollection := project.GetProjectFromContext(ctx).GetEmbeddingCollection()
-	if err := embeddingCollection.Set(docID, v); err != nil {
+	p := project.GetProjectFromContext(ctx)
+	if err := p.GetEmbeddingCollection().Set(docID, v); err != nil {
 		return err
 	}
+	if vectorIndex := p.GetVectorIndex(); vectorIndex != nil {
+		return vectorIndex.Add(docID, embedding)
+	}
 	return nil
 }
 func (idx *embedStore) AddEmbeddingDocuments(ctx context.Context, vectors []types.Vector) (int, error) {
 	pairs := []types.KeyValue[types.Vector]{}
 	for _, v := range vectors {
+		if err := checkIndexHeader(ctx, len(v.Values), true); err != nil {
+			return 0, err
+		}
 		pairs = append(pairs, types.KeyValue[types.Vector]{Key: v.ID, Value: v})
 	}
-	embeddingCollection := project.GetProjectFromContext(ctx).GetEmbeddingCollection()
-	wrote, err := embeddingCollection.BatchSet(pairs)
+	p := project.GetProjectFromContext(ctx)
+	wrote, err := p.GetEmbeddingCollection().BatchSet(pairs)
 	if err != nil {
 		return wrote, err
 	}
+	if vectorIndex := p.GetVectorIndex(); vectorIndex != nil {
+		for _, v := range vectors {
+			if err := vectorIndex.Add(v.ID, v.Values); err != nil {
+				return wrote, err
+			}
+		}
+	}
 	return wrote, nil
 }
 func (idx *embedStore) DeleteEmbeddingDocument(ctx context.Context, docID string) error {
-	embeddingCollection := project.GetProjectFromContext(ctx).GetEmbeddingCollection()
-	if err := embeddingCollection.Set(docID, types.Vector{}); err != nil {
+	p := project.GetProjectFromContext(ctx)
+	if err := p.GetEmbeddingCollection().Set(docID, types.Vector{}); err != nil {
 		return err
 	}
+	if vectorIndex := p.GetVectorIndex(); vectorIndex != nil {
+		return vectorIndex.Delete(docID)
+	}
 	return nil
 }
 func (idx *embedStore) DeleteEmbeddingDocuments(ctx context.Context, docIDs []string) error {
@@ -44,9 +65,12 @@ func (idx *embedStore) DeleteEmbeddingDocuments(ctx context.Context, docIDs []st
 	for _, docID := range docIDs {
 		pairs = append(pairs, types.KeyValue[types.Vector]{Key: docID})
 	}
-	embeddingCollection := project.GetProjectFromContext(ctx).GetEmbeddingCollection()
-	if _, err := embeddingCollection.BatchSet(pairs); err != nil {
+	p := project.GetProjectFromContext(ctx)
+	if _, err := p.GetEmbeddingCollection().BatchSet(pairs); err != nil {
 		return err
 	}
+	if vectorIndex := p.GetVectorIndex(); vectorIndex != nil {
+		return vectorIndex.Delete(docIDs...)
+	}
 	return nil
 }
@@ -0,0 +1,55 @@
+package embedstore
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/models"
+	"github.com/tzapio/tzap/pkg/project"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
+)
+
+const indexHeaderKey = "index"
+
+// legacyIndexHeader describes the embeddings of indexes built before headers were recorded, which were all
+// embedded with ada-002.
+var legacyIndexHeader = types.IndexHeader{Model: openai.AdaEmbeddingV2, Dimensions: 1536}
+
+// checkIndexHeader returns an error wrapping types.ErrReindexRequired unless embeddings of the configured
+// model with the given dimensions can be used with the embedding collection of the project in ctx.
+// With create, the header of an empty collection is set to those embeddings. Collections without header
+// whose embeddings all have the length of ada-002 get the header of ada-002.
+func checkIndexHeader(ctx context.Context, dimensions int, create bool) error {
+	p := project.GetProjectFromContext(ctx)
+	want := types.IndexHeader{Model: models.ID(config.FromContext(ctx).EmbedModel), Dimensions: dimensions}
+	headers := p.GetIndexHeader()
+	header, exists := headers.Get(indexHeaderKey)
+	if exists && header == want {
+		return nil
+	}
+	vectors := p.GetEmbeddingCollection().GetAll()
+	empty := len(vectors) == 0
+	switch {
+	case empty && create:
+		return headers.Set(indexHeaderKey, want)
+	case empty:
+		return nil
+	case !exists:
+		for _, vector := range vectors {
+			if len(vector.Value.Values) != legacyIndexHeader.Dimensions {
+				return fmt.Errorf("%w: the index has no header and embeddings of %d dimensions, run tzap reset to index the project again",
+					types.ErrReindexRequired, len(vector.Value.Values))
+			}
+		}
+		header = legacyIndexHeader
+		if err := headers.Set(indexHeaderKey, header); err != nil {
+			return err
+		}
+	}
+	if err := header.Check(want); err != nil {
+		return fmt.Errorf("%w, run tzap reset to index the project again", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package embedstore
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed/hnsw"
+	"github.com/tzapio/tzap/pkg/embed/localdb"
+	"github.com/tzapio/tzap/pkg/project"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+type memoryProject struct {
+	embeddings  types.DBCollectionInterface[types.Vector]
+	indexHeader types.DBCollectionInterface[types.IndexHeader]
+	vectorIndex types.VectorIndex
+}
+
+func newMemoryProject(t *testing.T) *memoryProject {
+	embeddings, err := localdb.NewFileDB[types.Vector]("@MEMORY/" + t.Name() + "/embeddings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexHeader, err := localdb.NewFileDB[types.IndexHeader]("@MEMORY/" + t.Name() + "/header")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &memoryProject{embeddings: embeddings, indexHeader: indexHeader, vectorIndex: hnsw.NewStore("", embeddings, hnsw.Options{})}
+}
+
+func (p *memoryProject) GetProjectName() project.ProjectName                             { return "memory" }
+func (p *memoryProject) GetFiles() ([]types.FileReader, error)                           { return nil, nil }
+func (p *memoryProject) GetTimestampCache() types.DBCollectionInterface[types.FileStamp] { return nil }
+func (p *memoryProject) GetEmbeddingsCache() types.DBCollectionInterface[string]         { return nil }
+func (p *memoryProject) GetEmbeddingCollection() types.DBCollectionInterface[types.Vector] {
+	return p.embeddings
+}
+func (p *memoryProject) GetIndexHeader() types.DBCollectionInterface[types.IndexHeader] {
+	return p.indexHeader
+}
+func (p *memoryProject) GetVectorIndex() types.VectorIndex { return p.vectorIndex }
+func (p *memoryProject) GetLexicalIndex() types.DBCollectionInterface[types.LexicalDocument] {
+	return nil
+}
+func (p *memoryProject) GetLexicalTimestampCache() types.DBCollectionInterface[types.FileStamp] {
+	return nil
+}
+func (p *memoryProject) CanIndex() bool { return true }
+
+func newContext(p project.Project, embedModel string) context.Context {
+	ctx := config.NewContext(context.Background(), config.Configuration{EmbedModel: embedModel})
+	return project.SetProjectInContext(ctx, p)
+}
+
+func TestIndexHeader_givenFirstDocument_expectHeaderRecorded(t *testing.T) {
+	p := newMemoryProject(t)
+	ctx := newContext(p, "ada2")
+	if err := EmbedStore.AddEmbeddingDocument(ctx, "a", []float32{1, 0, 0}, types.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+	header, exists := p.indexHeader.Get(indexHeaderKey)
+	if !exists || header != (types.IndexHeader{Model: "text-embedding-ada-002", Dimensions: 3}) {
+		t.Errorf("expected the header of ada-002 with 3 dimensions, got %+v", header)
+	}
+	results, err := EmbedStore.SearchWithEmbedding(ctx, types.QueryFilter{Values: []float32{1, 0, 0}}, 1)
+	if err != nil || len(results.Results) != 1 {
+		t.Errorf("expected one result, got %+v, %v", results, err)
+	}
+}
+
+func TestIndexHeader_givenOtherModelOrDimensions_expectReindexRequired(t *testing.T) {
+	p := newMemoryProject(t)
+	ctx := newContext(p, "text-embedding-ada-002")
+	if err := EmbedStore.AddEmbeddingDocument(ctx, "a", []float32{1, 0, 0}, types.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+
+	otherModel := newContext(p, "text-embedding-3-small")
+	if _, err := EmbedStore.SearchWithEmbedding(otherModel, types.QueryFilter{Values: []float32{1, 0, 0}}, 1); !errors.Is(err, types.ErrReindexRequired) {
+		t.Errorf("expected reindex required for another model, got %v", err)
+	}
+	if err := EmbedStore.AddEmbeddingDocument(otherModel, "b", []float32{1, 0, 0}, types.Metadata{}); !errors.Is(err, types.ErrReindexRequired) {
+		t.Errorf("expected reindex required when adding with another model, got %v", err)
+	}
+	if _, err := EmbedStore.SearchWithEmbedding(ctx, types.QueryFilter{Values: []float32{1, 0}}, 1); !errors.Is(err, types.ErrReindexRequired) {
+		t.Errorf("expected reindex required for other dimensions, got %v", err)
+	}
+}
+
+func TestIndexHeader_givenIndexWithoutHeader_expectReindexRequired(t *testing.T) {
+	p := newMemoryProject(t)
+	if err := p.embeddings.Set("a", types.Vector{ID: "a", Values: []float32{1, 0, 0}}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := newContext(p, "ada2")
+	_, err := EmbedStore.SearchWithEmbedding(ctx, types.QueryFilter{Values: []float32{1, 0, 0}}, 1)
+	if !errors.Is(err, types.ErrReindexRequired) || !strings.Contains(err.Error(), "tzap reset") {
+		t.Errorf("expected reindex required naming tzap reset for an index without header, got %v", err)
+	}
+}
+
+func TestIndexHeader_givenLegacyIndexWithoutHeader_expectAdaHeaderRecorded(t *testing.T) {
+	p := newMemoryProject(t)
+	values := make([]float32, 1536)
+	values[0] = 1
+	if err := p.embeddings.Set("a", types.Vector{ID: "a", Values: values}); err != nil {
+		t.Fatal(err)
+	}
+
+	otherModel := newContext(p, "text-embedding-3-small")
+	if _, err := EmbedStore.SearchWithEmbedding(otherModel, types.QueryFilter{Values: values}, 1); !errors.Is(err, types.ErrReindexRequired) {
+		t.Errorf("expected reindex required for another model, got %v", err)
+	}
+	ctx := newContext(p, "ada2")
+	results, err := EmbedStore.SearchWithEmbedding(ctx, types.QueryFilter{Values: values}, 1)
+	if err != nil || len(results.Results) != 1 {
+		t.Errorf("expected one result, got %+v, %v", results, err)
+	}
+	header, exists := p.indexHeader.Get(indexHeaderKey)
+	if !exists || header != (types.IndexHeader{Model: "text-embedding-ada-002", Dimensions: 1536}) {
+		t.Errorf("expected the header of ada-002 with 1536 dimensions, got %+v", header)
+	}
+}
+
+func TestIndexHeader_givenEmptiedIndex_expectNewHeader(t *testing.T) {
+	p := newMemoryProject(t)
+	ctx := newContext(p, "ada2")
+	if err := EmbedStore.AddEmbeddingDocument(ctx, "a", []float32{1, 0, 0}, types.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := EmbedStore.DeleteEmbeddingDocument(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	otherModel := newContext(p, "text-embedding-3-large")
+	if err := EmbedStore.AddEmbeddingDocument(otherModel, "b", []float32{1, 0}, types.Metadata{}); err != nil {
+		t.Fatalf("expected an emptied index to take the new model, got %v", err)
+	}
+}
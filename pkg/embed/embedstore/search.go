@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/embed/cosine"
 	"github.com/tzapio/tzap/pkg/project"
 	"github.com/tzapio/tzap/pkg/types"
@@ -21,31 +22,97 @@ func (idx *embedStore) ListAllEmbeddingsIds(ctx context.Context) (types.SearchRe
 	}
 	return listEmbeddings, nil
 }
+
+// exactSearchLimit is the number of filtered embeddings below which comparing the query with each of them is faster
+// than searching the vector index for the few that pass the filter.
+const exactSearchLimit = 5000
+
+// SearchWithEmbedding returns the k documents most similar to embedding, all of them for k below 0. Only the documents
+// selected by the Filter of embedding are ranked, so none of the k are spent on documents the filter drops.
+// It searches the vector index of the project, unless the project has none, k is below 0, the configuration asks
+// for ExactSearch or the filter selects few documents, in which case embedding is compared with every selected document.
+// An embedding of another model or length than the index fails with types.ErrReindexRequired.
 func (idx *embedStore) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
 	tl.Logger.Println("SearchWithEmbedding")
-	embeddingCollection := project.GetProjectFromContext(ctx).GetEmbeddingCollection()
-	res := embeddingCollection.GetAll()
-	floatVectors := [][1536]float32{}
-	vectors := []types.Vector{}
-	for _, r := range res {
-		vector := r.Value
-		floatVectors = append(floatVectors, vector.Values)
-		vectors = append(vectors, vector)
+	if err := checkIndexHeader(ctx, len(embedding.Values), false); err != nil {
+		return types.SearchResults{}, err
 	}
+	p := project.GetProjectFromContext(ctx)
+	embeddingCollection := p.GetEmbeddingCollection()
+	vectorIndex := p.GetVectorIndex()
+	exact := vectorIndex == nil || k < 0 || config.FromContext(ctx).ExactSearch
 
-	results := cosine.SearchByCosineSimilarity(floatVectors, embedding.Values)
-	searchResults := types.SearchResults{}
-	if len(results) < k || k <= -1 {
-		k = len(results)
+	var keep func(id string) bool
+	var selected []types.KeyValue[types.Vector]
+	if !embedding.Filter.IsEmpty() {
+		selected = filterVectors(embeddingCollection.GetAll(), embedding.Filter)
+		if len(selected) <= exactSearchLimit {
+			exact = true
+		}
+		selectedIDs := make(map[string]struct{}, len(selected))
+		for _, s := range selected {
+			selectedIDs[s.Key] = struct{}{}
+		}
+		keep = func(id string) bool {
+			_, ok := selectedIDs[id]
+			return ok
+		}
+	} else if exact {
+		selected = embeddingCollection.GetAll()
+	}
+	if exact {
+		return exactSearch(selected, embedding.Values, k), nil
 	}
+	return indexSearch(vectorIndex, embeddingCollection, embedding.Values, k, keep)
+}
+
+func filterVectors(vectors []types.KeyValue[types.Vector], filter types.MetadataFilter) []types.KeyValue[types.Vector] {
+	var selected []types.KeyValue[types.Vector]
+	for _, v := range vectors {
+		if filter.Match(v.Value.Metadata.Filename) {
+			selected = append(selected, v)
+		}
+	}
+	return selected
+}
+
+// exactSearch compares values with every vector of res.
+func exactSearch(res []types.KeyValue[types.Vector], values []float32, k int) types.SearchResults {
+	floatVectors := make([][]float32, len(res))
+	for i, r := range res {
+		floatVectors[i] = r.Value.Values
+	}
+
+	searchResults := types.SearchResults{}
 	//pcaResult := pca.EmbeddingsTo3D(floatVectors)
-	for _, r := range results[:k] {
+	for _, r := range cosine.SearchTopK(floatVectors, values, k) {
 		searchResults.Results = append(searchResults.Results, types.SearchResult{
-			Vector: vectors[r.Index],
+			Vector: res[r.Index].Value,
 			//PCA:        pcaResult[r.Index],
 			Similarity: r.Similarity,
 		})
 	}
+	return searchResults
+}
+
+// indexSearch finds the vectors most similar to values kept by keep in vectorIndex and saves the changes made to
+// the index since it was loaded.
+func indexSearch(vectorIndex types.VectorIndex, embeddingCollection types.DBCollectionInterface[types.Vector], values []float32, k int, keep func(id string) bool) (types.SearchResults, error) {
+	found, err := vectorIndex.Search(values, k, keep)
+	if err != nil {
+		return types.SearchResults{}, err
+	}
+	if err := vectorIndex.Save(); err != nil {
+		return types.SearchResults{}, err
+	}
+	searchResults := types.SearchResults{}
+	for _, f := range found {
+		vector, exists := embeddingCollection.Get(f.Key)
+		if !exists {
+			continue
+		}
+		searchResults.Results = append(searchResults.Results, types.SearchResult{Vector: vector, Similarity: f.Value})
+	}
 	return searchResults, nil
 }
 func (idx *embedStore) GetEmbeddingDocument(ctx context.Context, docID string) (types.Vector, bool, error) {
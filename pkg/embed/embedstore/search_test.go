@@ -0,0 +1,77 @@
+package embedstore
+
+import (
+	"context"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/project"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func TestSearchWithEmbedding_givenIndexAndExactSearch_expectSameResults(t *testing.T) {
+	p := newMemoryProject(t)
+	ctx := newContext(p, "ada2")
+	vectors := []types.Vector{
+		{ID: "a", Values: []float32{1, 0, 0}},
+		{ID: "b", Values: []float32{0.9, 0.1, 0}},
+		{ID: "c", Values: []float32{0, 1, 0}},
+		{ID: "d", Values: []float32{0, 0, 1}},
+	}
+	if _, err := EmbedStore.AddEmbeddingDocuments(ctx, vectors); err != nil {
+		t.Fatal(err)
+	}
+	if err := EmbedStore.DeleteEmbeddingDocument(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	exactCtx := project.SetProjectInContext(config.NewContext(context.Background(), config.Configuration{EmbedModel: "ada2", ExactSearch: true}), p)
+
+	query := types.QueryFilter{Values: []float32{1, 0.05, 0}}
+	indexed, err := EmbedStore.SearchWithEmbedding(ctx, query, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exact, err := EmbedStore.SearchWithEmbedding(exactCtx, query, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed.Results) != 2 || len(exact.Results) != 2 {
+		t.Fatalf("expected two results each, got %+v and %+v", indexed.Results, exact.Results)
+	}
+	for i := range exact.Results {
+		if indexed.Results[i].Vector.ID != exact.Results[i].Vector.ID {
+			t.Errorf("result %d: index found %s, exact search %s", i, indexed.Results[i].Vector.ID, exact.Results[i].Vector.ID)
+		}
+	}
+	if indexed.Results[0].Vector.ID != "b" {
+		t.Errorf("expected the deleted a to be skipped, got %s first", indexed.Results[0].Vector.ID)
+	}
+}
+
+func TestSearchWithEmbedding_givenFilter_expectOnlySelectedFilesRanked(t *testing.T) {
+	p := newMemoryProject(t)
+	ctx := newContext(p, "ada2")
+	vectors := []types.Vector{
+		{ID: "a", Values: []float32{1, 0, 0}, Metadata: types.Metadata{Filename: "pkg/a_test.go"}},
+		{ID: "b", Values: []float32{0.9, 0.1, 0}, Metadata: types.Metadata{Filename: "pkg/b.go"}},
+		{ID: "c", Values: []float32{0, 1, 0}, Metadata: types.Metadata{Filename: "pkg/c.go"}},
+		{ID: "d", Values: []float32{1, 0, 0}, Metadata: types.Metadata{Filename: "docs/d.md"}},
+	}
+	if _, err := EmbedStore.AddEmbeddingDocuments(ctx, vectors); err != nil {
+		t.Fatal(err)
+	}
+	query := types.QueryFilter{
+		Values: []float32{1, 0, 0},
+		Filter: types.MetadataFilter{
+			Include: types.FileMatcher{Paths: []string{"pkg"}},
+			Exclude: types.FileMatcher{Paths: []string{"*_test.go"}},
+		},
+	}
+	results, err := EmbedStore.SearchWithEmbedding(ctx, query, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Results) != 2 || results.Results[0].Vector.ID != "b" || results.Results[1].Vector.ID != "c" {
+		t.Errorf("expected b and c, got %+v", results.Results)
+	}
+}
@@ -61,7 +61,7 @@ func groupConsecutiveMetadata(searchResults []types.SearchResult) []types.Search
 		if len(currentGroup) == 0 {
 			currentGroup = append(currentGroup, searchResults[i])
 		} else {
-			if searchResults[i].Vector.Metadata.Start == currentGroup[len(currentGroup)-1].Vector.Metadata.Start+200 {
+			if follows(currentGroup[len(currentGroup)-1].Vector.Metadata, searchResults[i].Vector.Metadata) {
 				currentGroup = append(currentGroup, searchResults[i])
 			} else {
 				resultWithConcatenatedMetadata := concatenateConsecutiveMetadata(currentGroup)
@@ -80,6 +80,14 @@ func groupConsecutiveMetadata(searchResults []types.SearchResult) []types.Search
 	return resultsWithConsecutive
 }
 
+// follows reports whether the chunk of next comes right after the chunk of prev in their file.
+func follows(prev, next types.Metadata) bool {
+	if prev.Chunker == "" {
+		return next.Start == prev.Start+200
+	}
+	return next.Start == prev.End
+}
+
 // Concatenates consecutive metadata from a group of search results
 func concatenateConsecutiveMetadata(searchResults []types.SearchResult) types.SearchResult {
 	if len(searchResults) == 1 {
@@ -91,19 +99,50 @@ func concatenateConsecutiveMetadata(searchResults []types.SearchResult) types.Se
 	first := searchResults[0].Vector.Metadata
 	last := searchResults[len(searchResults)-1].Vector.Metadata
 	filename := searchResults[0].Vector.Metadata.Filename
+	var rerankScore *int
+	for _, sr := range searchResults {
+		if sr.RerankScore != nil && (rerankScore == nil || *sr.RerankScore > *rerankScore) {
+			rerankScore = sr.RerankScore
+		}
+	}
+	symbol, receiver := concatSymbols(searchResults)
 	return types.SearchResult{
+		RerankScore: rerankScore,
 		Vector: types.Vector{
 			Metadata: types.Metadata{
 				Filename:     filename,
 				Start:        first.Start,
 				LineStart:    first.LineStart,
 				End:          last.End,
+				LineEnd:      last.LineEnd,
 				TruncatedEnd: last.TruncatedEnd,
+				Symbol:       symbol,
+				Receiver:     receiver,
+				Chunker:      first.Chunker,
 				SplitPart:    concatSplitPart(filename, searchResults),
 			},
 		},
 	}
 }
+
+// concatSymbols returns the symbol and receiver of the chunks of a group, joining the qualified symbols of chunks
+// holding different declarations.
+func concatSymbols(searchResults []types.SearchResult) (string, string) {
+	first := searchResults[0].Vector.Metadata
+	symbols := []string{}
+	seen := map[string]bool{}
+	for _, sr := range searchResults {
+		symbol := sr.Vector.Metadata.QualifiedSymbol()
+		if symbol != "" && !seen[symbol] {
+			seen[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	if len(symbols) == 1 && symbols[0] == first.QualifiedSymbol() {
+		return first.Symbol, first.Receiver
+	}
+	return strings.Join(symbols, ", "), ""
+}
 func concatSplitPart(filename string, searchResults []types.SearchResult) string {
 	var splitPart strings.Builder = strings.Builder{}
 	if len(searchResults) <= 1 {
@@ -170,3 +170,27 @@ func TestGroupConsecutiveMetadata(t *testing.T) {
 		}
 	}
 }
+
+func TestGroupConsecutiveMetadata_givenSyntaxChunks_expectGroupedByOffsets(t *testing.T) {
+	chunk := func(start, end, lineEnd int, symbol string) types.SearchResult {
+		return types.SearchResult{Vector: types.Vector{Metadata: types.Metadata{
+			Filename: "a.go", Start: start, End: end, LineEnd: lineEnd, Symbol: symbol, Receiver: "Embedder", Chunker: "go1-200",
+			SplitPart: "####embedding from file: a.go\n" + symbol, RealSplitPart: symbol,
+		}}}
+	}
+	got := groupConsecutiveMetadata([]types.SearchResult{chunk(0, 120, 8, "New"), chunk(120, 330, 20, "Run"), chunk(400, 600, 30, "Close")})
+
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(got))
+	}
+	first := got[0].Vector.Metadata
+	if first.Start != 0 || first.End != 330 || first.LineEnd != 20 {
+		t.Errorf("Expected the first two chunks grouped, got %+v", first)
+	}
+	if first.QualifiedSymbol() != "Embedder.New, Embedder.Run" {
+		t.Errorf("Expected both symbols, got %q", first.QualifiedSymbol())
+	}
+	if got[1].Vector.Metadata.QualifiedSymbol() != "Embedder.Close" {
+		t.Errorf("Expected the last chunk alone, got %+v", got[1].Vector.Metadata)
+	}
+}
@@ -1,6 +1,8 @@
 package embed
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"io"
 	"io/fs"
@@ -9,17 +11,25 @@ import (
 	"github.com/tzapio/tzap/pkg/types"
 )
 
+// FilestampCache tells the files changed since an index was built from the types.FileStamp of the files it was
+// built from. The edit time and size of a file are a fast pre-check; when they differ, the file is read and only
+// counts as changed when its content hash differs. So a touched or checked out file, or an index copied to another
+// machine, does not index unchanged files again.
 type FilestampCache struct {
-	filesTimestampsDB types.DBCollectionInterface[int64]
+	filesTimestampsDB types.DBCollectionInterface[types.FileStamp]
+	// read holds the stamps of the files read by CheckFileCache, so that CacheFilestamps records the content that
+	// was indexed even when a file changes meanwhile.
+	read map[string]types.FileStamp
 }
 
-func NewFilestampCache(filesTimestampsDB types.DBCollectionInterface[int64]) *FilestampCache {
-	return &FilestampCache{filesTimestampsDB: filesTimestampsDB}
+func NewFilestampCache(filesTimestampsDB types.DBCollectionInterface[types.FileStamp]) *FilestampCache {
+	return &FilestampCache{filesTimestampsDB: filesTimestampsDB, read: map[string]types.FileStamp{}}
 }
 func (fc *FilestampCache) CheckFileCache(files []types.FileReader) (changedFiles map[string]string, unchangedFiles map[string]int64) {
 	tl.Logger.Println("Checking file cache. Files:", len(files))
 	changedFiles = map[string]string{}
 	unchangedFiles = map[string]int64{}
+	var touched []types.KeyValue[types.FileStamp]
 
 	for _, file := range files {
 		fileName := file.FilePath()
@@ -31,41 +41,49 @@ func (fc *FilestampCache) CheckFileCache(files []types.FileReader) (changedFiles
 			continue
 		}
 		currentEditTime := fileStats.ModTime().UnixNano()
-		cachedEditTime, exists := fc.filesTimestampsDB.Get(fileName)
-		if exists && !isTimeDiffSignificant(currentEditTime, cachedEditTime) {
-			tl.DeepLogger.Printf("NO CHANGE %s. Old Edittime: %d, New Edittime: %d, TimeDiff: %d", fileName, cachedEditTime, currentEditTime, cachedEditTime-currentEditTime)
-			unchangedFiles[fileName] = cachedEditTime
+		cached, exists := fc.filesTimestampsDB.Get(fileName)
+		if exists && cached.Size == fileStats.Size() && !isTimeDiffSignificant(currentEditTime, cached.ModTime) {
+			tl.DeepLogger.Printf("NO CHANGE %s. Old Edittime: %d, New Edittime: %d, TimeDiff: %d", fileName, cached.ModTime, currentEditTime, cached.ModTime-currentEditTime)
+			unchangedFiles[fileName] = cached.ModTime
 			continue
 		}
-		readCloser, err := file.Open()
+		fileContent, err := readFile(file)
 		if err != nil {
 			println(err.Error())
 			continue
 		}
-		fileContent, err := io.ReadAll(readCloser)
-		if err != nil {
-			println(err.Error())
+		stamp := types.FileStamp{ModTime: currentEditTime, Size: fileStats.Size(), Hash: hashContent(fileContent)}
+		if exists && cached.Hash == stamp.Hash {
+			tl.DeepLogger.Printf("NO CHANGE %s. Same content, new Edittime: %d", fileName, currentEditTime)
+			unchangedFiles[fileName] = stamp.ModTime
+			touched = append(touched, types.KeyValue[types.FileStamp]{Key: fileName, Value: stamp})
 			continue
 		}
-		fileContentStr := string(fileContent)
-		tl.Logger.Printf("File %s has changed. Old Edittime: %d, New Edittime: %d, TimeDiff: %d", fileName, cachedEditTime, currentEditTime, cachedEditTime-currentEditTime)
-		changedFiles[fileName] = fileContentStr
+		tl.Logger.Printf("File %s has changed. Old Edittime: %d, New Edittime: %d, TimeDiff: %d", fileName, cached.ModTime, currentEditTime, cached.ModTime-currentEditTime)
+		fc.read[fileName] = stamp
+		changedFiles[fileName] = string(fileContent)
+	}
+	if len(touched) > 0 {
+		// Recording the new edit times of unchanged files keeps the next check from reading them again.
+		if _, err := fc.filesTimestampsDB.BatchSet(touched); err != nil {
+			tl.Logger.Println("Failed to update the edit times of unchanged files:", err)
+		}
 	}
-	tl.Logger.Println("Finished checking file cache. Changed files:", len(changedFiles), "Unchanged files:", len(unchangedFiles))
+	tl.Logger.Println("Finished checking file cache. Changed files:", len(changedFiles), "Unchanged files:", len(unchangedFiles), "Touched files:", len(touched))
 	return changedFiles, unchangedFiles
 }
 
 func (fc *FilestampCache) CacheFilestamps(embeddings *types.Embeddings, files []types.FileReader) error {
 	if len(embeddings.Vectors) > 0 {
-		var keyvals []types.KeyValue[int64]
+		var keyvals []types.KeyValue[types.FileStamp]
 		for _, vector := range embeddings.Vectors {
 			for _, fileReader := range files {
 				if fileReader.FilePath() == vector.Metadata.Filename {
-					fileStat, err := fileReader.Stat()
+					stamp, err := fc.stamp(fileReader)
 					if err != nil {
 						return err
 					}
-					keyvals = append(keyvals, types.KeyValue[int64]{Key: vector.Metadata.Filename, Value: fileStat.ModTime().UnixNano()})
+					keyvals = append(keyvals, types.KeyValue[types.FileStamp]{Key: vector.Metadata.Filename, Value: stamp})
 				}
 			}
 		}
@@ -77,3 +95,35 @@ func (fc *FilestampCache) CacheFilestamps(embeddings *types.Embeddings, files []
 	}
 	return nil
 }
+
+// stamp returns the stamp of file when CheckFileCache read it, and reads it otherwise.
+func (fc *FilestampCache) stamp(file types.FileReader) (types.FileStamp, error) {
+	if stamp, ok := fc.read[file.FilePath()]; ok {
+		return stamp, nil
+	}
+	fileStat, err := file.Stat()
+	if err != nil {
+		return types.FileStamp{}, err
+	}
+	content, err := readFile(file)
+	if err != nil {
+		return types.FileStamp{}, err
+	}
+	stamp := types.FileStamp{ModTime: fileStat.ModTime().UnixNano(), Size: fileStat.Size(), Hash: hashContent(content)}
+	fc.read[file.FilePath()] = stamp
+	return stamp, nil
+}
+
+func readFile(file types.FileReader) ([]byte, error) {
+	readCloser, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+	return io.ReadAll(readCloser)
+}
+
+func hashContent(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
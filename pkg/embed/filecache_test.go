@@ -0,0 +1,69 @@
+package embed_test
+
+import (
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/embed/localdb"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func indexFiles(t *testing.T, cache *embed.FilestampCache, files ...types.FileReader) {
+	changed, _ := cache.CheckFileCache(files)
+	embeddings := &types.Embeddings{}
+	for filename := range changed {
+		embeddings.Vectors = append(embeddings.Vectors, &types.Vector{Metadata: types.Metadata{Filename: filename}})
+	}
+	if err := cache.CacheFilestamps(embeddings, files); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckFileCache_givenTouchedFile_expectUnchanged(t *testing.T) {
+	db, _ := localdb.NewFileDB[types.FileStamp]("@MEMORY/stamps")
+	indexFiles(t, embed.NewFilestampCache(db), fileReader{path: "a.go", content: "package a", modTime: 10})
+
+	// A new FilestampCache, as on another machine where all edit times differ.
+	changed, unchanged := embed.NewFilestampCache(db).CheckFileCache([]types.FileReader{fileReader{path: "a.go", content: "package a", modTime: 500}})
+	if len(changed) != 0 || len(unchanged) != 1 {
+		t.Fatalf("Expected a.go unchanged, got changed %v", changed)
+	}
+	if stamp, _ := db.Get("a.go"); stamp.ModTime != 500e9 {
+		t.Errorf("Expected the new edit time recorded, got %+v", stamp)
+	}
+}
+
+func TestCheckFileCache_givenChangedContent_expectChanged(t *testing.T) {
+	db, _ := localdb.NewFileDB[types.FileStamp]("@MEMORY/stamps")
+	indexFiles(t, embed.NewFilestampCache(db), fileReader{path: "a.go", content: "package a", modTime: 10}, fileReader{path: "b.go", content: "package b", modTime: 10})
+
+	changed, unchanged := embed.NewFilestampCache(db).CheckFileCache([]types.FileReader{
+		fileReader{path: "a.go", content: "package a // edited", modTime: 20},
+		// Same edit time and size, so the pre-check does not read it.
+		fileReader{path: "b.go", content: "package c", modTime: 10},
+	})
+	if changed["a.go"] != "package a // edited" || len(changed) != 1 {
+		t.Errorf("Expected a.go changed, got %v", changed)
+	}
+	if _, ok := unchanged["b.go"]; !ok {
+		t.Errorf("Expected b.go unchanged, got %v", unchanged)
+	}
+}
+
+func TestCacheFilestamps_givenFileEditedAfterCheck_expectCheckedContentRecorded(t *testing.T) {
+	db, _ := localdb.NewFileDB[types.FileStamp]("@MEMORY/stamps")
+	cache := embed.NewFilestampCache(db)
+	changed, _ := cache.CheckFileCache([]types.FileReader{fileReader{path: "a.go", content: "package a", modTime: 10}})
+	if len(changed) != 1 {
+		t.Fatalf("Expected a new file changed, got %v", changed)
+	}
+	edited := fileReader{path: "a.go", content: "package a // edited", modTime: 20}
+	if err := cache.CacheFilestamps(&types.Embeddings{Vectors: []*types.Vector{{Metadata: types.Metadata{Filename: "a.go"}}}}, []types.FileReader{edited}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, _ = embed.NewFilestampCache(db).CheckFileCache([]types.FileReader{edited})
+	if len(changed) != 1 {
+		t.Errorf("Expected the edit after the check to be indexed next time, got %v", changed)
+	}
+}
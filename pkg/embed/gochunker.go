@@ -0,0 +1,122 @@
+package embed
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// GoChunker cuts Go source along its top-level declarations. Declarations smaller than Size tokens are packed
+// together, declarations larger than 4*Size are split at their statements, specs or lines. The chunks cover the
+// whole file, so a chunk starts where the previous one ends. Source that does not parse is cut into token windows.
+type GoChunker struct {
+	Size int
+}
+
+func (c GoChunker) Version() string {
+	return fmt.Sprintf("go1-%d", c.Size)
+}
+
+func (c GoChunker) Chunk(t *tzap.Tzap, filename string, content string) ([]*types.Vector, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+	if err != nil {
+		tl.Logger.Println("Chunking", filename, "into token windows:", err)
+		return WindowChunker{Size: c.Size, version: c.Version()}.Chunk(t, filename, content)
+	}
+	tokenFile := fset.File(f.Pos())
+
+	spans := []span{}
+	start := 0
+	for i, decl := range f.Decls {
+		end := lineEnd(content, tokenFile.Offset(decl.End()))
+		if i == len(f.Decls)-1 {
+			end = len(content)
+		}
+		symbol, receiver := declSymbol(decl)
+		split, err := c.splitDecl(t, content, tokenFile, decl, span{start: start, end: end, symbol: symbol, receiver: receiver})
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, split...)
+		start = end
+	}
+	if start < len(content) {
+		rest, err := countSpan(t, content, span{start: start, end: len(content)})
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, rest)
+	}
+	tl.Logger.Println("Filename", filename, "Go declarations", len(f.Decls))
+	return spanVectors(filename, content, c.Version(), packSpans(spans, c.Size)), nil
+}
+
+// splitDecl returns s, the whole of decl, as parts of at most 4*Size tokens where possible.
+func (c GoChunker) splitDecl(t *tzap.Tzap, content string, tokenFile *token.File, decl ast.Decl, s span) ([]span, error) {
+	s, err := countSpan(t, content, s)
+	if err != nil || s.tokens <= 4*c.Size {
+		return []span{s}, err
+	}
+
+	var nodes []ast.Node
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Body != nil {
+			for _, stmt := range d.Body.List {
+				nodes = append(nodes, stmt)
+			}
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			nodes = append(nodes, spec)
+		}
+	}
+	cuts := []int{}
+	for _, node := range nodes[min(1, len(nodes)):] {
+		cuts = append(cuts, lineStart(content, tokenFile.Offset(node.Pos())))
+	}
+	return splitSpan(t, content, s, cuts, 4*c.Size)
+}
+
+// declSymbol returns the name of decl and the type name of its receiver. A const, var or type block is named after
+// its first spec.
+func declSymbol(decl ast.Decl) (string, string) {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv != nil && len(d.Recv.List) > 0 {
+			return d.Name.Name, receiverName(d.Recv.List[0].Type)
+		}
+		return d.Name.Name, ""
+	case *ast.GenDecl:
+		if len(d.Specs) == 0 {
+			return "", ""
+		}
+		switch s := d.Specs[0].(type) {
+		case *ast.TypeSpec:
+			return s.Name.Name, ""
+		case *ast.ValueSpec:
+			return s.Names[0].Name, ""
+		}
+	}
+	return "", ""
+}
+
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
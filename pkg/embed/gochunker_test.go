@@ -0,0 +1,123 @@
+package embed_test
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// wordTG takes the words of a text, with the spaces before them, as its tokens.
+type wordTG struct {
+	types.TGenerator
+}
+
+var wordRegexp = regexp.MustCompile(`\s*\S+`)
+
+func (tg *wordTG) CountTokens(ctx context.Context, content string) (int, error) {
+	return len(strings.Fields(content)), nil
+}
+
+func (tg *wordTG) OffsetTokens(ctx context.Context, content string, from int, to int) (string, int, error) {
+	words := wordRegexp.FindAllString(content, -1)
+	if to > len(words) {
+		to = len(words)
+	}
+	return strings.Join(words[from:to], ""), to - from, nil
+}
+
+func newWordTzap() *tzap.Tzap {
+	return tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return &wordTG{}, config.Configuration{}
+	})
+}
+
+const goSource = `package sample
+
+import "fmt"
+
+// Store keeps values.
+type Store struct {
+	values map[string]int
+}
+
+// Get returns the value of key.
+func (s *Store) Get(key string) int {
+	return s.values[key]
+}
+
+func Print(s *Store) {
+	fmt.Println(s.values)
+}
+`
+
+func TestGoChunker_givenSmallDeclarations_expectPackedChunkCoveringFile(t *testing.T) {
+	vectors, err := embed.GoChunker{Size: 200}.Chunk(newWordTzap(), "sample.go", goSource)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 1 {
+		t.Fatalf("Expected the small declarations packed into 1 chunk, got %d", len(vectors))
+	}
+	metadata := vectors[0].Metadata
+	if metadata.RealSplitPart != goSource || metadata.Start != 0 || metadata.End != len(goSource) {
+		t.Errorf("Expected the chunk to cover the file, got %d to %d", metadata.Start, metadata.End)
+	}
+	if metadata.Symbol != "Store, Get, Print" || metadata.LineStart != 1 || metadata.LineEnd != 17 {
+		t.Errorf("Expected the symbols and lines of the file, got %+v", metadata)
+	}
+}
+
+func TestGoChunker_givenLargeFunction_expectSplitAtStatements(t *testing.T) {
+	var source strings.Builder
+	source.WriteString("package sample\n\n// Large is large.\nfunc (s *Store) Large() {\n")
+	for i := 0; i < 300; i++ {
+		source.WriteString("\ts.add(1, 2, 3)\n")
+	}
+	source.WriteString("}\n\nfunc Small() {}\n")
+	content := source.String()
+
+	vectors, err := embed.GoChunker{Size: 200}.Chunk(newWordTzap(), "sample.go", content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) < 3 {
+		t.Fatalf("Expected the large function split and the small one apart, got %d chunks", len(vectors))
+	}
+	var joined strings.Builder
+	for i, v := range vectors {
+		joined.WriteString(v.Metadata.RealSplitPart)
+		if i > 0 && v.Metadata.Start != vectors[i-1].Metadata.End {
+			t.Errorf("Expected chunk %d to start where the previous one ends, got %d and %d", i, v.Metadata.Start, vectors[i-1].Metadata.End)
+		}
+		if i < len(vectors)-1 {
+			if v.Metadata.QualifiedSymbol() != "Store.Large" {
+				t.Errorf("Expected chunk %d of Store.Large, got %q", i, v.Metadata.QualifiedSymbol())
+			}
+			if !strings.HasSuffix(v.Metadata.RealSplitPart, "\n") {
+				t.Errorf("Expected chunk %d to end at a statement, got %q", i, v.Metadata.RealSplitPart)
+			}
+		}
+	}
+	if joined.String() != content {
+		t.Errorf("Expected the chunks to cover the file")
+	}
+	if last := vectors[len(vectors)-1].Metadata; last.Symbol != "Small" {
+		t.Errorf("Expected the last chunk to hold Small, got %q", last.Symbol)
+	}
+}
+
+func TestGoChunker_givenInvalidSource_expectTokenWindows(t *testing.T) {
+	vectors, err := embed.GoChunker{Size: 200}.Chunk(newWordTzap(), "broken.go", "package broken\nfunc {")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 1 || vectors[0].Metadata.Chunker != "go1-200" || vectors[0].Metadata.Symbol != "" {
+		t.Errorf("Expected one window of the go chunker, got %+v", vectors)
+	}
+}
@@ -0,0 +1,342 @@
+// Package hnsw is an approximate nearest neighbour index of embeddings, a hierarchical navigable small world graph
+// (Malkov and Yashunin, 2016). A search visits a few hundred vectors instead of comparing the query with all of them.
+// Vectors are normalized when added, so the cosine similarity of two vectors is their dot product.
+package hnsw
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// Options tune the graph. The zero value of a field picks its default.
+type Options struct {
+	// M is the number of neighbours of a node on the upper layers, it has 2*M on the bottom layer. Defaults to 16.
+	M int
+	// EfConstruction is the number of candidates considered when linking a new node. Defaults to 200.
+	EfConstruction int
+	// EfSearch is the number of candidates considered by a search, at least k. Defaults to 64.
+	EfSearch int
+}
+
+func (o Options) withDefaults() Options {
+	if o.M <= 0 {
+		o.M = 16
+	}
+	if o.EfConstruction <= 0 {
+		o.EfConstruction = 200
+	}
+	if o.EfSearch <= 0 {
+		o.EfSearch = 64
+	}
+	return o
+}
+
+type node struct {
+	id        string
+	vector    []float32
+	neighbors [][]int32
+	deleted   bool
+}
+
+// Result is a vector found by Search.
+type Result struct {
+	ID         string
+	Similarity float32
+}
+
+// Index is a graph of vectors identified by string ids. It is safe for concurrent use.
+type Index struct {
+	lock      sync.RWMutex
+	options   Options
+	nodes     []*node
+	byID      map[string]int32
+	entry     int32
+	maxLevel  int
+	deleted   int
+	levelMult float64
+	rng       *rand.Rand
+}
+
+// New returns an empty index.
+func New(options Options) *Index {
+	options = options.withDefaults()
+	return &Index{
+		options:   options,
+		byID:      map[string]int32{},
+		entry:     -1,
+		levelMult: 1 / math.Log(float64(options.M)),
+		rng:       rand.New(rand.NewSource(1)),
+	}
+}
+
+// Len returns the number of vectors in the index.
+func (idx *Index) Len() int {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	return len(idx.byID)
+}
+
+// Add adds the vector of id, replacing the previous vector of id unless it is the same.
+func (idx *Index) Add(id string, vector []float32) {
+	normalized := normalize(vector)
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	if i, ok := idx.byID[id]; ok {
+		if equal(idx.nodes[i].vector, normalized) {
+			return
+		}
+		idx.nodes[i].deleted = true
+		idx.deleted++
+	}
+	idx.insert(id, normalized, idx.randomLevel())
+}
+
+// Delete removes the vector of id. Its node stays in the graph to keep it connected, but is not returned by Search.
+func (idx *Index) Delete(id string) {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	if i, ok := idx.byID[id]; ok {
+		idx.nodes[i].deleted = true
+		idx.deleted++
+		delete(idx.byID, id)
+	}
+}
+
+// Search returns about the k vectors most similar to query for which keep returns true, best first.
+// A nil keep keeps every vector. When keep drops most of the candidates the search widens until it finds k vectors
+// or has visited the whole graph.
+func (idx *Index) Search(query []float32, k int, keep func(id string) bool) []Result {
+	idx.lock.RLock()
+	defer idx.lock.RUnlock()
+	if idx.entry < 0 || k <= 0 {
+		return nil
+	}
+	q := normalize(query)
+	ep := idx.entry
+	for level := idx.maxLevel; level > 0; level-- {
+		ep = idx.greedy(q, ep, level)
+	}
+	ef := idx.options.EfSearch
+	if k > ef {
+		ef = k
+	}
+	for {
+		results := []Result{}
+		candidates := idx.searchLayer(q, ep, ef, 0)
+		for _, c := range candidates {
+			if n := idx.nodes[c.id]; !n.deleted && (keep == nil || keep(n.id)) {
+				results = append(results, Result{ID: n.id, Similarity: c.similarity})
+				if len(results) == k {
+					return results
+				}
+			}
+		}
+		if len(candidates) < ef {
+			return results
+		}
+		ef *= 4
+	}
+}
+
+func (idx *Index) randomLevel() int {
+	return int(-math.Log(1-idx.rng.Float64()) * idx.levelMult)
+}
+
+func (idx *Index) maxNeighbors(level int) int {
+	if level == 0 {
+		return 2 * idx.options.M
+	}
+	return idx.options.M
+}
+
+func (idx *Index) insert(id string, vector []float32, level int) {
+	n := &node{id: id, vector: vector, neighbors: make([][]int32, level+1)}
+	i := int32(len(idx.nodes))
+	idx.nodes = append(idx.nodes, n)
+	idx.byID[id] = i
+	if idx.entry < 0 {
+		idx.entry, idx.maxLevel = i, level
+		return
+	}
+	ep := idx.entry
+	for l := idx.maxLevel; l > level; l-- {
+		ep = idx.greedy(vector, ep, l)
+	}
+	for l := min(level, idx.maxLevel); l >= 0; l-- {
+		candidates := idx.searchLayer(vector, ep, idx.options.EfConstruction, l)
+		n.neighbors[l] = idx.selectNeighbors(candidates, idx.maxNeighbors(l))
+		for _, neighbor := range n.neighbors[l] {
+			idx.link(neighbor, i, l)
+		}
+		ep = candidates[0].id
+	}
+	if level > idx.maxLevel {
+		idx.entry, idx.maxLevel = i, level
+	}
+}
+
+// link adds to as a neighbour of from on level, keeping the best neighbours when from has too many.
+func (idx *Index) link(from, to int32, level int) {
+	n := idx.nodes[from]
+	n.neighbors[level] = append(n.neighbors[level], to)
+	if len(n.neighbors[level]) <= idx.maxNeighbors(level) {
+		return
+	}
+	candidates := make([]candidate, len(n.neighbors[level]))
+	for j, neighbor := range n.neighbors[level] {
+		candidates[j] = candidate{id: neighbor, similarity: dot(n.vector, idx.nodes[neighbor].vector)}
+	}
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].similarity > candidates[b].similarity })
+	n.neighbors[level] = idx.selectNeighbors(candidates, idx.maxNeighbors(level))
+}
+
+// selectNeighbors picks up to m of candidates, best first, preferring candidates closer to the new node than to
+// the neighbours already picked so that the graph links distinct directions. It fills up with the skipped ones.
+func (idx *Index) selectNeighbors(candidates []candidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) == m {
+			break
+		}
+		diverse := true
+		for _, s := range selected {
+			if dot(idx.nodes[c.id].vector, idx.nodes[s].vector) > c.similarity {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+	for _, s := range skipped {
+		if len(selected) == m {
+			break
+		}
+		selected = append(selected, s)
+	}
+	return selected
+}
+
+// greedy walks level from ep towards query and returns the closest node it finds.
+func (idx *Index) greedy(query []float32, ep int32, level int) int32 {
+	best := dot(query, idx.nodes[ep].vector)
+	for changed := true; changed; {
+		changed = false
+		for _, neighbor := range idx.nodes[ep].neighbors[level] {
+			if similarity := dot(query, idx.nodes[neighbor].vector); similarity > best {
+				best, ep, changed = similarity, neighbor, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer returns the ef nodes of level closest to query found from ep, best first.
+func (idx *Index) searchLayer(query []float32, ep int32, ef int, level int) []candidate {
+	visited := map[int32]bool{ep: true}
+	first := candidate{id: ep, similarity: dot(query, idx.nodes[ep].vector)}
+	candidates := &maxHeap{first}
+	results := &minHeap{first}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(candidate)
+		if results.Len() >= ef && c.similarity < (*results)[0].similarity {
+			break
+		}
+		for _, neighbor := range idx.nodes[c.id].neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			similarity := dot(query, idx.nodes[neighbor].vector)
+			if results.Len() < ef || similarity > (*results)[0].similarity {
+				heap.Push(candidates, candidate{id: neighbor, similarity: similarity})
+				heap.Push(results, candidate{id: neighbor, similarity: similarity})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	sorted := make([]candidate, results.Len())
+	for i := len(sorted) - 1; i >= 0; i-- {
+		sorted[i] = heap.Pop(results).(candidate)
+	}
+	return sorted
+}
+
+type candidate struct {
+	id         int32
+	similarity float32
+}
+
+type maxHeap []candidate
+
+func (h maxHeap) Len() int            { return len(h) }
+func (h maxHeap) Less(i, j int) bool  { return h[i].similarity > h[j].similarity }
+func (h maxHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *maxHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+type minHeap []candidate
+
+func (h minHeap) Len() int            { return len(h) }
+func (h minHeap) Less(i, j int) bool  { return h[i].similarity < h[j].similarity }
+func (h minHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *minHeap) Push(x interface{}) { *h = append(*h, x.(candidate)) }
+func (h *minHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func dot(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return sum
+}
+
+func normalize(vector []float32) []float32 {
+	magnitude := float32(math.Sqrt(float64(dot(vector, vector))))
+	normalized := make([]float32, len(vector))
+	if magnitude == 0 {
+		return normalized
+	}
+	for i, v := range vector {
+		normalized[i] = v / magnitude
+	}
+	return normalized
+}
+
+func equal(a, b []float32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,142 @@
+package hnsw_test
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed/cosine"
+	"github.com/tzapio/tzap/pkg/embed/hnsw"
+	"github.com/tzapio/tzap/pkg/embed/localdb"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func randomVectors(n, dimensions int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func id(i int) string {
+	return string(rune('a'+i%26)) + string(rune('a'+i/26%26)) + string(rune('a'+i/676))
+}
+
+// recall returns the share of the k nearest vectors found by search, using exact cosine search as the truth.
+func recall(t *testing.T, vectors, queries [][]float32, k int, search func(query []float32) []string) float64 {
+	t.Helper()
+	found := 0
+	for _, query := range queries {
+		want := map[string]bool{}
+		for _, r := range cosine.SearchTopK(vectors, query, k) {
+			want[id(r.Index)] = true
+		}
+		for _, got := range search(query) {
+			if want[got] {
+				found++
+			}
+		}
+	}
+	return float64(found) / float64(k*len(queries))
+}
+
+func TestSearch_givenRandomVectors_expectRecallOfExactSearch(t *testing.T) {
+	vectors := randomVectors(2000, 32, 1)
+	index := hnsw.New(hnsw.Options{})
+	for i, v := range vectors {
+		index.Add(id(i), v)
+	}
+	got := recall(t, vectors, randomVectors(50, 32, 2), 10, func(query []float32) []string {
+		var ids []string
+		for _, r := range index.Search(query, 10, nil) {
+			ids = append(ids, r.ID)
+		}
+		return ids
+	})
+	if got < 0.9 {
+		t.Errorf("expected a recall@10 of at least 0.9, got %.3f", got)
+	}
+}
+
+func TestSearch_givenDeletedVector_expectNotFound(t *testing.T) {
+	index := hnsw.New(hnsw.Options{})
+	index.Add("a", []float32{1, 0})
+	index.Add("b", []float32{0.8, 0.2})
+	index.Add("c", []float32{0, 1})
+	index.Delete("a")
+	results := index.Search([]float32{1, 0}, 1, nil)
+	if len(results) != 1 || results[0].ID != "b" {
+		t.Errorf("expected b, got %+v", results)
+	}
+	if index.Len() != 2 {
+		t.Errorf("expected 2 vectors, got %d", index.Len())
+	}
+}
+
+func TestStore_givenSavedGraph_expectLoadedInSyncWithCollection(t *testing.T) {
+	collection, err := localdb.NewFileDB[types.Vector]("@MEMORY/" + t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors := randomVectors(300, 16, 3)
+	for i, v := range vectors {
+		if err := collection.Set(id(i), types.Vector{ID: id(i), Values: v}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "fileembeddings.hnsw")
+	store := hnsw.NewStore(path, collection, hnsw.Options{})
+	if n, _ := store.Len(); n != 300 {
+		t.Fatalf("expected the store to index the collection, got %d vectors", n)
+	}
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes made to the collection after the graph was saved are picked up when it is loaded.
+	if err := collection.Set(id(0), types.Vector{}); err != nil {
+		t.Fatal(err)
+	}
+	added := []float32{1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1}
+	if err := collection.Set("new", types.Vector{ID: "new", Values: added}); err != nil {
+		t.Fatal(err)
+	}
+	loaded := hnsw.NewStore(path, collection, hnsw.Options{})
+	if n, _ := loaded.Len(); n != 300 {
+		t.Errorf("expected 300 vectors after one deletion and one addition, got %d", n)
+	}
+	results, err := loaded.Search(added, 1, nil)
+	if err != nil || len(results) != 1 || results[0].Key != "new" {
+		t.Errorf("expected the added vector, got %+v, %v", results, err)
+	}
+	if results, _ := loaded.Search(vectors[0], 1, nil); len(results) == 1 && results[0].Key == id(0) {
+		t.Errorf("expected the deleted vector to be gone")
+	}
+	if results, _ := loaded.Search(vectors[1], 1, nil); len(results) != 1 || results[0].Key != id(1) {
+		t.Errorf("expected %s, got %+v", id(1), results)
+	}
+}
+
+func TestSearch_givenKeep_expectOnlyKeptVectors(t *testing.T) {
+	vectors := randomVectors(500, 16, 4)
+	index := hnsw.New(hnsw.Options{})
+	kept := map[string]bool{}
+	for i, v := range vectors {
+		index.Add(id(i), v)
+		kept[id(i)] = i%50 == 0
+	}
+	results := index.Search(vectors[1], 10, func(id string) bool { return kept[id] })
+	if len(results) != 10 {
+		t.Fatalf("expected the search to widen until it finds 10 kept vectors, got %d", len(results))
+	}
+	for _, r := range results {
+		if !kept[r.ID] {
+			t.Errorf("expected only kept vectors, got %s", r.ID)
+		}
+	}
+}
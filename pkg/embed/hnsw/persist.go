@@ -0,0 +1,126 @@
+package hnsw
+
+import (
+	"bufio"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// version changes when the file format changes. Files of other versions are ignored and the graph is rebuilt.
+const version = 1
+
+// snapshot is the saved graph. The vectors are not saved, they are in the embedding collection.
+type snapshot struct {
+	Version   int
+	Options   Options
+	IDs       []string
+	Neighbors [][][]int32
+	Entry     int32
+	MaxLevel  int
+}
+
+// Save drops the deleted nodes and writes the graph to path. The file is replaced atomically.
+func (idx *Index) Save(path string) error {
+	idx.lock.Lock()
+	defer idx.lock.Unlock()
+	idx.compact()
+	s := snapshot{Version: version, Options: idx.options, Entry: idx.entry, MaxLevel: idx.maxLevel}
+	for _, n := range idx.nodes {
+		s.IDs = append(s.IDs, n.id)
+		s.Neighbors = append(s.Neighbors, n.neighbors)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	writer := bufio.NewWriter(file)
+	if err := gob.NewEncoder(writer).Encode(s); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// Load reads the graph saved at path, taking the vectors from vector. Nodes without a vector were deleted since the
+// graph was saved and are dropped; Load returns how many.
+func Load(path string, vector func(id string) ([]float32, bool)) (*Index, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	var s snapshot
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(&s); err != nil {
+		return nil, 0, fmt.Errorf("reading vector index %s: %w", path, err)
+	}
+	if s.Version != version || len(s.IDs) != len(s.Neighbors) {
+		return nil, 0, fmt.Errorf("vector index %s has version %d, not %d", path, s.Version, version)
+	}
+
+	idx := New(s.Options)
+	idx.entry, idx.maxLevel = s.Entry, s.MaxLevel
+	for i, id := range s.IDs {
+		n := &node{id: id, neighbors: s.Neighbors[i]}
+		if values, ok := vector(id); ok {
+			n.vector = normalize(values)
+			idx.byID[id] = int32(i)
+		} else {
+			n.deleted = true
+			idx.deleted++
+		}
+		idx.nodes = append(idx.nodes, n)
+	}
+	removed := idx.deleted
+	idx.compact()
+	return idx, removed, nil
+}
+
+// compact removes the deleted nodes from the graph and from the neighbours of the others.
+func (idx *Index) compact() {
+	if idx.deleted == 0 {
+		return
+	}
+	remap := make([]int32, len(idx.nodes))
+	var nodes []*node
+	for i, n := range idx.nodes {
+		if n.deleted {
+			remap[i] = -1
+			continue
+		}
+		remap[i] = int32(len(nodes))
+		nodes = append(nodes, n)
+	}
+	idx.entry, idx.maxLevel = -1, 0
+	idx.byID = map[string]int32{}
+	for i, n := range nodes {
+		for level, neighbors := range n.neighbors {
+			kept := neighbors[:0]
+			for _, neighbor := range neighbors {
+				if remap[neighbor] >= 0 {
+					kept = append(kept, remap[neighbor])
+				}
+			}
+			n.neighbors[level] = kept
+		}
+		idx.byID[n.id] = int32(i)
+		if idx.entry < 0 || len(n.neighbors)-1 > idx.maxLevel {
+			idx.entry, idx.maxLevel = int32(i), len(n.neighbors)-1
+		}
+	}
+	idx.nodes = nodes
+	idx.deleted = 0
+}
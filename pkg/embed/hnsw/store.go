@@ -0,0 +1,124 @@
+package hnsw
+
+import (
+	"os"
+	"sync"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Store is the Index of an embedding collection, saved to a file next to it. It implements types.VectorIndex.
+// The graph is loaded on first use and brought up to date with the collection, which holds the vectors, so a graph
+// saved before the collection last changed only misses links. An empty path keeps the graph in memory.
+type Store struct {
+	path       string
+	collection types.DBCollectionInterface[types.Vector]
+	options    Options
+
+	lock    sync.Mutex
+	index   *Index
+	changed bool
+}
+
+// NewStore returns the Store of collection saved at path.
+func NewStore(path string, collection types.DBCollectionInterface[types.Vector], options Options) *Store {
+	return &Store{path: path, collection: collection, options: options}
+}
+
+func (s *Store) load() *Index {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.index != nil {
+		return s.index
+	}
+	vectors := s.collection.GetAll()
+	byID := make(map[string][]float32, len(vectors))
+	for _, v := range vectors {
+		byID[v.Key] = v.Value.Values
+	}
+
+	var index *Index
+	if s.path != "" {
+		loaded, removed, err := Load(s.path, func(id string) ([]float32, bool) {
+			values, ok := byID[id]
+			return values, ok
+		})
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			tl.Logger.Println("Rebuilding vector index:", err)
+		case removed > len(loaded.nodes)/3:
+			// Dropping many nodes leaves holes in the graph, rebuilding restores its recall.
+			tl.Logger.Println("Rebuilding vector index, removed", removed, "of", removed+len(loaded.nodes))
+			s.changed = true
+		default:
+			index = loaded
+			s.changed = removed > 0
+		}
+	}
+	if index == nil {
+		index = New(s.options)
+	}
+	added := 0
+	for _, v := range vectors {
+		if _, ok := index.byID[v.Key]; !ok {
+			index.Add(v.Key, v.Value.Values)
+			added++
+		}
+	}
+	if added > 0 {
+		tl.Logger.Println("Vector index updated with", added, "embeddings")
+		s.changed = true
+	}
+	s.index = index
+	return index
+}
+
+func (s *Store) Add(id string, values []float32) error {
+	s.load().Add(id, values)
+	s.setChanged()
+	return nil
+}
+
+func (s *Store) Delete(ids ...string) error {
+	index := s.load()
+	for _, id := range ids {
+		index.Delete(id)
+	}
+	s.setChanged()
+	return nil
+}
+
+func (s *Store) Search(values []float32, k int, keep func(id string) bool) ([]types.KeyValue[float32], error) {
+	results := []types.KeyValue[float32]{}
+	for _, r := range s.load().Search(values, k, keep) {
+		results = append(results, types.KeyValue[float32]{Key: r.ID, Value: r.Similarity})
+	}
+	return results, nil
+}
+
+func (s *Store) Len() (int, error) {
+	return s.load().Len(), nil
+}
+
+// Save writes the graph if it changed since it was loaded or last saved.
+func (s *Store) Save() error {
+	index := s.load()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.changed || s.path == "" {
+		return nil
+	}
+	if err := index.Save(s.path); err != nil {
+		return err
+	}
+	s.changed = false
+	return nil
+}
+
+func (s *Store) setChanged() {
+	s.lock.Lock()
+	s.changed = true
+	s.lock.Unlock()
+}
@@ -0,0 +1,55 @@
+// Package hybrid merges the rankings of vector and lexical search with reciprocal rank fusion, so that
+// exact identifiers found by keyword search rank well next to semantically similar chunks.
+package hybrid
+
+import (
+	"sort"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// rrfK dampens the advantage of the first ranks, as in the original reciprocal rank fusion paper.
+const rrfK = 60
+
+// DefaultWeight is the weight of the vector ranking used for weights outside (0, 1).
+const DefaultWeight = 0.5
+
+// Fuse merges vector and lexical, both ordered best first, into one ranking of the k best results, all of them
+// for k below 0. A result scores weight/(60+rank) for its vector rank plus (1-weight)/(60+rank) for its lexical rank.
+// The Similarity of the results is the fused score; VectorScore and LexicalScore keep the scores of the components.
+func Fuse(vector, lexical types.SearchResults, weight float64, k int) types.SearchResults {
+	if weight <= 0 || weight >= 1 {
+		weight = DefaultWeight
+	}
+	type fused struct {
+		result types.SearchResult
+		score  float64
+	}
+	byID := map[string]*fused{}
+	var order []*fused
+	add := func(results types.SearchResults, weight float64, setScore func(*types.SearchResult, float32)) {
+		for rank, result := range results.Results {
+			entry, ok := byID[result.Vector.ID]
+			if !ok {
+				entry = &fused{result: result}
+				byID[result.Vector.ID] = entry
+				order = append(order, entry)
+			}
+			setScore(&entry.result, result.Similarity)
+			entry.score += weight / float64(rrfK+rank+1)
+		}
+	}
+	add(vector, weight, func(r *types.SearchResult, score float32) { r.VectorScore = score })
+	add(lexical, 1-weight, func(r *types.SearchResult, score float32) { r.LexicalScore = score })
+
+	sort.SliceStable(order, func(i, j int) bool { return order[i].score > order[j].score })
+	if k > -1 && len(order) > k {
+		order = order[:k]
+	}
+	results := types.SearchResults{}
+	for _, entry := range order {
+		entry.result.Similarity = float32(entry.score)
+		results.Results = append(results.Results, entry.result)
+	}
+	return results
+}
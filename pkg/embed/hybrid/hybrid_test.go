@@ -0,0 +1,64 @@
+package hybrid_test
+
+import (
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed/hybrid"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func ranking(scores map[string]float32, ids ...string) types.SearchResults {
+	results := types.SearchResults{}
+	for _, id := range ids {
+		results.Results = append(results.Results, types.SearchResult{Vector: types.Vector{ID: id}, Similarity: scores[id]})
+	}
+	return results
+}
+
+func TestFuse_givenExactIdentifierMatch_expectRankedFirst(t *testing.T) {
+	vector := ranking(map[string]float32{"prose": 0.9, "tighten": 0.8, "other": 0.7}, "prose", "tighten", "other")
+	lexical := ranking(map[string]float32{"tighten": 12.5}, "tighten")
+
+	fused := hybrid.Fuse(vector, lexical, 0.5, -1)
+	if len(fused.Results) != 3 {
+		t.Fatalf("expected 3 results, got %+v", fused.Results)
+	}
+	first := fused.Results[0]
+	if first.Vector.ID != "tighten" {
+		t.Errorf("expected the lexical match first, got %s", first.Vector.ID)
+	}
+	if first.VectorScore != 0.8 || first.LexicalScore != 12.5 {
+		t.Errorf("expected both component scores, got %f and %f", first.VectorScore, first.LexicalScore)
+	}
+	if fused.Results[1].Vector.ID != "prose" || fused.Results[1].LexicalScore != 0 {
+		t.Errorf("expected the vector-only result second, got %+v", fused.Results[1])
+	}
+}
+
+func TestFuse_givenWeight_expectFavouredRanking(t *testing.T) {
+	vector := ranking(nil, "a", "b")
+	lexical := ranking(nil, "b", "a")
+
+	if fused := hybrid.Fuse(vector, lexical, 0.9, 1); len(fused.Results) != 1 || fused.Results[0].Vector.ID != "a" {
+		t.Errorf("expected the vector ranking to win with weight 0.9, got %+v", fused.Results)
+	}
+	if fused := hybrid.Fuse(vector, lexical, 0.1, 1); len(fused.Results) != 1 || fused.Results[0].Vector.ID != "b" {
+		t.Errorf("expected the lexical ranking to win with weight 0.1, got %+v", fused.Results)
+	}
+}
+
+func TestFuse_givenWeightOutsideRange_expectDefaultWeight(t *testing.T) {
+	vector := ranking(nil, "a", "b")
+	lexical := ranking(nil, "b", "c")
+	want := hybrid.Fuse(vector, lexical, hybrid.DefaultWeight, -1)
+
+	for _, weight := range []float64{0, 1, -1, 2} {
+		fused := hybrid.Fuse(vector, lexical, weight, -1)
+		for i := range want.Results {
+			if fused.Results[i].Vector.ID != want.Results[i].Vector.ID || fused.Results[i].Similarity != want.Results[i].Similarity {
+				t.Errorf("expected weight %v to fuse like the default weight, got %+v", weight, fused.Results)
+				break
+			}
+		}
+	}
+}
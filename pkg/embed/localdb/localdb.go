@@ -27,6 +27,7 @@ type FileDB[T any] struct {
 	scanKeyList []types.KeyValue[T]
 	lock        sync.RWMutex
 	state       FileDBState
+	initOnce    sync.Once     // starts loading the data once, also when first used by several goroutines
 	ready       chan struct{} // signal when the data is ready
 
 }
@@ -41,25 +42,22 @@ func NewFileDB[T any](filePath string) (types.DBCollectionInterface[T], error) {
 	return db, nil
 }
 func (db *FileDB[T]) StartInit() {
-	if db.state != FileDBStateNotStarted {
-		return
-	}
-	db.state = FileDBStateNotReady
-	go func() {
-		// close the channel to signal we're done
-		defer close(db.ready)
-		tl.Logger.Println("NewFileDB - Loading data from ", db.filePath)
-		if err := db.load(); err != nil {
-			tl.Logger.Printf("error loading data: %v", err)
-		}
-		db.state = FileDBStateReady
-	}()
+	db.initOnce.Do(func() {
+		db.state = FileDBStateNotReady
+		go func() {
+			// close the channel to signal we're done
+			defer close(db.ready)
+			tl.Logger.Println("NewFileDB - Loading data from ", db.filePath)
+			if err := db.load(); err != nil {
+				tl.Logger.Printf("error loading data: %v", err)
+			}
+			db.state = FileDBStateReady
+		}()
+	})
 }
 
 func (db *FileDB[T]) waitReady() {
-	if db.state == FileDBStateNotStarted {
-		db.StartInit()
-	}
+	db.StartInit()
 	<-db.ready
 }
 func (db *FileDB[T]) load() error {
@@ -0,0 +1,116 @@
+package embed
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// MarkdownChunker cuts markdown along its headings. A section runs from its heading to the next one and is named
+// after its heading and the headings it is under, as in "Usage > Search". Sections smaller than Size tokens are
+// packed together, sections larger than 4*Size are split at their paragraphs.
+type MarkdownChunker struct {
+	Size int
+}
+
+func (c MarkdownChunker) Version() string {
+	return fmt.Sprintf("md1-%d", c.Size)
+}
+
+func (c MarkdownChunker) Chunk(t *tzap.Tzap, filename string, content string) ([]*types.Vector, error) {
+	sections := []span{}
+	headings := []string{}
+	start := 0
+	symbol := ""
+	fenced := false
+	for offset := 0; offset < len(content); offset = lineEnd(content, offset) {
+		line := strings.TrimRight(content[offset:lineEnd(content, offset)], "\r\n")
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fenced = !fenced
+		}
+		level, title := markdownHeading(line)
+		if fenced || level == 0 {
+			continue
+		}
+		if offset > start {
+			sections = append(sections, span{start: start, end: offset, symbol: symbol})
+		}
+		for len(headings) >= level {
+			headings = headings[:len(headings)-1]
+		}
+		for len(headings) < level-1 {
+			headings = append(headings, "")
+		}
+		headings = append(headings, title)
+		start, symbol = offset, joinHeadings(headings)
+	}
+	if start < len(content) {
+		sections = append(sections, span{start: start, end: len(content), symbol: symbol})
+	}
+
+	spans := []span{}
+	for _, section := range sections {
+		section, err := countSpan(t, content, section)
+		if err != nil {
+			return nil, err
+		}
+		if section.tokens <= 4*c.Size {
+			spans = append(spans, section)
+			continue
+		}
+		split, err := splitSpan(t, content, section, paragraphCuts(content, section, false), 4*c.Size)
+		if err != nil {
+			return nil, err
+		}
+		spans = append(spans, split...)
+	}
+	return spanVectors(filename, content, c.Version(), packSpans(spans, c.Size)), nil
+}
+
+// markdownHeading returns the level and the title of an ATX heading line, or level 0 for other lines.
+func markdownHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level < len(line) && line[level] != ' ' && line[level] != '\t' {
+		return 0, ""
+	}
+	return level, strings.TrimSpace(strings.TrimRight(strings.TrimSpace(line[level:]), "#"))
+}
+
+func joinHeadings(headings []string) string {
+	titles := []string{}
+	for _, heading := range headings {
+		if heading != "" {
+			titles = append(titles, heading)
+		}
+	}
+	return strings.Join(titles, " > ")
+}
+
+// paragraphCuts returns the starts of the lines of s following a blank line. With unindented only the lines
+// starting without indentation are returned.
+func paragraphCuts(content string, s span, unindented bool) []int {
+	cuts := []int{}
+	blank := false
+	for offset := s.start; offset < s.end; offset = lineEnd(content, offset) {
+		line := strings.TrimRight(content[offset:lineEnd(content, offset)], "\r\n")
+		if strings.TrimSpace(line) == "" {
+			blank = true
+			continue
+		}
+		if blank && offset > s.start && (!unindented || !startsIndented(line)) {
+			cuts = append(cuts, offset)
+		}
+		blank = false
+	}
+	return cuts
+}
+
+func startsIndented(line string) bool {
+	return strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")
+}
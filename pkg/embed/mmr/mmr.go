@@ -0,0 +1,108 @@
+// Package mmr diversifies search results by maximal marginal relevance (Carbonell and Goldstein, 1998), so that
+// overlapping chunks of one file do not crowd out the other files relevant to a query. It works on the stored vectors
+// of the results and makes no model calls.
+package mmr
+
+import (
+	"math"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Rerank picks k of results, ordered best first, all of them for k below 0. Each pick is the result maximizing
+// (1-diversity)*relevance - diversity*redundancy, where relevance is its Similarity scaled to [0, 1] over results,
+// and redundancy its highest cosine similarity with the results already picked. Results without Values are never
+// redundant. With a maxPerFile above 0, results of a file that already has maxPerFile picks are dropped.
+// A diversity of 0 keeps the order of results.
+func Rerank(results types.SearchResults, diversity float64, maxPerFile int, k int) types.SearchResults {
+	candidates := results.Results
+	if k < 0 || k > len(candidates) {
+		k = len(candidates)
+	}
+	diversity = math.Max(0, math.Min(1, diversity))
+
+	relevance := scale(candidates)
+	vectors := make([][]float32, len(candidates))
+	for i, c := range candidates {
+		vectors[i] = normalize(c.Vector.Values)
+	}
+	redundancy := make([]float64, len(candidates))
+	picked := make([]bool, len(candidates))
+	perFile := map[string]int{}
+
+	reranked := types.SearchResults{}
+	for len(reranked.Results) < k {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range candidates {
+			if picked[i] || maxPerFile > 0 && perFile[candidates[i].Vector.Metadata.Filename] >= maxPerFile {
+				continue
+			}
+			if score := (1-diversity)*relevance[i] - diversity*redundancy[i]; score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		picked[best] = true
+		perFile[candidates[best].Vector.Metadata.Filename]++
+		reranked.Results = append(reranked.Results, candidates[best])
+		if diversity == 0 {
+			continue
+		}
+		for i := range candidates {
+			if !picked[i] {
+				redundancy[i] = math.Max(redundancy[i], similarity(vectors[i], vectors[best]))
+			}
+		}
+	}
+	return reranked
+}
+
+// scale maps the Similarity of results linearly to [0, 1], so that cosine, BM25 and fused scores weigh the same.
+func scale(results []types.SearchResult) []float64 {
+	scaled := make([]float64, len(results))
+	if len(results) == 0 {
+		return scaled
+	}
+	lowest, highest := math.Inf(1), math.Inf(-1)
+	for _, r := range results {
+		lowest = math.Min(lowest, float64(r.Similarity))
+		highest = math.Max(highest, float64(r.Similarity))
+	}
+	for i, r := range results {
+		if highest > lowest {
+			scaled[i] = (float64(r.Similarity) - lowest) / (highest - lowest)
+		} else {
+			scaled[i] = 1
+		}
+	}
+	return scaled
+}
+
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return nil
+	}
+	magnitude := float32(math.Sqrt(sum))
+	normalized := make([]float32, len(vector))
+	for i, v := range vector {
+		normalized[i] = v / magnitude
+	}
+	return normalized
+}
+
+func similarity(a, b []float32) float64 {
+	if a == nil || b == nil || len(a) != len(b) {
+		return 0
+	}
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
@@ -0,0 +1,50 @@
+package mmr_test
+
+import (
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed/mmr"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func result(id, filename string, similarity float32, values ...float32) types.SearchResult {
+	return types.SearchResult{
+		Vector:     types.Vector{ID: id, Values: values, Metadata: types.Metadata{Filename: filename}},
+		Similarity: similarity,
+	}
+}
+
+func ids(results types.SearchResults) []string {
+	var ids []string
+	for _, r := range results.Results {
+		ids = append(ids, r.Vector.ID)
+	}
+	return ids
+}
+
+// Three overlapping windows of a.go rank above a different chunk of b.go.
+var overlapping = types.SearchResults{Results: []types.SearchResult{
+	result("a-0", "a.go", 0.90, 1, 0, 0),
+	result("a-1", "a.go", 0.89, 0.99, 0.1, 0),
+	result("a-2", "a.go", 0.88, 0.98, 0.15, 0),
+	result("b-0", "b.go", 0.80, 0, 1, 0),
+}}
+
+func TestRerank_givenNoDiversity_expectRelevanceOrder(t *testing.T) {
+	if got := ids(mmr.Rerank(overlapping, 0, 0, 2)); len(got) != 2 || got[0] != "a-0" || got[1] != "a-1" {
+		t.Errorf("expected a-0 and a-1, got %v", got)
+	}
+}
+
+func TestRerank_givenDiversity_expectOtherFilePicked(t *testing.T) {
+	if got := ids(mmr.Rerank(overlapping, 0.5, 0, 2)); len(got) != 2 || got[0] != "a-0" || got[1] != "b-0" {
+		t.Errorf("expected a-0 and b-0, got %v", got)
+	}
+}
+
+func TestRerank_givenMaxPerFile_expectCap(t *testing.T) {
+	got := ids(mmr.Rerank(overlapping, 0, 2, -1))
+	if len(got) != 3 || got[0] != "a-0" || got[1] != "a-1" || got[2] != "b-0" {
+		t.Errorf("expected two chunks of a.go and b-0, got %v", got)
+	}
+}
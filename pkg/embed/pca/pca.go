@@ -1,19 +1,19 @@
 package pca
 
-func EmbeddingsTo3D(embeddings [][1536]float32) [][]float32 {
+func EmbeddingsTo3D(embeddings [][]float32) [][]float32 {
 	// Calculate the covariance matrix of the input embeddings
 	n := len(embeddings)
 	m := len(embeddings[0])
-	means := [1536]float32(make([]float32, m))
+	means := make([]float32, m)
 	for i := 0; i < m; i++ {
 		for j := 0; j < n; j++ {
 			means[i] += embeddings[j][i]
 		}
 		means[i] /= float32(n)
 	}
-	cov := make([][1536]float32, m)
+	cov := make([][]float32, m)
 	for i := 0; i < m; i++ {
-		cov[i] = [1536]float32(make([]float32, m))
+		cov[i] = make([]float32, m)
 		for j := 0; j < m; j++ {
 			for k := 0; k < n; k++ {
 				cov[i][j] += (embeddings[k][i] - means[i]) * (embeddings[k][j] - means[j])
@@ -23,10 +23,10 @@ func EmbeddingsTo3D(embeddings [][1536]float32) [][]float32 {
 	}
 
 	// Calculate the eigenvectors and eigenvalues of the covariance matrix
-	eigenVals := [1536]float32(make([]float32, m))
-	tmpVecs := make([][1536]float32, m)
+	eigenVals := make([]float32, m)
+	tmpVecs := make([][]float32, m)
 	for i := 0; i < m; i++ {
-		tmpVecs[i] = [1536]float32(make([]float32, m))
+		tmpVecs[i] = make([]float32, m)
 	}
 	for i := range eigenVals {
 		eigenVals[i] = cov[i][i]
@@ -60,9 +60,9 @@ func EmbeddingsTo3D(embeddings [][1536]float32) [][]float32 {
 			}
 		}
 	}
-	eigenVecs := make([][1536]float32, m)
+	eigenVecs := make([][]float32, m)
 	for i := 0; i < m; i++ {
-		eigenVecs[i] = [1536]float32(make([]float32, m))
+		eigenVecs[i] = make([]float32, m)
 		for j := 0; j < m; j++ {
 			eigenVecs[i][j] = tmpVecs[j][i]
 		}
@@ -18,7 +18,7 @@ func TestEmbeddingsTo3D(t *testing.T) {
 	if len(embeddings.Vectors) == 0 {
 		t.Fatalf("embeddings file is empty")
 	}
-	var vectors [][1536]float32
+	var vectors [][]float32
 	for _, v := range embeddings.Vectors {
 		vectors = append(vectors, v.Values)
 	}
@@ -5,29 +5,40 @@ import (
 	"github.com/tzapio/tzap/pkg/tzap"
 )
 
+// NewQuery embeds input without its filter terms, see ParseQuery, and filters the query with them.
+// With query expansion configured, see ExpandQuery, the query holds one embedding for input followed by one for
+// each expansion, all fetched in one call.
 func NewQuery(t *tzap.Tzap, input string) (types.QueryRequest, error) {
-	embeddings, err := getEmbeddings(t, input)
+	text, filter := ParseQuery(input)
+	if text == "" {
+		text = input
+	}
+	expansions, err := ExpandQuery(t, text)
+	if err != nil {
+		return types.QueryRequest{}, err
+	}
+	embeddings, err := getEmbeddings(t, append([]string{text}, expansions...)...)
 	if err != nil {
 		return types.QueryRequest{}, err
 	}
-	queryFilters := CreateQueryFilters(embeddings)
+	queryFilters := CreateQueryFilters(embeddings, filter)
 	query := BuildQuery(queryFilters)
 	return query, nil
 }
 
-func getEmbeddings(t *tzap.Tzap, input string) ([][1536]float32, error) {
-	embeddings, err := t.TG.FetchEmbedding(t.C, input)
+func getEmbeddings(t *tzap.Tzap, input ...string) ([][]float32, error) {
+	embeddings, err := t.TG.FetchEmbedding(t.UsageContext(), input...)
 	if err != nil {
 		return nil, err
 	}
 	return embeddings, nil
 }
 
-func CreateQueryFilters(embeddings [][1536]float32) []types.QueryFilter {
+func CreateQueryFilters(embeddings [][]float32, filter types.MetadataFilter) []types.QueryFilter {
 	var queryFilters []types.QueryFilter
 	for _, embedding := range embeddings {
 		queryFilters = append(queryFilters, types.QueryFilter{
-			Filter: nil, // No filter is applied; adjust this according to your needs
+			Filter: filter,
 			Values: embedding,
 		})
 	}
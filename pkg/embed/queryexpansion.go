@@ -0,0 +1,71 @@
+package embed
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// maxSubQueries bounds the SubQueries of the configuration.
+const maxSubQueries = 4
+
+type queryExpansion struct {
+	Snippet string   `json:"snippet,omitempty" description:"hypothetical code answering the query"`
+	Queries []string `json:"queries,omitempty" description:"rephrased search queries"`
+}
+
+// ExpandQuery asks the ExpandModel of the configuration for texts to search with next to query: a hypothetical
+// snippet of the code the query is looking for when HyDE is set, and up to SubQueries rephrasings of the query,
+// both in one call. It returns no texts when neither is configured.
+func ExpandQuery(t *tzap.Tzap, query string) ([]string, error) {
+	conf := config.FromContext(t.C)
+	subQueries := conf.SubQueries
+	if subQueries > maxSubQueries {
+		subQueries = maxSubQueries
+	}
+	if !conf.HyDE && subQueries <= 0 {
+		return nil, nil
+	}
+
+	var instruction strings.Builder
+	instruction.WriteString("The user searches a code base with the query below. Help find the code by writing texts phrased like the code it is looking for.\n")
+	if conf.HyDE {
+		instruction.WriteString("In snippet, write a short hypothetical code snippet that would answer the query, in the language of the code base if the query tells it.\n")
+	}
+	if subQueries > 0 {
+		fmt.Fprintf(&instruction, "In queries, write %d different search queries for the code, using the names code would use.\n", subQueries)
+	}
+
+	expanding := t.CopyConnection().
+		AddContextChange(func(ctx context.Context) context.Context {
+			conf := config.FromContext(ctx)
+			if conf.ExpandModel != "" {
+				conf.OpenAIModel = conf.ExpandModel
+			}
+			return config.NewContext(ctx, conf)
+		}).
+		AddUserMessage("Query: " + query)
+	expansion, _, err := tzap.RequestJSON(expanding, tzap.JSONOptions[queryExpansion]{Instruction: instruction.String()})
+	if err != nil {
+		return nil, fmt.Errorf("query expansion: %w", err)
+	}
+
+	var texts []string
+	if conf.HyDE && strings.TrimSpace(expansion.Snippet) != "" {
+		texts = append(texts, expansion.Snippet)
+	}
+	queries := 0
+	for _, q := range expansion.Queries {
+		if queries == subQueries {
+			break
+		}
+		if strings.TrimSpace(q) != "" {
+			texts = append(texts, q)
+			queries++
+		}
+	}
+	return texts, nil
+}
@@ -0,0 +1,78 @@
+package embed_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// expansionTG answers chats with answer and embeds each input as its length.
+type expansionTG struct {
+	types.TGenerator
+	answer     string
+	model      string
+	embeddings [][]string
+}
+
+func (tg *expansionTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	tg.model = config.FromContext(ctx).OpenAIModel
+	return tg.answer, nil
+}
+
+func (tg *expansionTG) CountTokens(ctx context.Context, content string) (int, error) {
+	return len(content) / 4, nil
+}
+
+func (tg *expansionTG) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	tg.embeddings = append(tg.embeddings, content)
+	var embeddings [][]float32
+	for _, c := range content {
+		embeddings = append(embeddings, []float32{float32(len(c))})
+	}
+	return embeddings, nil
+}
+
+func TestNewQuery_givenExpansion_expectAllTextsEmbeddedInOneCall(t *testing.T) {
+	tg := &expansionTG{answer: `{"snippet": "func VerifyEmail(u *User) error", "queries": ["user email verification", "send verification mail", "dropped"]}`}
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{OpenAIModel: "gpt-4", ExpandModel: "gpt-3.5-turbo", HyDE: true, SubQueries: 2}
+	})
+
+	query, err := embed.NewQuery(tz, "lang:go add email verification to the user field")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"add email verification to the user field", "func VerifyEmail(u *User) error", "user email verification", "send verification mail"}}
+	if !reflect.DeepEqual(tg.embeddings, want) {
+		t.Errorf("expected one embedding call with the query and its expansions, got %v", tg.embeddings)
+	}
+	if tg.model != "gpt-3.5-turbo" {
+		t.Errorf("expected the expand model, got %s", tg.model)
+	}
+	if len(query.Queries) != 4 {
+		t.Fatalf("expected 4 queries, got %d", len(query.Queries))
+	}
+	for _, q := range query.Queries {
+		if !reflect.DeepEqual(q.Filter.Include.Extensions, []string{"go"}) {
+			t.Errorf("expected every query filtered, got %+v", q.Filter)
+		}
+	}
+}
+
+func TestNewQuery_givenNoExpansion_expectNoChat(t *testing.T) {
+	tg := &expansionTG{}
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{}
+	})
+	if _, err := embed.NewQuery(tz, "cosine distance"); err != nil {
+		t.Fatal(err)
+	}
+	if tg.model != "" || !reflect.DeepEqual(tg.embeddings, [][]string{{"cosine distance"}}) {
+		t.Errorf("expected only the query embedded, got %v and a chat with %q", tg.embeddings, tg.model)
+	}
+}
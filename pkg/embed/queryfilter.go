@@ -0,0 +1,63 @@
+package embed
+
+import (
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// languageExtensions maps the names accepted by lang: to file extensions. Other names are taken as extensions.
+var languageExtensions = map[string][]string{
+	"go":         {"go"},
+	"python":     {"py"},
+	"javascript": {"js", "jsx", "mjs", "cjs"},
+	"js":         {"js", "jsx", "mjs", "cjs"},
+	"typescript": {"ts", "tsx"},
+	"ts":         {"ts", "tsx"},
+	"rust":       {"rs"},
+	"ruby":       {"rb"},
+	"c":          {"c", "h"},
+	"cpp":        {"cc", "cpp", "cxx", "hh", "hpp", "h"},
+	"csharp":     {"cs"},
+	"kotlin":     {"kt", "kts"},
+	"shell":      {"sh", "bash"},
+	"markdown":   {"md"},
+	"yaml":       {"yaml", "yml"},
+	"proto":      {"proto"},
+}
+
+// ParseQuery splits the filter terms off a search query and returns the rest of the query and the filter.
+// Filter terms are path:, dir:, lang: and ext: followed by a value, and exclude when prefixed with -, as in
+// "path:pkg/embed lang:go -path:*_test.go cosine distance". Other words are kept in the query.
+func ParseQuery(input string) (string, types.MetadataFilter) {
+	var filter types.MetadataFilter
+	var words []string
+	for _, word := range strings.Fields(input) {
+		matcher := &filter.Include
+		term := word
+		if strings.HasPrefix(term, "-") {
+			matcher = &filter.Exclude
+			term = term[1:]
+		}
+		key, value, found := strings.Cut(term, ":")
+		if !found || value == "" {
+			words = append(words, word)
+			continue
+		}
+		switch strings.ToLower(key) {
+		case "path", "dir":
+			matcher.Paths = append(matcher.Paths, value)
+		case "ext":
+			matcher.Extensions = append(matcher.Extensions, strings.TrimPrefix(value, "."))
+		case "lang":
+			if extensions, ok := languageExtensions[strings.ToLower(value)]; ok {
+				matcher.Extensions = append(matcher.Extensions, extensions...)
+			} else {
+				matcher.Extensions = append(matcher.Extensions, value)
+			}
+		default:
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " "), filter
+}
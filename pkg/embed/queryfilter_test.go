@@ -0,0 +1,44 @@
+package embed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+func TestParseQuery(t *testing.T) {
+	text, filter := embed.ParseQuery("path:pkg/embed lang:go -path:*_test.go cosine distance note:kept")
+	if text != "cosine distance note:kept" {
+		t.Errorf("expected the filter terms removed from the query, got %q", text)
+	}
+	want := types.MetadataFilter{
+		Include: types.FileMatcher{Paths: []string{"pkg/embed"}, Extensions: []string{"go"}},
+		Exclude: types.FileMatcher{Paths: []string{"*_test.go"}},
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("ParseQuery filter = %+v, want %+v", filter, want)
+	}
+}
+
+func TestMetadataFilterMatch(t *testing.T) {
+	_, filter := embed.ParseQuery("path:pkg/embed lang:go -path:*_test.go -dir:pkg/embed/pca")
+	tests := map[string]bool{
+		"pkg/embed/query.go":          true,
+		"./pkg/embed/bm25/bm25.go":    true,
+		"pkg/embed/bm25/bm25_test.go": false,
+		"pkg/embed/pca/pca.go":        false,
+		"pkg/embedder/x.go":           false,
+		"pkg/embed/README.md":         false,
+		"cli/main.go":                 false,
+	}
+	for filename, want := range tests {
+		if got := filter.Match(filename); got != want {
+			t.Errorf("Match(%q) = %v, want %v", filename, got, want)
+		}
+	}
+	if _, filter := embed.ParseQuery("-path:pkg/*/hnsw"); filter.Match("pkg/embed/hnsw/hnsw.go") {
+		t.Errorf("expected a glob to match the directories of the filename")
+	}
+}
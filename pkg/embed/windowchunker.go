@@ -0,0 +1,97 @@
+package embed
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// windowBlockTokens is about how many tokens of a file WindowChunker tokenizes at once, so that cutting a window
+// does not tokenize the whole file.
+const windowBlockTokens = 4000
+
+// WindowChunker cuts files into windows of Size tokens, each starting Size-Overlap tokens after the previous one.
+// Start and End of a chunk are token offsets, End being where the next window starts.
+type WindowChunker struct {
+	Size    int
+	Overlap int
+	// legacy keeps the ids of the chunks of indexes built before chunkers were configurable.
+	legacy bool
+	// version overrides the version of a chunker falling back to windows.
+	version string
+}
+
+func (c WindowChunker) Version() string {
+	switch {
+	case c.legacy:
+		return ""
+	case c.version != "":
+		return c.version
+	}
+	return fmt.Sprintf("window1-%d-%d", c.Size, c.Overlap)
+}
+
+func (c WindowChunker) Chunk(t *tzap.Tzap, filename string, content string) ([]*types.Vector, error) {
+	fileTokens, err := t.TG.CountTokens(t.C, content)
+	if err != nil {
+		return nil, err
+	}
+	stride := c.Size - c.Overlap
+	block := windowBlockTokens / stride * stride
+	if block == 0 {
+		block = stride
+	}
+
+	vectors := []*types.Vector{}
+	lineStart := 1
+	for blockStart := 0; blockStart < fileTokens; blockStart += block {
+		tl.Logger.Println("Processing file", filename, "chunk", blockStart, "to", blockStart+block, "tokens", fileTokens)
+		blockContent, blockTokens, err := t.TG.OffsetTokens(t.C, content, blockStart, min(blockStart+block+c.Overlap, fileTokens))
+		if err != nil {
+			return nil, err
+		}
+		for start := 0; start < block && start < blockTokens; start += stride {
+			vector, err := c.window(t, filename, blockContent, blockStart, start, blockTokens, fileTokens, lineStart)
+			if err != nil {
+				return nil, err
+			}
+			vectors = append(vectors, vector)
+			lineStart += strings.Count(vector.Metadata.RealSplitPart, "\n")
+		}
+	}
+	return vectors, nil
+}
+
+// window cuts the window starting start tokens into blockContent, the blockTokens tokens of a file of fileTokens
+// tokens starting at blockStart. lineStart is the line the window starts at.
+func (c WindowChunker) window(t *tzap.Tzap, filename string, blockContent string, blockStart int, start int, blockTokens int, fileTokens int, lineStart int) (*types.Vector, error) {
+	stride := c.Size - c.Overlap
+	splitPart, _, err := t.TG.OffsetTokens(t.C, blockContent, start, min(start+c.Size, blockTokens))
+	if err != nil {
+		return nil, err
+	}
+	realSplitPart, _, err := t.TG.OffsetTokens(t.C, blockContent, start, min(start+stride, blockTokens))
+	if err != nil {
+		return nil, err
+	}
+	metadataStart := blockStart + start
+	id := chunkID(filename, c.Version(), metadataStart, metadataStart+stride)
+	return &types.Vector{
+		ID: id,
+		Metadata: types.Metadata{
+			ID:            id,
+			Filename:      filename,
+			Start:         metadataStart,
+			End:           metadataStart + stride,
+			LineStart:     lineStart,
+			LineEnd:       lineStart + strings.Count(strings.TrimSuffix(splitPart, "\n"), "\n"),
+			TruncatedEnd:  min(metadataStart+c.Size, fileTokens),
+			Chunker:       c.Version(),
+			SplitPart:     AddEmbedHeader(filename, splitPart),
+			RealSplitPart: realSplitPart,
+		},
+	}, nil
+}
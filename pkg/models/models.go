@@ -0,0 +1,212 @@
+// Package models is the registry of the chat and embedding models tzap knows: their context windows,
+// prices, tokenizers and capabilities. Projects add or override models with Load.
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Kinds of models.
+const (
+	KindChat      = "chat"
+	KindEmbedding = "embedding"
+)
+
+// Encodings of the tokenizers.
+const (
+	EncodingCL100kBase = "cl100k_base"
+)
+
+// Capabilities lists the optional features of a model.
+type Capabilities struct {
+	Tools  bool `json:"tools,omitempty"`
+	JSON   bool `json:"json,omitempty"`
+	Vision bool `json:"vision,omitempty"`
+	// Dimensions marks embedding models that return shortened vectors on request.
+	Dimensions bool `json:"dimensions,omitempty"`
+}
+
+// Model describes a model. Prices are in USD per 1000 tokens.
+type Model struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Kind     string `json:"kind"`
+	// Aliases are short names accepted wherever the id is, such as the --model flag of the CLI.
+	Aliases         []string     `json:"aliases,omitempty"`
+	ContextWindow   int          `json:"contextWindow"`
+	MaxOutputTokens int          `json:"maxOutputTokens,omitempty"`
+	Encoding        string       `json:"encoding"`
+	InputPrice      float64      `json:"inputPrice"`
+	OutputPrice     float64      `json:"outputPrice,omitempty"`
+	Capabilities    Capabilities `json:"capabilities"`
+	// Dimensions is the length of the vectors of an embedding model.
+	Dimensions int `json:"dimensions,omitempty"`
+	// MaxRequestTokens and MaxRequestInputs bound the tokens and inputs of one request to an embedding model.
+	// 0 means no bound.
+	MaxRequestTokens int `json:"maxRequestTokens,omitempty"`
+	MaxRequestInputs int `json:"maxRequestInputs,omitempty"`
+}
+
+// Cost returns the USD cost of a call with the given token counts.
+func (m Model) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*m.InputPrice + float64(completionTokens)*m.OutputPrice) / 1000
+}
+
+var (
+	lock    sync.RWMutex
+	byID    = map[string]Model{}
+	aliases = map[string]string{}
+)
+
+func init() {
+	openAIChat := func(id string, contextWindow int, input, output float64, aliases ...string) Model {
+		return Model{
+			ID: id, Provider: "openai", Kind: KindChat, Aliases: aliases,
+			ContextWindow: contextWindow, MaxOutputTokens: 4096, Encoding: EncodingCL100kBase,
+			InputPrice: input, OutputPrice: output,
+			Capabilities: Capabilities{Tools: true, JSON: true},
+		}
+	}
+	for _, model := range []Model{
+		openAIChat("gpt-3.5-turbo", 4096, 0.0015, 0.002, "gpt35"),
+		openAIChat("gpt-3.5-turbo-0301", 4096, 0.0015, 0.002),
+		openAIChat("gpt-3.5-turbo-0613", 4096, 0.0015, 0.002, "gpt356"),
+		openAIChat("gpt-3.5-turbo-16k", 16384, 0.003, 0.004, "gpt3516", "gpt3516k", "gpt16"),
+		openAIChat("gpt-4", 8192, 0.03, 0.06, "gpt4"),
+		openAIChat("gpt-4-0314", 8192, 0.03, 0.06),
+		openAIChat("gpt-4-32k", 32768, 0.06, 0.12, "gpt432k"),
+		openAIChat("gpt-4-32k-0314", 32768, 0.06, 0.12),
+		{
+			ID: "text-embedding-ada-002", Provider: "openai", Kind: KindEmbedding, Aliases: []string{"ada2"},
+			ContextWindow: 8191, Encoding: EncodingCL100kBase, InputPrice: 0.0001, Dimensions: 1536,
+			MaxRequestTokens: 300000, MaxRequestInputs: 2048,
+		},
+		{
+			ID: "text-embedding-3-small", Provider: "openai", Kind: KindEmbedding, Aliases: []string{"emb3small"},
+			ContextWindow: 8191, Encoding: EncodingCL100kBase, InputPrice: 0.00002, Dimensions: 1536,
+			MaxRequestTokens: 300000, MaxRequestInputs: 2048,
+			Capabilities: Capabilities{Dimensions: true},
+		},
+		{
+			ID: "text-embedding-3-large", Provider: "openai", Kind: KindEmbedding, Aliases: []string{"emb3large"},
+			ContextWindow: 8191, Encoding: EncodingCL100kBase, InputPrice: 0.00013, Dimensions: 3072,
+			MaxRequestTokens: 300000, MaxRequestInputs: 2048,
+			Capabilities: Capabilities{Dimensions: true},
+		},
+	} {
+		if err := Register(model); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Register adds model to the registry, replacing a model with the same id.
+func Register(model Model) error {
+	if model.ID == "" {
+		return fmt.Errorf("model without id")
+	}
+	if model.Kind != KindChat && model.Kind != KindEmbedding {
+		return fmt.Errorf("model %s: kind must be %q or %q, got %q", model.ID, KindChat, KindEmbedding, model.Kind)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for _, alias := range model.Aliases {
+		if id, ok := aliases[alias]; ok && id != model.ID {
+			return fmt.Errorf("model %s: alias %q is already used by %s", model.ID, alias, id)
+		}
+	}
+	if previous, ok := byID[model.ID]; ok {
+		for _, alias := range previous.Aliases {
+			delete(aliases, alias)
+		}
+	}
+	byID[model.ID] = model
+	for _, alias := range model.Aliases {
+		aliases[alias] = model.ID
+	}
+	return nil
+}
+
+// Load registers the models of a JSON array, as found under "models" in the project config file.
+func Load(data []byte) error {
+	var loaded []Model
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return fmt.Errorf("error decoding models: %w", err)
+	}
+	for _, model := range loaded {
+		if err := Register(model); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns the model with the id or alias name.
+func Get(name string) (Model, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+	if id, ok := aliases[name]; ok {
+		name = id
+	}
+	model, ok := byID[name]
+	return model, ok
+}
+
+// Resolve returns the model of kind with the id or alias name, or an error listing the models of that kind.
+func Resolve(name, kind string) (Model, error) {
+	model, ok := Get(name)
+	if !ok {
+		return Model{}, fmt.Errorf("unknown %s model %q. Available: %s", kind, name, strings.Join(Names(kind), ", "))
+	}
+	if model.Kind != kind {
+		return Model{}, fmt.Errorf("model %q is a %s model, expected a %s model", name, model.Kind, kind)
+	}
+	return model, nil
+}
+
+// ID returns the id of the model with the id or alias name, or name for unknown models.
+func ID(name string) string {
+	if model, ok := Get(name); ok {
+		return model.ID
+	}
+	return name
+}
+
+// List returns the registered models of kind sorted by id. An empty kind lists every model.
+func List(kind string) []Model {
+	lock.RLock()
+	defer lock.RUnlock()
+	var list []Model
+	for _, model := range byID {
+		if kind == "" || model.Kind == kind {
+			list = append(list, model)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// Names returns the ids and aliases of the models of kind.
+func Names(kind string) []string {
+	var names []string
+	for _, model := range List(kind) {
+		name := model.ID
+		if len(model.Aliases) > 0 {
+			name += " (" + strings.Join(model.Aliases, ", ") + ")"
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// ContextWindow returns the context window of the model name, or fallback for unknown models.
+func ContextWindow(name string, fallback int) int {
+	if model, ok := Get(name); ok && model.ContextWindow > 0 {
+		return model.ContextWindow
+	}
+	return fallback
+}
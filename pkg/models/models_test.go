@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveAlias(t *testing.T) {
+	model, err := Resolve("gpt4", KindChat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.ID != "gpt-4" || model.ContextWindow != 8192 {
+		t.Errorf("unexpected model %+v", model)
+	}
+}
+
+func TestResolveWrongKind(t *testing.T) {
+	if _, err := Resolve("text-embedding-ada-002", KindChat); err == nil {
+		t.Error("expected an embedding model to be rejected as chat model")
+	}
+	_, err := Resolve("gpt-5-turbo", KindChat)
+	if err == nil || !strings.Contains(err.Error(), "gpt-4 (gpt4)") {
+		t.Errorf("expected the available models in the error, got %v", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	err := Load([]byte(`[
+		{"id": "local-llama", "provider": "ollama", "kind": "chat", "aliases": ["llama"], "contextWindow": 2048, "encoding": "cl100k_base"},
+		{"id": "gpt-4", "provider": "openai", "kind": "chat", "aliases": ["gpt4"], "contextWindow": 8192, "encoding": "cl100k_base", "inputPrice": 0.01, "outputPrice": 0.02}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ContextWindow("llama", 4000) != 2048 {
+		t.Error("expected the loaded model to be found by alias")
+	}
+	if ContextWindow("unknown", 4000) != 4000 {
+		t.Error("expected the fallback for unknown models")
+	}
+	gpt4, _ := Get("gpt-4")
+	if cost := gpt4.Cost(1000, 1000); cost < 0.0299 || cost > 0.0301 {
+		t.Errorf("expected the overridden prices, got cost %f", cost)
+	}
+
+	if err := Load([]byte(`[{"id": "other", "kind": "chat", "aliases": ["llama"]}]`)); err == nil {
+		t.Error("expected a duplicate alias to be rejected")
+	}
+}
@@ -24,7 +24,14 @@ type Project interface {
 	GetProjectName() ProjectName
 	GetFiles() ([]types.FileReader, error)
 	GetEmbeddingCollection() types.DBCollectionInterface[types.Vector]
-	GetTimestampCache() types.DBCollectionInterface[int64]
+	// GetIndexHeader returns the collection holding the types.IndexHeader of the embedding collection.
+	GetIndexHeader() types.DBCollectionInterface[types.IndexHeader]
+	// GetVectorIndex returns the nearest neighbour index of the embedding collection, nil to always compare the query with every embedding.
+	GetVectorIndex() types.VectorIndex
+	// GetLexicalIndex returns the BM25 documents of the project, and GetLexicalTimestampCache the types.FileStamp of the files they were built from.
+	GetLexicalIndex() types.DBCollectionInterface[types.LexicalDocument]
+	GetLexicalTimestampCache() types.DBCollectionInterface[types.FileStamp]
+	GetTimestampCache() types.DBCollectionInterface[types.FileStamp]
 	GetEmbeddingsCache() types.DBCollectionInterface[string]
 	CanIndex() bool
 }
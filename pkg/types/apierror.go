@@ -0,0 +1,33 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// APIError is a failed request to a model API. Connectors return it so middleware can tell
+// failures worth retrying from permanent ones.
+type APIError struct {
+	StatusCode int
+	// RetryAfter is the wait the API asked for before retrying, 0 if it did not say.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("api error (status %d): %v", e.StatusCode, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the request may succeed when retried: rate limits, timeouts and server errors.
+func (e *APIError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusRequestTimeout, http.StatusConflict, http.StatusTooManyRequests:
+		return true
+	}
+	return e.StatusCode >= http.StatusInternalServerError
+}
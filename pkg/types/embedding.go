@@ -1,11 +1,39 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+)
+
 type Vector struct {
-	ID        string        `json:"id"`
-	TimeStamp int           `json:"timestamp"`
-	Metadata  Metadata      `json:"metadata"`
-	Values    [1536]float32 `json:"values"`
+	ID        string    `json:"id"`
+	TimeStamp int       `json:"timestamp"`
+	Metadata  Metadata  `json:"metadata"`
+	Values    []float32 `json:"values"`
+}
+
+// IndexHeader records the embedding model and the length of the vectors of an index.
+// Only vectors of the same model and length can be compared with the vectors of the index.
+type IndexHeader struct {
+	Model      string `json:"model"`
+	Dimensions int    `json:"dimensions"`
+}
+
+// ErrReindexRequired is returned when an index is searched or extended with embeddings of another model or length.
+var ErrReindexRequired = errors.New("reindex required")
+
+// Check returns an error wrapping ErrReindexRequired unless embeddings described by other can be used with the index of h.
+func (h IndexHeader) Check(other IndexHeader) error {
+	if h != other {
+		return fmt.Errorf("%w: the index was built with %s, not %s", ErrReindexRequired, h, other)
+	}
+	return nil
 }
+
+func (h IndexHeader) String() string {
+	return fmt.Sprintf("%s (%d dimensions)", h.Model, h.Dimensions)
+}
+
 type Metadata struct {
 	ID            string `json:"id"`
 	Filename      string `json:"filename"`
@@ -15,21 +43,39 @@ type Metadata struct {
 	TruncatedEnd  int    `json:"truncatedEnd"`
 	SplitPart     string `json:"splitPart"`
 	RealSplitPart string `json:"realSplitPart"`
+	// LineEnd is the last line of the chunk.
+	LineEnd int `json:"lineEnd,omitempty"`
+	// Symbol names the declarations of the chunk, Receiver the type of a method.
+	Symbol   string `json:"symbol,omitempty"`
+	Receiver string `json:"receiver,omitempty"`
+	// Chunker is the version of the chunker that cut the chunk, "" for the legacy 200 token windows. Start and End
+	// are token offsets for token windows, and byte offsets for chunks cut along the syntax of their file.
+	Chunker string `json:"chunker,omitempty"`
 }
+
+// QualifiedSymbol returns the Symbol of m, prefixed with its Receiver for a method.
+func (m Metadata) QualifiedSymbol() string {
+	if m.Receiver != "" {
+		return m.Receiver + "." + m.Symbol
+	}
+	return m.Symbol
+}
+
 type Embeddings struct {
 	Vectors []*Vector `json:"vectors"`
 }
 
 type Query struct {
-	Values [1536]float32 `json:"values"`
+	Values []float32 `json:"values"`
 }
 type QueryJson struct {
 	Queries []Query `json:"queries"`
 }
 
 type QueryFilter struct {
-	Filter map[string]string `json:"filter"`
-	Values [1536]float32     `json:"values"`
+	// Filter selects the chunks ranked against Values.
+	Filter MetadataFilter `json:"filter"`
+	Values []float32      `json:"values"`
 }
 type QueryRequest struct {
 	TopK            int           `json:"topK"`
@@ -41,6 +87,12 @@ type SearchResult struct {
 	Vector     Vector    `json:"vector"`
 	PCA        []float32 `json:"pca"`
 	Similarity float32   `json:"score"`
+	// VectorScore and LexicalScore are the cosine similarity and the BM25 score of a hybrid search result.
+	// Similarity then holds their fused score.
+	VectorScore  float32 `json:"vectorScore,omitempty"`
+	LexicalScore float32 `json:"lexicalScore,omitempty"`
+	// RerankScore is the relevance from 0 to 100 a model gave a reranked result, nil when it was not reranked.
+	RerankScore *int `json:"rerankScore,omitempty"`
 }
 type SearchResults struct {
 	Results []SearchResult
@@ -16,3 +16,11 @@ type FileReader interface {
 type FileWalker interface {
 	GetFiles() ([]FileReader, error)
 }
+
+// FileStamp records the file an index was built from. A file whose ModTime and Size are unchanged is not read again,
+// otherwise it is changed when its Hash, the hex SHA-256 of its content, differs.
+type FileStamp struct {
+	ModTime int64  `json:"modTime"`
+	Size    int64  `json:"size"`
+	Hash    string `json:"hash"`
+}
@@ -0,0 +1,81 @@
+package types
+
+import (
+	"path"
+	"strings"
+)
+
+// MetadataFilter selects the chunks a search ranks by the file they are from. A chunk is selected when it matches
+// Include, or Include is empty, and does not match Exclude.
+type MetadataFilter struct {
+	Include FileMatcher `json:"include"`
+	Exclude FileMatcher `json:"exclude"`
+}
+
+// FileMatcher matches filenames by path and by extension.
+type FileMatcher struct {
+	// Paths are files, directories or globs. A glob matches the filename or one of its directories, and a glob
+	// without a slash also matches the base name, so "*_test.go" matches "pkg/a_test.go".
+	Paths []string `json:"paths,omitempty"`
+	// Extensions are file extensions without the dot, such as "go".
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+// IsEmpty reports whether f selects every chunk.
+func (f MetadataFilter) IsEmpty() bool {
+	return f.Include.isEmpty() && f.Exclude.isEmpty()
+}
+
+// Match reports whether f selects the chunks of filename.
+func (f MetadataFilter) Match(filename string) bool {
+	include := f.Include
+	if len(include.Paths) > 0 && !matchAnyPath(include.Paths, filename) {
+		return false
+	}
+	if len(include.Extensions) > 0 && !matchAnyExtension(include.Extensions, filename) {
+		return false
+	}
+	return !matchAnyPath(f.Exclude.Paths, filename) && !matchAnyExtension(f.Exclude.Extensions, filename)
+}
+
+func (m FileMatcher) isEmpty() bool {
+	return len(m.Paths) == 0 && len(m.Extensions) == 0
+}
+
+func matchAnyPath(patterns []string, filename string) bool {
+	filename = path.Clean(filename)
+	for _, pattern := range patterns {
+		if matchPath(pattern, filename) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPath(pattern, filename string) bool {
+	pattern = strings.TrimSuffix(path.Clean(pattern), "/")
+	if !strings.ContainsAny(pattern, "*?[") {
+		return filename == pattern || strings.HasPrefix(filename, pattern+"/")
+	}
+	if !strings.Contains(pattern, "/") {
+		if ok, _ := path.Match(pattern, path.Base(filename)); ok {
+			return true
+		}
+	}
+	for dir := filename; dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if ok, _ := path.Match(pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchAnyExtension(extensions []string, filename string) bool {
+	ext := strings.TrimPrefix(path.Ext(filename), ".")
+	for _, extension := range extensions {
+		if ext != "" && strings.EqualFold(ext, strings.TrimPrefix(extension, ".")) {
+			return true
+		}
+	}
+	return false
+}
@@ -29,9 +29,14 @@ type ITzap[T any, Z any] interface {
 	IsolatedTzap(fn func(t T)) T
 	MutationTzap(fn func(t T) T) T
 	Map(func(t T) T) T
+	ParallelMap(workers int, fn func(t T) T) T
 	Accumulate(func(t T) T) T
 	Exit() T
 
+	WithErrorMode() T
+	Err() error
+	Must() T
+
 	ApplyWorkflow(nt NamedWorkflow[T, Z]) T
 	ApplyErrorWorkflow(nt NamedWorkflow[T, Z], fn func(t Z) error) T
 	ApplyWorkflowFN(nt func(t T) T) T
@@ -0,0 +1,9 @@
+package types
+
+// LexicalDocument is a chunk of a file in a lexical index such as BM25, with the frequencies of its terms.
+type LexicalDocument struct {
+	Metadata Metadata       `json:"metadata"`
+	Terms    map[string]int `json:"terms"`
+	// Length is the number of terms of the chunk.
+	Length int `json:"length"`
+}
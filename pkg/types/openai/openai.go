@@ -23,9 +23,11 @@ const (
 	GPT3Curie               = "curie"
 	GPT3Ada                 = "ada"
 	GPT3Babbage             = "babbage"
+	AdaEmbeddingV2          = "text-embedding-ada-002"
 )
 const (
 	ChatMessageRoleSystem    = "system"
 	ChatMessageRoleUser      = "user"
 	ChatMessageRoleAssistant = "assistant"
+	ChatMessageRoleFunction  = "function"
 )
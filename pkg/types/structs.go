@@ -3,5 +3,9 @@ package types
 type Message struct {
 	Role    string
 	Content string
+	// Name is the tool name of a function role message carrying a tool result.
+	Name string `json:",omitempty"`
+	// ToolCall is set on assistant messages in which the model asks to call a tool.
+	ToolCall *ToolCall `json:",omitempty"`
 }
 type MappedInterface map[string]interface{}
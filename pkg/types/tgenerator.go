@@ -9,8 +9,8 @@ import (
 type TGenerator interface {
 	TextToSpeech(ctx context.Context, content, language, voice string) (*[]byte, error)
 	SpeechToText(ctx context.Context, audioContent *[]byte, language string) (string, error)
-	FetchEmbedding(ctx context.Context, content ...string) ([][1536]float32, error)
-	AddEmbeddingDocument(ctx context.Context, id string, embedding [1536]float32, metadata Metadata) error
+	FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error)
+	AddEmbeddingDocument(ctx context.Context, id string, embedding []float32, metadata Metadata) error
 	GetEmbeddingDocument(ctx context.Context, id string) (Vector, bool, error)
 	DeleteEmbeddingDocument(ctx context.Context, id string) error
 	DeleteEmbeddingDocuments(ctx context.Context, ids []string) error
@@ -0,0 +1,42 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ToolDefinition describes a tool the model may call. Parameters is the JSON schema of the arguments.
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  json.RawMessage
+}
+
+// ToolCall is a request from the model to call a tool. Arguments is a JSON object.
+type ToolCall struct {
+	Name      string
+	Arguments string
+}
+
+// ChatResponse is a chat completion that is either a final answer in Content or a ToolCall.
+type ChatResponse struct {
+	Content  string
+	ToolCall *ToolCall
+}
+
+// ToolChatGenerator is implemented by TGenerators whose chat completions support tool calling.
+type ToolChatGenerator interface {
+	GenerateChatWithTools(ctx context.Context, messages []Message, tools []ToolDefinition, stream bool) (ChatResponse, error)
+}
+
+// JSONChatGenerator is implemented by TGenerators that can constrain a chat completion to JSON matching an object schema.
+type JSONChatGenerator interface {
+	GenerateChatJSON(ctx context.Context, messages []Message, schema json.RawMessage, stream bool) (string, error)
+}
+
+// StreamChatGenerator is implemented by TGenerators that can stream a chat completion.
+// GenerateChatStream calls onDelta with each part of the answer as it arrives and returns the whole answer.
+// An error returned by onDelta cancels the request and is returned.
+type StreamChatGenerator interface {
+	GenerateChatStream(ctx context.Context, messages []Message, onDelta func(delta string) error) (string, error)
+}
@@ -0,0 +1,43 @@
+package types
+
+import (
+	"context"
+
+	"github.com/tzapio/tzap/pkg/models"
+)
+
+// Usage is the token usage and cost of one model call.
+type Usage struct {
+	// Method is "GenerateChat" for chat completions and "FetchEmbedding" for embeddings.
+	Method           string `json:"method"`
+	Model            string `json:"model"`
+	PromptTokens     int    `json:"promptTokens"`
+	CompletionTokens int    `json:"completionTokens"`
+	// Cost is in USD, 0 for models without a price.
+	Cost float64 `json:"cost"`
+	// Estimated marks usage counted with the local tokenizer because the API did not report it, as for streamed completions.
+	Estimated bool `json:"estimated,omitempty"`
+}
+
+// NewUsage returns the usage of a call with its cost computed from the prices in the models registry.
+func NewUsage(method, model string, promptTokens, completionTokens int) Usage {
+	usage := Usage{Method: method, Model: model, PromptTokens: promptTokens, CompletionTokens: completionTokens}
+	if registered, ok := models.Get(model); ok {
+		usage.Cost = registered.Cost(promptTokens, completionTokens)
+	}
+	return usage
+}
+
+type usageReporterKey struct{}
+
+// WithUsageReporter returns a context whose model calls pass their usage to report.
+func WithUsageReporter(ctx context.Context, report func(Usage)) context.Context {
+	return context.WithValue(ctx, usageReporterKey{}, report)
+}
+
+// ReportUsage passes the usage of a model call to the reporter of ctx, if any. Connectors call it once per call.
+func ReportUsage(ctx context.Context, usage Usage) {
+	if report, ok := ctx.Value(usageReporterKey{}).(func(Usage)); ok {
+		report(usage)
+	}
+}
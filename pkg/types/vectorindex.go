@@ -0,0 +1,15 @@
+package types
+
+// VectorIndex finds the vectors of an embedding collection most similar to a query without comparing the query
+// with each of them. It is kept up to date as vectors are added to and deleted from the collection.
+type VectorIndex interface {
+	// Add adds or replaces the vector of id.
+	Add(id string, values []float32) error
+	Delete(ids ...string) error
+	// Search returns the ids of about the k vectors most similar to values with their cosine similarity, best first.
+	// Only ids for which keep returns true are returned; a nil keep keeps all of them.
+	Search(values []float32, k int, keep func(id string) bool) ([]KeyValue[float32], error)
+	Len() (int, error)
+	// Save persists the changes made since the index was loaded.
+	Save() error
+}
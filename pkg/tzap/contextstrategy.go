@@ -0,0 +1,349 @@
+package tzap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
+)
+
+// Names of the built-in context strategies, selected with config.Configuration.ContextStrategy.
+const (
+	// ContextStrategyNewest pins system messages and keeps the newest other messages that fit. It is the default.
+	ContextStrategyNewest = "newest"
+	// ContextStrategyRelevance drops the lowest scoring embedded search results first, then falls back to ContextStrategyNewest.
+	ContextStrategyRelevance = "relevance"
+	// ContextStrategySummarize replaces the oldest turns with one summary message written by the model.
+	ContextStrategySummarize = "summarize"
+)
+
+// RelevanceKey holds the search score of a message added from an embedding search result.
+// Messages carrying it are the first ones dropped by ContextStrategyRelevance.
+var RelevanceKey = Key[float32]("relevance")
+
+// ContextReportKey holds the ContextReport of the request made by RequestChatCompletion.
+var ContextReportKey = Key[ContextReport]("contextReport")
+
+// ContextMessage is a thread message together with what a ContextStrategy needs to pick what to drop.
+type ContextMessage struct {
+	types.Message
+	// Embedded marks messages added from embedding search results.
+	Embedded bool
+	// Relevance is the search score of an embedded message.
+	Relevance float32
+}
+
+// ContextReport describes what a ContextStrategy removed to fit a thread into its token limit.
+type ContextReport struct {
+	Strategy string
+	// Tokens is the token count of the fitted thread.
+	Tokens int
+	// Dropped lists the messages that were removed.
+	Dropped []ContextMessage
+	// Summarized lists the messages that were replaced by a summary.
+	Summarized []ContextMessage
+}
+
+// Changed reports whether the strategy dropped or summarized any message.
+func (r ContextReport) Changed() bool {
+	return len(r.Dropped) > 0 || len(r.Summarized) > 0
+}
+
+func (r ContextReport) String() string {
+	embedded := 0
+	for _, message := range r.Dropped {
+		if message.Embedded {
+			embedded++
+		}
+	}
+	parts := []string{fmt.Sprintf("dropped %d messages (%d embedded results)", len(r.Dropped), embedded)}
+	if len(r.Summarized) > 0 {
+		parts = append(parts, fmt.Sprintf("summarized %d messages", len(r.Summarized)))
+	}
+	return fmt.Sprintf("context %s: %s, %d tokens kept", r.Strategy, strings.Join(parts, ", "), r.Tokens)
+}
+
+// ContextStrategy fits a thread into maxTokens tokens and reports what it removed.
+type ContextStrategy interface {
+	Fit(ctx context.Context, tg types.TGenerator, messages []ContextMessage, maxTokens int) ([]types.Message, ContextReport, error)
+}
+
+var (
+	contextStrategiesLock sync.RWMutex
+	contextStrategies     = map[string]ContextStrategy{
+		ContextStrategyNewest:    NewestContextStrategy{},
+		ContextStrategyRelevance: RelevanceContextStrategy{},
+		ContextStrategySummarize: SummarizeContextStrategy{},
+	}
+)
+
+// RegisterContextStrategy makes a strategy selectable by name through config.Configuration.ContextStrategy.
+func RegisterContextStrategy(name string, strategy ContextStrategy) {
+	contextStrategiesLock.Lock()
+	defer contextStrategiesLock.Unlock()
+	contextStrategies[name] = strategy
+}
+
+// GetContextStrategy returns the strategy registered under name. An empty name returns ContextStrategyNewest.
+func GetContextStrategy(name string) (ContextStrategy, error) {
+	if name == "" {
+		name = ContextStrategyNewest
+	}
+	contextStrategiesLock.RLock()
+	defer contextStrategiesLock.RUnlock()
+	strategy, ok := contextStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown context strategy %q", name)
+	}
+	return strategy, nil
+}
+
+// ContextMessages wraps plain messages for a ContextStrategy.
+func ContextMessages(messages []types.Message) []ContextMessage {
+	contextMessages := make([]ContextMessage, len(messages))
+	for i, message := range messages {
+		contextMessages[i] = ContextMessage{Message: message}
+	}
+	return contextMessages
+}
+
+// FitContext fits messages into the configured TruncateLimit with the configured ContextStrategy.
+func (t *Tzap) FitContext(messages []ContextMessage) ([]types.Message, ContextReport, error) {
+	return t.FitContextTokens(messages, config.FromContext(t.C).TruncateLimit)
+}
+
+// FitContextTokens fits messages into maxTokens with the configured ContextStrategy. A maxTokens of 0 keeps every message.
+func (t *Tzap) FitContextTokens(messages []ContextMessage, maxTokens int) ([]types.Message, ContextReport, error) {
+	name := config.FromContext(t.C).ContextStrategy
+	strategy, err := GetContextStrategy(name)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	if maxTokens < 0 {
+		return nil, ContextReport{}, fmt.Errorf("context token limit is %d, set above 1, or 0 to allow unlimited until model fails", maxTokens)
+	}
+	if maxTokens == 0 {
+		return plainMessages(messages), ContextReport{Strategy: name}, nil
+	}
+	return strategy.Fit(t.UsageContext(), t.TG, messages, maxTokens)
+}
+
+func plainMessages(messages []ContextMessage) []types.Message {
+	plain := make([]types.Message, len(messages))
+	for i, message := range messages {
+		plain[i] = message.Message
+	}
+	return plain
+}
+
+func countContextTokens(ctx context.Context, tg types.TGenerator, messages []ContextMessage) ([]int, int, error) {
+	counts := make([]int, len(messages))
+	total := 0
+	for i, message := range messages {
+		count, err := tg.CountTokens(ctx, message.Content)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error counting tokens: %w", err)
+		}
+		counts[i] = count
+		total += count
+	}
+	return counts, total, nil
+}
+
+func isPinned(message ContextMessage) bool {
+	return message.Role == openai.ChatMessageRoleSystem && !message.Embedded
+}
+
+// NewestContextStrategy pins system messages and keeps the newest other messages that fit.
+type NewestContextStrategy struct{}
+
+func (NewestContextStrategy) Fit(ctx context.Context, tg types.TGenerator, messages []ContextMessage, maxTokens int) ([]types.Message, ContextReport, error) {
+	counts, _, err := countContextTokens(ctx, tg, messages)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	keep, tokens, err := keepNewest(messages, counts, maxTokens)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	fitted, report := applyKeep(messages, keep)
+	report.Strategy = ContextStrategyNewest
+	report.Tokens = tokens
+	return fitted, report, nil
+}
+
+// keepNewest marks the pinned messages and then the newest messages that still fit.
+func keepNewest(messages []ContextMessage, counts []int, maxTokens int) ([]bool, int, error) {
+	keep := make([]bool, len(messages))
+	tokens := 0
+	for i, message := range messages {
+		if isPinned(message) {
+			keep[i] = true
+			tokens += counts[i]
+		}
+	}
+	if tokens > maxTokens {
+		return nil, 0, fmt.Errorf("system messages need %d tokens, more than the context limit of %d", tokens, maxTokens)
+	}
+	for i := len(messages) - 1; i >= 0; i-- {
+		if keep[i] {
+			continue
+		}
+		if tokens+counts[i] > maxTokens {
+			break
+		}
+		keep[i] = true
+		tokens += counts[i]
+	}
+	return keep, tokens, nil
+}
+
+func applyKeep(messages []ContextMessage, keep []bool) ([]types.Message, ContextReport) {
+	var fitted []types.Message
+	var report ContextReport
+	for i, message := range messages {
+		if keep[i] {
+			fitted = append(fitted, message.Message)
+			continue
+		}
+		report.Dropped = append(report.Dropped, message)
+	}
+	return fitted, report
+}
+
+// RelevanceContextStrategy drops the lowest scoring embedded messages until the thread fits,
+// then falls back to NewestContextStrategy for the rest.
+type RelevanceContextStrategy struct{}
+
+func (RelevanceContextStrategy) Fit(ctx context.Context, tg types.TGenerator, messages []ContextMessage, maxTokens int) ([]types.Message, ContextReport, error) {
+	counts, total, err := countContextTokens(ctx, tg, messages)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+
+	var embedded []int
+	for i, message := range messages {
+		if message.Embedded {
+			embedded = append(embedded, i)
+		}
+	}
+	sort.SliceStable(embedded, func(a, b int) bool {
+		return messages[embedded[a]].Relevance < messages[embedded[b]].Relevance
+	})
+
+	dropped := map[int]bool{}
+	for _, i := range embedded {
+		if total <= maxTokens {
+			break
+		}
+		dropped[i] = true
+		total -= counts[i]
+	}
+
+	var remaining []ContextMessage
+	var remainingCounts []int
+	var report ContextReport
+	for i, message := range messages {
+		if dropped[i] {
+			report.Dropped = append(report.Dropped, message)
+			continue
+		}
+		remaining = append(remaining, message)
+		remainingCounts = append(remainingCounts, counts[i])
+	}
+
+	keep, tokens, err := keepNewest(remaining, remainingCounts, maxTokens)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	fitted, newestReport := applyKeep(remaining, keep)
+	report.Dropped = append(report.Dropped, newestReport.Dropped...)
+	report.Strategy = ContextStrategyRelevance
+	report.Tokens = tokens
+	return fitted, report, nil
+}
+
+// SummarizeContextStrategy asks the model to summarize the oldest turns that do not fit into one system message.
+// A quarter of the limit is reserved for the summary.
+type SummarizeContextStrategy struct{}
+
+func (SummarizeContextStrategy) Fit(ctx context.Context, tg types.TGenerator, messages []ContextMessage, maxTokens int) ([]types.Message, ContextReport, error) {
+	counts, total, err := countContextTokens(ctx, tg, messages)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	if total <= maxTokens {
+		return plainMessages(messages), ContextReport{Strategy: ContextStrategySummarize, Tokens: total}, nil
+	}
+
+	summaryTokens := maxTokens / 4
+	keep, _, err := keepNewest(messages, counts, maxTokens-summaryTokens)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	var summarized []ContextMessage
+	firstKept := -1
+	for i, message := range messages {
+		if !keep[i] {
+			summarized = append(summarized, message)
+		} else if firstKept == -1 && !isPinned(message) {
+			firstKept = i
+		}
+	}
+
+	summary, err := summarizeMessages(ctx, tg, summarized, summaryTokens)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	summaryMessage := ContextMessage{Message: types.Message{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}}
+
+	var withSummary []ContextMessage
+	inserted := false
+	for i, message := range messages {
+		if i == firstKept {
+			withSummary = append(withSummary, summaryMessage)
+			inserted = true
+		}
+		if keep[i] {
+			withSummary = append(withSummary, message)
+		}
+	}
+	if !inserted {
+		withSummary = append(withSummary, summaryMessage)
+	}
+
+	// The summary may come back longer than asked for; fall back to keeping the newest messages.
+	fitted, report, err := NewestContextStrategy{}.Fit(ctx, tg, withSummary, maxTokens)
+	if err != nil {
+		return nil, ContextReport{}, err
+	}
+	report.Strategy = ContextStrategySummarize
+	report.Summarized = summarized
+	return fitted, report, nil
+}
+
+func summarizeMessages(ctx context.Context, tg types.TGenerator, messages []ContextMessage, maxTokens int) (string, error) {
+	var transcript strings.Builder
+	for _, message := range messages {
+		transcript.WriteString(message.Role + ": " + message.Content + "\n\n")
+	}
+	summary, err := tg.GenerateChat(ctx, []types.Message{
+		{
+			Role:    openai.ChatMessageRoleSystem,
+			Content: fmt.Sprintf("Summarize the following conversation in at most %d tokens. Keep decisions, requirements, file names and code identifiers.", maxTokens),
+		},
+		{Role: openai.ChatMessageRoleUser, Content: transcript.String()},
+	}, false)
+	if err != nil {
+		return "", fmt.Errorf("error summarizing context: %w", err)
+	}
+	return summary, nil
+}
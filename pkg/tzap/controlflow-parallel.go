@@ -0,0 +1,146 @@
+package tzap
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// ParallelMap works like Map but runs fn on up to workers children at the same time.
+// The mapped children keep the order of the original children. The first failing child
+// cancels the context of the remaining children and its error is raised like any other chain failure.
+// The cancellation only applies while the workers run, so the mapped children can be chained further.
+func (t *Tzap) ParallelMap(workers int, fn func(*Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
+	Log(t, "START PARALLEL MAP", len(children), "workers", workers)
+
+	mappedChildren, err := runParallel(t, workers, children, fn)
+	if err != nil {
+		return t.Fail(err)
+	}
+	tzmapped := t.AddTzap(&Tzap{Name: "ParallelMap", Message: t.Message, Data: types.MappedInterface{"children": mappedChildren}})
+	return tzmapped
+}
+
+// ParallelEach works like Each but runs fn on up to workers children at the same time.
+func (t *Tzap) ParallelEach(workers int, fn func(*Tzap)) *Tzap {
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
+	Log(t, "ParallelEach start", len(children), "workers", workers)
+
+	_, err = runParallel(t, workers, children, func(child *Tzap) *Tzap {
+		fn(child)
+		return child
+	})
+	if err != nil {
+		return t.Fail(err)
+	}
+	return t
+}
+
+// parallelContext keeps the values of a child's own context but takes its cancellation from the parallel run.
+type parallelContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c parallelContext) Value(key any) any {
+	return c.values.Value(key)
+}
+
+func runParallel(t *Tzap, workers int, children []*Tzap, fn func(*Tzap) *Tzap) ([]*Tzap, error) {
+	if workers < 1 {
+		workers = 1
+	}
+	ctx, cancel := context.WithCancel(t.C)
+	defer cancel()
+
+	var (
+		results  = make([]*Tzap, len(children))
+		jobs     = make(chan int)
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				mapped, err := runParallelChild(ctx, children[i], fn)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("parallel child %d (%s): %w", i, children[i].Name, err)
+						cancel()
+					})
+					continue
+				}
+				results[i] = mapped
+			}
+		}()
+	}
+
+feed:
+	for i := range children {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	for _, mapped := range results {
+		restoreContexts(mapped)
+	}
+
+	if firstErr == nil && ctx.Err() != nil && t.C.Err() != nil {
+		firstErr = t.C.Err()
+	}
+	return results, firstErr
+}
+
+func runParallelChild(ctx context.Context, child *Tzap, fn func(*Tzap) *Tzap) (mapped *Tzap, err error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = recoveredError(r)
+		}
+	}()
+	// Stand in for the child with an identical tzap whose context is cancelled with the run.
+	runner := child.HijackTzap(&Tzap{
+		Name:                 child.Name,
+		InitialSystemContent: child.InitialSystemContent,
+		Message:              child.Message,
+		Data:                 child.Data,
+	})
+	runner.C = parallelContext{Context: ctx, values: child.C}
+	runner.TG = child.TG
+	mapped = fn(runner)
+	return mapped, mapped.Err()
+}
+
+// restoreContexts gives the tzaps leading to mapped that inherited the context of a parallel run back their own
+// context, which the end of the run does not cancel.
+func restoreContexts(mapped *Tzap) {
+	for tz := mapped; tz != nil; tz = tz.Parent {
+		if c, ok := tz.C.(parallelContext); ok {
+			tz.C = c.values
+		}
+	}
+}
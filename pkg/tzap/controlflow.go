@@ -5,12 +5,20 @@ import "github.com/tzapio/tzap/pkg/types"
 
 // MutationTzap applies the provided function to the current Tzap object and returns a new Tzap object.
 func (t *Tzap) MutationTzap(fn func(*Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Mutation Tzap")
-	return fn(t)
+	return t.recoverFail(func() *Tzap {
+		return fn(t)
+	})
 }
 
 // WorkTzap executes the provided function and returns the Tzap object.
 func (t *Tzap) If(b bool, tfn func(*Tzap) *Tzap, ffn func(*Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "If Tzap")
 	if b {
 		return tfn(t)
@@ -21,72 +29,128 @@ func (t *Tzap) If(b bool, tfn func(*Tzap) *Tzap, ffn func(*Tzap) *Tzap) *Tzap {
 
 // WorkTzap executes the provided function and returns the Tzap object.
 func (t *Tzap) WorkTzap(fn func(*Tzap)) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Work Tzap")
 	tb := t.CloneTzap(&Tzap{Name: "Work"})
-	fn(tb)
-	return t
+	return t.recoverFail(func() *Tzap {
+		fn(tb)
+		return t
+	})
 }
 
 // IsolatedTzap executes the provided function with a new isolated Tzap object.
 // It does not modify the current Tzap object but returns it.
 func (t *Tzap) IsolatedTzap(fn func(*Tzap)) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Isolated Tzap")
 	isolated := t.CopyConnection()
-	fn(isolated)
-	return t
+	return t.recoverFail(func() *Tzap {
+		fn(isolated)
+		return t
+	})
 }
 
-// Exit raises a panic to exit from the current Tzap.
+// Exit raises a panic to exit from the current Tzap. In error mode it sets ErrExit as the sticky error instead.
 func (t *Tzap) Exit() *Tzap {
 	Log(t, "Exit Tzap")
-	panic("parachute exit")
+	return t.Fail(ErrExit)
+}
+
+// children returns the Tzaps exposed in Data["children"], e.g. by LoadFiles.
+func (t *Tzap) children() ([]*Tzap, error) {
+	return ChildrenKey.Get(t)
 }
 
 // Map iterates through the children Tzap objects and applies the provided function.
 // Returns a new Tzap object containing the result of the function application.
 func (t *Tzap) Map(fn func(*Tzap) *Tzap) *Tzap {
-	children := t.Data["children"].([]*Tzap)
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
 	Log(t, "START MAP", len(children))
 	mappedChildren := make([]*Tzap, len(children))
 
 	for i, child := range children {
 		Log(t, "MAP child I", i)
-		mappedChildren[i] = fn(child)
+		mappedChildren[i] = t.recoverFail(func() *Tzap { return fn(child) })
+		if err := mappedChildren[i].Err(); err != nil {
+			return t.Fail(err)
+		}
 	}
 	tzmapped := t.AddTzap(&Tzap{Name: "Map", Message: t.Message, Data: types.MappedInterface{"children": mappedChildren}})
 	return tzmapped
 }
 
 func (t *Tzap) Reduce(fn func(*Tzap, *Tzap) *Tzap) *Tzap {
-	children := t.Data["children"].([]*Tzap)
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
 	Log(t, "START REDUCE", len(children))
 
 	reducedTzap := t.AddTzap(&Tzap{Name: "Reduce"})
 
 	for i, child := range children {
 		Log(t, "REDUCE child I", i)
-		reducedTzap = fn(reducedTzap, child)
+		previous := reducedTzap
+		reducedTzap = t.recoverFail(func() *Tzap { return fn(previous, child) })
+		if reducedTzap.err != nil {
+			return reducedTzap
+		}
 	}
 
 	return reducedTzap
 }
 func (t *Tzap) Accumulate(fn func(*Tzap) *Tzap) *Tzap {
-	children := t.Data["children"].([]*Tzap)
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
 	Log(t, "Accumulate start", len(children))
 	tzmapped := t.AddTzap(&Tzap{Name: "Accumulate"})
 	for _, child := range children {
 		child.Parent = tzmapped
-		tzmapped = tzmapped.ApplyWorkflowP(fn(child))
+		accumulated := t.recoverFail(func() *Tzap { return fn(child) })
+		if accumulated.err != nil {
+			return accumulated
+		}
+		tzmapped = tzmapped.ApplyWorkflowP(accumulated)
 	}
 	return tzmapped
 }
 
 func (t *Tzap) Each(fn func(*Tzap)) *Tzap {
-	children := t.Data["children"].([]*Tzap)
+	if t.err != nil {
+		return t
+	}
+	children, err := t.children()
+	if err != nil {
+		return t.Fail(err)
+	}
 	Log(t, "Each start", len(children))
 
 	for _, child := range children {
-		fn(child)
+		each := t.recoverFail(func() *Tzap {
+			fn(child)
+			return t
+		})
+		if each.err != nil {
+			return each
+		}
 	}
 	return t
 }
@@ -94,6 +158,9 @@ func (t *Tzap) Each(fn func(*Tzap)) *Tzap {
 // Recursive applies the provided function recursively to the Tzap object
 // and its children.
 func (t *Tzap) Recursive(tf func(tzapThatCreatesNewChildren *Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	recursive := func(t *Tzap) *Tzap {
 		return t.Recursive(tf)
 	}
@@ -1,18 +1,26 @@
 package tzap
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
 )
 
+// ErrExit is raised by Exit.
+var ErrExit = errors.New("parachute exit")
+
 type ErrorTzap struct {
 	Tzap *Tzap
 	Err  error
 }
 
+// ErrorTzap wraps err, or the sticky error of the chain when err is nil, for HandleError and ApplyErrorWorkflow.
 func (t *Tzap) ErrorTzap(err error) *ErrorTzap {
-	if err != nil {
+	if err == nil {
+		err = t.err
+	}
+	if err != nil && err != t.err {
 		Logf(t, "Error tzap (%s)", err.Error())
 		err = fmt.Errorf("error tzap %s (%d): %v", t.Name, t.Id, err)
 	}
@@ -27,20 +35,88 @@ func (t *ErrorTzap) HandleError(cb func(*ErrorTzap) error) *Tzap {
 	if t.Err != nil {
 		r := cb(t)
 		if r != nil {
-			panic(r)
+			return t.Tzap.Fail(r)
 		}
 	}
 	return t.Tzap
 }
 
+// ToTzap returns the wrapped Tzap with Err as its sticky error, so the chain continues as an error-carrying chain.
+func (t *ErrorTzap) ToTzap() *Tzap {
+	if t.Err == nil {
+		return t.Tzap
+	}
+	return t.Tzap.WithErrorMode().Fail(t.Err)
+}
+
+// WithErrorMode returns a Tzap whose chain carries errors instead of panicking.
+// Once a call fails, the error is kept on the returned Tzap and every following chained call is a no-op.
+// Check the outcome at the end of the chain with Err or Must.
+func (t *Tzap) WithErrorMode() *Tzap {
+	if t.err != nil || t.errorMode {
+		return t
+	}
+	errorTzap := t.AddTzap(&Tzap{Name: "ErrorMode"})
+	errorTzap.errorMode = true
+	return errorTzap
+}
+
+// Err returns the sticky error of the chain, or nil.
+func (t *Tzap) Err() error {
+	return t.err
+}
+
+// Must returns the Tzap, or panics with the sticky error of the chain.
+func (t *Tzap) Must() *Tzap {
+	if t.err != nil {
+		panic(t.err)
+	}
+	return t
+}
+
+// Fail panics with err, or in error mode returns a Tzap carrying err.
+func (t *Tzap) Fail(err error) *Tzap {
+	if t.err != nil {
+		return t
+	}
+	if !t.errorMode {
+		panic(err)
+	}
+	Logf(t, "Error tzap (%s)", err.Error())
+	failed := t.AddTzap(&Tzap{Name: "Error"})
+	failed.err = fmt.Errorf("tzap %s (%d): %w", t.Name, t.Id, err)
+	return failed
+}
+
+// recoverFail turns a panic raised inside fn into the sticky error of the chain when in error mode.
+func (t *Tzap) recoverFail(fn func() *Tzap) (result *Tzap) {
+	if !t.errorMode {
+		return fn()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			result = t.Fail(recoveredError(r))
+		}
+	}()
+	return fn()
+}
+
+// recoveredError converts a recovered panic value into an error.
+func recoveredError(r any) error {
+	if err, ok := r.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%v", r)
+}
+
 func tzapHandlePanic(err *error) {
 	if r := recover(); r != nil {
-		*err = r.(error)
+		*err = recoveredError(r)
 		stack := make([]byte, 4096)
 		length := runtime.Stack(stack, true)
 
 		// Print the error message
-		println(r.(error).Error())
+		println((*err).Error())
 		// Print the stack trace
 		fmt.Fprintf(os.Stderr, "%s\n", stack[:length])
 	}
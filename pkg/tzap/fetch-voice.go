@@ -7,7 +7,11 @@ import (
 // RequestTextToSpeech requests synthesized speech using specific (google voices) language and voice.
 // It returns a pointer to a new Tzap containing the synthesised speech 'audioContent'.
 func (t *Tzap) RequestTextToSpeech(language string, voice string) *ErrorTzap {
-	audioContent, err := t.TG.TextToSpeech(t.C, t.Data["content"].(string), language, voice)
+	content, err := ContentKey.Get(t)
+	if err != nil {
+		return t.ErrorTzap(err)
+	}
+	audioContent, err := t.TG.TextToSpeech(t.C, content, language, voice)
 	if err != nil {
 		return t.ErrorTzap(err)
 	}
@@ -11,19 +11,25 @@ import (
 
 // LoadFileDir exposes an array of Tzaps in the previous elements .Data["children"]. Each child is a .LoadTask(file)
 func (t *Tzap) LoadFileDir(dir string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	_, err := os.ReadDir(dir)
 	if err != nil {
-		panic(fmt.Errorf("cannot read directory: %w", err))
+		return t.Fail(fmt.Errorf("cannot read directory: %w", err))
 	}
 	files, err := util.ListFilesInDir(dir)
 	if err != nil {
-		panic(fmt.Errorf("cannot list files in directory: %w", err))
+		return t.Fail(fmt.Errorf("cannot list files in directory: %w", err))
 	}
 	return t.LoadFiles(files)
 }
 
 // LoadFiles exposes an array of Tzaps in with .Data["children"]. Each child is a .LoadTask(file)
 func (t *Tzap) LoadFiles(filepaths []string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	var ts []*Tzap
 	t = t.AddTzap(&Tzap{
 		Name: "LoadFiles",
@@ -33,21 +39,28 @@ func (t *Tzap) LoadFiles(filepaths []string) *Tzap {
 		// Check if the file is a regular file and its name contains "test" if test is true.
 		if info, err := os.Stat(file); err == nil && !info.IsDir() {
 			// Load the file content and create a Tzap with the file content as the message content.
-			ts = append(ts, t.LoadFileAsCompletion(file))
+			loaded := t.LoadFileAsCompletion(file)
+			if loaded.err != nil {
+				return loaded
+			}
+			ts = append(ts, loaded)
 		} else if err != nil {
-			panic(err)
+			return t.Fail(err)
 		}
 	}
-	t.Data["children"] = ts
+	ChildrenKey.Set(t, ts)
 	return t
 }
 
 // LoadFileAsCompletion loads a file and returns a Tzap with the file's content
 func (t *Tzap) LoadFileAsCompletion(filePath string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Adding file", filePath)
 	originalContent, err := os.ReadFile(filePath)
 	if err != nil {
-		panic(fmt.Errorf("cannot add file: %w", err))
+		return t.Fail(fmt.Errorf("cannot add file: %w", err))
 	}
 
 	data := types.MappedInterface{
@@ -69,6 +82,9 @@ func (t *Tzap) LoadFileAsCompletion(filePath string) *Tzap {
 
 // LoadTaskOrRequestNewTask loads a file if it exists, otherwise requests a new file content from OpenAI and applies the changes to the original file
 func (t *Tzap) LoadCompletionOrRequestCompletion(filePath string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Opening", filePath)
 	t = t.AddTzap(&Tzap{
 		Name: "LoadCompletionOrRequestCompletion"})
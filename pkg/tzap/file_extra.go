@@ -13,13 +13,19 @@ import (
 
 // ChangeFilepath updates the filepath metadata in the Tzap data
 func (t *Tzap) ChangeFilepath(filepath string) *Tzap {
-	t.Data["filepath"] = filepath
+	if t.err != nil {
+		return t
+	}
+	FilepathKey.Set(t, filepath)
 	return t
 }
 
 // LoadCompletionOrRequestCompletionMD5 loads a task file if it exists and its MD5 checksum matches,
 // otherwise requests a new task content from OpenAI and applies the changes to the original file
 func (t *Tzap) LoadCompletionOrRequestCompletionMD5(filePath string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	config := config.FromContext(t.C)
 	md5memory := getMessageMD5(t)
 	md5file := util.ReadFileP(filePath + ".md5")
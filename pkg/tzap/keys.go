@@ -0,0 +1,60 @@
+package tzap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Key is a typed key into Tzap.Data. Declare keys once and use Get and Set instead of raw type assertions:
+//
+//	var ContentKey = tzap.Key[string]("content")
+//	content, err := ContentKey.Get(t)
+type Key[T any] string
+
+// Built-in keys set by the tzap package.
+var (
+	ContentKey  = Key[string]("content")
+	FilepathKey = Key[string]("filepath")
+	ChildrenKey = Key[[]*Tzap]("children")
+	MemoryKey   = Key[string]("memory")
+)
+
+// Get returns the value stored under the key in t.Data. It returns an error naming the tzap path
+// when the key is missing or holds a value of another type.
+func (k Key[T]) Get(t *Tzap) (T, error) {
+	var zero T
+	raw, ok := t.Data[string(k)]
+	if !ok {
+		return zero, fmt.Errorf("tzap %s: data key %q is not set", strings.Join(GetNames(t), "-"), string(k))
+	}
+	value, ok := raw.(T)
+	if !ok {
+		return zero, fmt.Errorf("tzap %s: data key %q holds %T, expected %T", strings.Join(GetNames(t), "-"), string(k), raw, zero)
+	}
+	return value, nil
+}
+
+// Must returns the value stored under the key, or panics with the error from Get.
+func (k Key[T]) Must(t *Tzap) T {
+	value, err := k.Get(t)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// Lookup returns the value stored under the key, or the zero value and false if it is missing or of another type.
+func (k Key[T]) Lookup(t *Tzap) (T, bool) {
+	value, ok := t.Data[string(k)].(T)
+	return value, ok
+}
+
+// Set stores value under the key in t.Data.
+func (k Key[T]) Set(t *Tzap, value T) {
+	if t.Data == nil {
+		t.Data = types.MappedInterface{}
+	}
+	t.Data[string(k)] = value
+}
@@ -138,23 +138,31 @@ func writeSubgraph(dotBuilder *strings.Builder, subgraph *GraphVizSubgraph) {
 	dotBuilder.WriteString("\t}\n")
 }
 
+// FillGraphVizGraph builds the graph of the DefaultSession.
 func FillGraphVizGraph() *GraphVizGraph {
+	return DefaultSession.FillGraphVizGraph()
+}
+
+// FillGraphVizGraph builds the graph of every Tzap and chat logged in the session.
+func (s *Session) FillGraphVizGraph() *GraphVizGraph {
 	graph := &GraphVizGraph{}
 	tzapNodes := make(map[int]*GraphVizNode)
-	for _, t := range GlobalTzaps {
+	for _, t := range s.Tzaps() {
 		metadataLabel := generateGraphvizDotFile2(t)
 		label := fmt.Sprintf("%s (%d) %s", t.Name, t.Id, metadataLabel)
 		node := &GraphVizNode{Id: fmt.Sprintf("tzap_%d", t.Id), Label: label}
 		tzapNodes[t.Id] = node
 		graph.Nodes = append(graph.Nodes, node)
 
-		if t.Parent != nil {
-			edge := &GraphVizEdge{FromNode: tzapNodes[t.Parent.Id], ToNode: node}
-			graph.Edges = append(graph.Edges, edge)
+		// The parent is missing from the graph when it belongs to another session.
+		if t.Parent != nil && t.Parent.session() == s {
+			if parentNode, ok := tzapNodes[t.Parent.Id]; ok {
+				graph.Edges = append(graph.Edges, &GraphVizEdge{FromNode: parentNode, ToNode: node})
+			}
 		}
 	}
 
-	for j, thread := range GlobalGraphVizLogThreads {
+	for j, thread := range s.graphVizLogThreadsSnapshot() {
 		messageNodes := make(map[int]*GraphVizNode)
 		messageEdges := make([]*GraphVizEdge, 0)
 		chatId := fmt.Sprintf("cluster_chat_%d", j)
@@ -173,14 +181,18 @@ func FillGraphVizGraph() *GraphVizGraph {
 			messageNode := &GraphVizNode{Id: msgId, Label: label, Tooltip: tooltip}
 			messageNodes[i] = messageNode
 
+			// The tzap of a message is missing when it was added after the tzaps were listed, by another goroutine.
+			tzapNode, ok := tzapNodes[msg.TzapId]
 			if msg.Direction == "REQUEST" {
 				requestSubgraph.Nodes = append(requestSubgraph.Nodes, messageNode)
-				edge := &GraphVizEdge{Style: "dotted", FromNode: tzapNodes[msg.TzapId], ToNode: messageNode}
-				messageEdges = append(messageEdges, edge)
+				if ok {
+					messageEdges = append(messageEdges, &GraphVizEdge{Style: "dotted", FromNode: tzapNode, ToNode: messageNode})
+				}
 			} else if msg.Direction == "RESPONSE" {
 				responseSubgraph.Nodes = append(responseSubgraph.Nodes, messageNode)
-				edge := &GraphVizEdge{Style: "dotted", FromNode: messageNode, ToNode: tzapNodes[msg.TzapId]}
-				messageEdges = append(messageEdges, edge)
+				if ok {
+					messageEdges = append(messageEdges, &GraphVizEdge{Style: "dotted", FromNode: messageNode, ToNode: tzapNode})
+				}
 			}
 
 			if i > 0 {
@@ -206,7 +218,7 @@ func FillGraphVizGraph() *GraphVizGraph {
 func generateGraphvizDotFile2(t *Tzap) string {
 	truncMsg := fmt.Sprintf("%.30s", t.Message.Content)
 	metadataLabel := ""
-	filepath, ok := t.Data["filepath"].(string)
+	filepath, ok := FilepathKey.Lookup(t)
 	if ok {
 		metadataLabel += fmt.Sprintf("\n<b>File out:</b> %s", filepath)
 	}
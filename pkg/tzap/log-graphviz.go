@@ -1,16 +1,33 @@
 package tzap
 
 import (
+	"fmt"
+	"os"
 	"strings"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
 )
 
+// GlobalTzaps lists the Tzaps created in the DefaultSession.
+//
+// Deprecated: use Session.Tzaps.
 var GlobalTzaps []*Tzap
 
+// HandleShutdown writes the graphviz log, flushes the log buffer and prints the usage summary of the session.
+// The usage is also appended to config.Configuration.UsageLog when it is set.
 func (t *Tzap) HandleShutdown() {
-	GenerateGraphvizDotFile(t, FillGraphVizGraph())
-	Flush()
+	session := t.session()
+	GenerateGraphvizDotFile(t, session.FillGraphVizGraph())
+	session.Flush()
+	if summary := session.UsageSummary(); summary != "" {
+		fmt.Fprint(os.Stderr, summary)
+	}
+	if usageLog := config.FromContext(t.C).UsageLog; usageLog != "" {
+		if err := session.AppendUsage(usageLog); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}
 }
 
 type GraphVizLogMessage struct {
@@ -25,6 +42,9 @@ type GraphVizLogMessages struct {
 	TokenCount int
 }
 
+// GlobalGraphVizLogThreads lists the chats logged in the DefaultSession.
+//
+// Deprecated: use Session.FillGraphVizGraph.
 var GlobalGraphVizLogThreads []GraphVizLogMessages
 
 func replaceNewLines(s string) string {
@@ -59,7 +79,7 @@ func getMessagesGraphViz(t *Tzap) {
 		TokenCount: c,
 	})
 	count += c
-	GlobalGraphVizLogThreads = append(GlobalGraphVizLogThreads, GraphVizLogMessages{
+	t.session().addGraphVizLogThread(GraphVizLogMessages{
 		Messages:   messages,
 		TokenCount: count,
 	})
@@ -77,9 +97,9 @@ func rgetMessagesGraphViz(t *Tzap) ([]GraphVizLogMessage, int) {
 	if t.Message.Content == "" || t.Message.Role == "" {
 		return messages, count
 	}
-	key, ok := t.Data["memory"].(string)
+	key, ok := MemoryKey.Lookup(t)
 	if ok && key != "" {
-		mV := Mem[key]
+		mV := t.session().memory(key)
 		if mV.Content != "" {
 			c, err := t.TG.CountTokens(t.C, mV.Content)
 			if err != nil {
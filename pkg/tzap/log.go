@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
@@ -29,10 +28,11 @@ func recentStack() string {
 	return r
 }
 
-// Package-level variables for global throttling
+// Package-level variables for global throttling, holding the log buffer of the DefaultSession.
+//
+// Deprecated: Tzaps log to their Session. Use Session.Flush and Session.ResetFlush instead.
 var (
 	LastExecutionTime time.Time
-	globalMutex       sync.Mutex
 	MessageBuffer     []string
 )
 
@@ -41,8 +41,9 @@ func Log(t *Tzap, messages ...interface{}) {
 	if !configuration.EnableLogs {
 		return
 	}
-	globalMutex.Lock()
-	defer globalMutex.Unlock()
+	s := t.session()
+	s.logLock.Lock()
+	defer s.logLock.Unlock()
 
 	now := time.Now()
 
@@ -59,38 +60,48 @@ func Log(t *Tzap, messages ...interface{}) {
 	// if time has passed since last message print without adding.
 
 	// Add the message to the buffer
-	MessageBuffer = append(MessageBuffer, message)
+	*s.messageBuffer = append(*s.messageBuffer, message)
 
 	// If the buffer has more than 15 messages, remove the oldest one
-	if len(MessageBuffer) > 15 {
-		MessageBuffer = MessageBuffer[1:]
+	if len(*s.messageBuffer) > 15 {
+		*s.messageBuffer = (*s.messageBuffer)[1:]
 	}
-	if now.Sub(LastExecutionTime) >= 1*time.Second {
-		LastExecutionTime = now
-		rawFlush()
+	if now.Sub(*s.lastExecutionTime) >= 1*time.Second {
+		*s.lastExecutionTime = now
+		s.rawFlush()
 	}
 
 }
 
-// no locks. Make sure you locked GlobalMutex.
-func rawFlush() {
-	for _, message := range MessageBuffer {
+// no locks. Make sure you locked logLock.
+func (s *Session) rawFlush() {
+	for _, message := range *s.messageBuffer {
 		tl.DeepLogger.Print(message)
 	}
 	// Clear the message buffer
-	MessageBuffer = []string{}
+	*s.messageBuffer = []string{}
+}
+
+// Flush prints the buffered log messages of the session.
+func (s *Session) Flush() {
+	s.logLock.Lock()
+	defer s.logLock.Unlock()
+	*s.lastExecutionTime = time.Now()
+	s.rawFlush()
+}
+
+// ResetFlush prints the buffered log messages and resets the throttling of the session.
+func (s *Session) ResetFlush() {
+	s.logLock.Lock()
+	defer s.logLock.Unlock()
+	*s.lastExecutionTime = time.Time{}
+	s.rawFlush()
 }
 func Flush() {
-	globalMutex.Lock()
-	defer globalMutex.Unlock()
-	LastExecutionTime = time.Now()
-	rawFlush()
+	DefaultSession.Flush()
 }
 func ResetFlush() {
-	globalMutex.Lock()
-	defer globalMutex.Unlock()
-	LastExecutionTime = time.Time{}
-	rawFlush()
+	DefaultSession.ResetFlush()
 }
 func sprintWithSpace(a ...interface{}) string {
 	t := ""
@@ -113,7 +124,7 @@ func GetNames(t *Tzap) []string {
 	}
 
 	if t.Name == "" {
-		panic("Name required!")
+		return append(names, "Unnamed")
 	}
 
 	return append(names, t.Name)
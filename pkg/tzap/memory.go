@@ -2,38 +2,55 @@ package tzap
 
 import "github.com/tzapio/tzap/pkg/types"
 
-// Mem represents a global memory storage for messages.
+// Mem represents a global memory storage for messages, the memories of the DefaultSession.
+//
+// Deprecated: use Session.GetMemory.
 var Mem = map[string]*types.Message{}
 
 // Memory adds a new Tzap tied to a memory key.
 func (t *Tzap) Memory(role, key string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	data := map[string]interface{}{}
 	data["memory"] = key
-	Mem[key] = &types.Message{Role: role, Content: ""}
+	t.session().setMemory(key, &types.Message{Role: role, Content: ""})
 	return t.AddTzap(&Tzap{Name: "memoryTzap", Data: data})
 }
 
 // Memorize stores the current Tzap message content under a given key.
 func (t *Tzap) Memorize(key string) *Tzap {
-	oldMemory := Mem[key]
-	Mem[key] = &types.Message{Role: oldMemory.Role, Content: t.Message.Content}
-	println("Memorized ", Mem[key].Content)
+	if t.err != nil {
+		return t
+	}
+	session := t.session()
+	oldMemory := session.memory(key)
+	session.setMemory(key, &types.Message{Role: oldMemory.Role, Content: t.Message.Content})
+	println("Memorized ", session.GetMemory(key))
 	return t
 }
 
 // MemorizeReq stores the Chat message content under a given key.
 func (t *Tzap) MemorizeReq(key string) *Tzap {
-	oldMemory := Mem[key]
-	Mem[key] = &types.Message{Role: oldMemory.Role, Content: t.RequestChatCompletion().Data["content"].(string)}
-	println("Memorized ", Mem[key].Content)
+	if t.err != nil {
+		return t
+	}
+	requested := t.RequestChatCompletion()
+	if requested.err != nil {
+		return requested
+	}
+	session := t.session()
+	oldMemory := session.memory(key)
+	content, err := ContentKey.Get(requested)
+	if err != nil {
+		return requested.Fail(err)
+	}
+	session.setMemory(key, &types.Message{Role: oldMemory.Role, Content: content})
+	println("Memorized ", session.GetMemory(key))
 	return t
 }
 
-// GetMemory returns the content of a memory key, or an empty string if the key does not exist.
+// GetMemory returns the content of a memory key in the DefaultSession, or an empty string if the key does not exist.
 func GetMemory(key string) string {
-	memory := Mem[key]
-	if memory == nil {
-		return ""
-	}
-	return memory.Content
+	return DefaultSession.GetMemory(key)
 }
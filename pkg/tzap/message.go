@@ -59,6 +59,9 @@ func (t *Tzap) AppendMessage(content string) *Tzap {
 
 // AppendContent appends content to the current message in the Tzap
 func (t *Tzap) AppendContent(sep string, s ...string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	if t.Message.Content == "" {
 		t.Message.Content = strings.Join(s, sep)
 		return t
@@ -69,6 +72,9 @@ func (t *Tzap) AppendContent(sep string, s ...string) *Tzap {
 
 // PrependContent prepends content to the current message in the Tzap
 func (t *Tzap) PrependContent(sep string, s ...string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	if t.Message.Content == "" {
 		t.Message.Content = strings.Join(s, sep)
 		return t
@@ -79,12 +85,18 @@ func (t *Tzap) PrependContent(sep string, s ...string) *Tzap {
 
 // CombineMessage combines two message functions and creates a new message in the Tzap
 func (t *Tzap) CombineMessage(nt1 func(*Tzap) *Tzap, nt2 func(*Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	types1 := nt1(t).Message
 	types2 := nt2(t).Message
 	return t.AddTzap(&Tzap{Name: "CombineMessage", Message: types.Message{Role: types1.Role, Content: fmt.Sprintf("%s\n%s", types1.Content, types2.Content)}})
 }
 
 func (t *Tzap) SetInitialSystemContent(content string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	t.InitialSystemContent = content
 	return t
 }
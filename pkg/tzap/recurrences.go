@@ -5,7 +5,6 @@ package tzap
 
 import (
 	"strings"
-	"sync"
 )
 
 // CheckAndHandleRecurrences counts the number of recurrences of the given filename
@@ -34,32 +33,16 @@ func countMatchingFilepathValues(t *Tzap, filepathValue interface{}) int {
 	return counter
 }
 
-// Package-level variables for global filepath occurrences tracking.
-var (
-	filepathOccurrences *sync.Map = &sync.Map{}
-)
-
-// ResetFilepathOccurrences clears the global filepath occurrences tracking.
+// ResetFilepathOccurrences clears the filepath occurrences tracking of the DefaultSession.
 func ResetFilepathOccurrences() {
-	filepathOccurrences = &sync.Map{}
-}
-
-// countGlobalMatchingFilepathValues counts the global occurrences of the
-// given filepath value.
-func countGlobalMatchingFilepathValues(filepathValue string) int {
-	counter := 0
-	if value, ok := filepathOccurrences.Load(filepathValue); ok {
-		counter = value.(int)
-	}
-	filepathOccurrences.Store(filepathValue, counter+1)
-	return counter
+	DefaultSession.ResetFilepathOccurrences()
 }
 
 // CheckAndHandleGlobalOccurrences checks and handles the global occurrences
 // of the given filename within the data. Calls either noOccurrence or
 // handleOccurrence based on the provided references.
 func (t *Tzap) CheckAndHandleGlobalOccurrences(references int, filename string, noOccurrence, handleOccurrence func(*Tzap) *Tzap) *Tzap {
-	filepathCount := countGlobalMatchingFilepathValues(filename)
+	filepathCount := t.session().countGlobalMatchingFilepathValues(filename)
 	parentLength := TotalLength(t)
 	if parentLength < 2 || strings.Contains(filename, "model") || strings.Contains(filename, "search") {
 		if filepathCount < references {
@@ -73,7 +56,7 @@ func (t *Tzap) CheckAndHandleGlobalOccurrences(references int, filename string,
 // of the provided filename within the data. Calls either noOccurrence or
 // handleOccurrence based on the provided references.
 func (t *Tzap) FileMustContainHandleGlobalOccurrences(references int, filename string, noOccurrence, handleOccurrence func(*Tzap) *Tzap) *Tzap {
-	filepathCount := countGlobalMatchingFilepathValues(filename)
+	filepathCount := t.session().countGlobalMatchingFilepathValues(filename)
 	if filepathCount < references {
 		return noOccurrence(t)
 	}
@@ -1,6 +1,7 @@
 package tzap
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/tzapio/tzap/internal/logging/filelog"
@@ -13,8 +14,14 @@ import (
 )
 
 func (t *Tzap) StoreCompletion(filePath string) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	config := config.FromContext(t.C)
-	editedContent := t.Data["content"].(string)
+	editedContent, err := ContentKey.Get(t)
+	if err != nil {
+		return t.Fail(fmt.Errorf("StoreCompletion: %w", err))
+	}
 
 	autoMode := config.AutoMode
 	makeChange := autoMode
@@ -26,7 +33,7 @@ func (t *Tzap) StoreCompletion(filePath string) *Tzap {
 	if makeChange {
 		err := util.MkdirPAndWriteFile(filePath, editedContent)
 		if err != nil {
-			panic(fmt.Errorf("error applying changes: %w", err))
+			return t.Fail(fmt.Errorf("error applying changes: %w", err))
 		}
 		writeMessageMD5(filePath, t)
 		data := types.MappedInterface{
@@ -43,24 +50,74 @@ func (t *Tzap) StoreCompletion(filePath string) *Tzap {
 	if config.AutoMode || stdin.ConfirmPrompt("Continue on?") {
 		return t
 	}
-	panic("Do not continue selected")
+	return t.Fail(ErrExit)
 }
 
 // RequestChatCompletion initializes the openai chat completion request and creates a new Tzap with the edited content.
+// When tools are registered with WithTool, the tools the model calls are run and their results sent back
+// until the model answers, at most config.Configuration.MaxToolIterations times.
+// The answer is streamed to tl.UICompletionLogger, which is silent unless enabled.
 func (t *Tzap) RequestChatCompletion() *Tzap {
-	output, err := fetchChatResponse(t, true)
-	if err != nil {
-		panic(err)
+	return t.RequestChatCompletionStream(writeCompletionLog)
+}
+
+// RequestChatCompletionStream works like RequestChatCompletion and calls onDelta with each part of the answer as it arrives.
+// An error returned by onDelta cancels the request and fails the chain.
+func (t *Tzap) RequestChatCompletionStream(onDelta func(delta string) error) *Tzap {
+	if t.err != nil {
+		return t
 	}
-	data := types.MappedInterface{
-		"content": output,
+	tools := GetTools(t)
+	maxIterations := config.FromContext(t.C).MaxToolIterations
+	if maxIterations <= 0 {
+		maxIterations = defaultMaxToolIterations
 	}
-	requestChat := t.AddTzap(&Tzap{Name: "requestChat", Data: data})
 
-	return requestChat
+	current := t
+	for i := 0; i <= maxIterations; i++ {
+		response, report, err := fetchChatResponse(current, chatRequest{onDelta: onDelta, tools: tools})
+		if err != nil {
+			return current.Fail(err)
+		}
+		if response.ToolCall == nil {
+			data := types.MappedInterface{
+				"content":                response.Content,
+				string(ContextReportKey): report,
+			}
+			requestChat := current.AddTzap(&Tzap{Name: "requestChat", Data: data})
+
+			return requestChat
+		}
+		if i == maxIterations {
+			break
+		}
+
+		Logf(current, "Tool call (%s) %s", response.ToolCall.Name, response.ToolCall.Arguments)
+		current = current.AddTzap(&Tzap{Name: "toolCall", Message: types.Message{
+			Role:     openai.ChatMessageRoleAssistant,
+			Content:  response.Content,
+			ToolCall: response.ToolCall,
+		}})
+		result, err := callTool(current, tools, response.ToolCall)
+		if err != nil {
+			return current.Fail(err)
+		}
+		current = current.AddTzap(&Tzap{Name: "toolResult", Message: types.Message{
+			Role:    openai.ChatMessageRoleFunction,
+			Name:    response.ToolCall.Name,
+			Content: result,
+		}})
+	}
+	return current.Fail(fmt.Errorf("RequestChatCompletion: no answer after %d tool calls", maxIterations))
 }
 func (t *Tzap) AsAssistantMessage() *Tzap {
-	content := t.Data["content"].(string)
+	if t.err != nil {
+		return t
+	}
+	content, err := ContentKey.Get(t)
+	if err != nil {
+		return t.Fail(fmt.Errorf("AsAssistantMessage: %w", err))
+	}
 	return t.AddAssistantMessage(content)
 }
 
@@ -74,26 +131,80 @@ func (t *Tzap) OffsetTokens(content string, from int, to int) (string, int, erro
 	return t.TG.OffsetTokens(t.C, content, from, to)
 }
 
-// fetchChatResponse requests openai-chat completion for the given Tzap and returns the modified content.
-func fetchChatResponse(t *Tzap, stream bool) (string, error) {
-	config := config.FromContext(t.C)
+func writeCompletionLog(delta string) error {
+	tl.UICompletionLogger.Writer().Write([]byte(delta))
+	return nil
+}
+
+// chatRequest selects how fetchChatResponse asks for a completion.
+type chatRequest struct {
+	// onDelta streams the answer when set.
+	onDelta func(delta string) error
+	// tools are offered to the model, so the response may be a tool call.
+	tools []Tool
+	// schema asks connectors supporting it for JSON matching the schema.
+	schema json.RawMessage
+}
 
-	thread := TruncateToMaxTokens(t.TG, GetThread(t), config.TruncateLimit)
+// fetchChatResponse requests openai-chat completion for the given Tzap and returns the response
+// and what the context strategy removed from the thread.
+func fetchChatResponse(t *Tzap, request chatRequest) (types.ChatResponse, ContextReport, error) {
+	thread, report, err := t.FitContext(GetContextThread(t))
+	if err != nil {
+		return types.ChatResponse{}, report, err
+	}
 
 	filelog.LogData(t.C, t, filelog.TzapLog)
-	GenerateGraphvizDotFile(t, FillGraphVizGraph())
-	filelog.LogData(t.C, thread, filelog.RequestLog)
+	GenerateGraphvizDotFile(t, t.session().FillGraphVizGraph())
 	tl.UILogger.Println("\n--- Completion:")
-	result, err := t.TG.GenerateChat(t.C, thread, stream)
-
+	// Requests and responses are logged by the connector, see tzapconnect.Log.
+	result, err := generateChat(t, thread, request)
 	if err != nil {
-		filelog.LogData(t.C, err.Error(), filelog.ResponseLog)
-		return "", err
+		return types.ChatResponse{}, report, err
 	}
 	tl.UILogger.Println("\n---")
 	getMessagesGraphViz(t)
-	GenerateGraphvizDotFile(t, FillGraphVizGraph())
-	filelog.LogData(t.C, result, filelog.ResponseLog)
+	GenerateGraphvizDotFile(t, t.session().FillGraphVizGraph())
 
-	return result, nil
+	return result, report, nil
+}
+
+func generateChat(t *Tzap, thread []types.Message, request chatRequest) (types.ChatResponse, error) {
+	stream := request.onDelta != nil
+	ctx := t.UsageContext()
+	if len(request.tools) > 0 {
+		toolTG, ok := t.TG.(types.ToolChatGenerator)
+		if !ok {
+			return types.ChatResponse{}, fmt.Errorf("tools are registered but the connector %T does not support tool calling", t.TG)
+		}
+		response, err := toolTG.GenerateChatWithTools(ctx, thread, toolDefinitions(request.tools), stream)
+		if err != nil {
+			return response, err
+		}
+		return response, emitDelta(request.onDelta, response.Content)
+	}
+	if jsonTG, ok := t.TG.(types.JSONChatGenerator); ok && request.schema != nil {
+		content, err := jsonTG.GenerateChatJSON(ctx, thread, request.schema, stream)
+		if err != nil {
+			return types.ChatResponse{}, err
+		}
+		return types.ChatResponse{Content: content}, emitDelta(request.onDelta, content)
+	}
+	if streamTG, ok := t.TG.(types.StreamChatGenerator); ok && stream {
+		content, err := streamTG.GenerateChatStream(ctx, thread, request.onDelta)
+		return types.ChatResponse{Content: content}, err
+	}
+	content, err := t.TG.GenerateChat(ctx, thread, stream)
+	if err != nil {
+		return types.ChatResponse{}, err
+	}
+	return types.ChatResponse{Content: content}, emitDelta(request.onDelta, content)
+}
+
+// emitDelta passes an answer that was not streamed to onDelta in one part.
+func emitDelta(onDelta func(delta string) error, content string) error {
+	if onDelta == nil || content == "" {
+		return nil
+	}
+	return onDelta(content)
 }
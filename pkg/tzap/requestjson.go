@@ -0,0 +1,209 @@
+package tzap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// defaultJSONRetries is how often RequestJSON asks the model to correct an invalid answer when JSONOptions.Retries is 0.
+const defaultJSONRetries = 2
+
+// JSONOptions configures RequestJSON.
+type JSONOptions[T any] struct {
+	// Instruction is put in front of the schema in the system message.
+	Instruction string
+	// Retries is how often the model is asked to correct an answer that does not decode or validate. 0 means 2, below 0 means none.
+	Retries int
+	// Validate checks the decoded value. Its error is sent back to the model.
+	Validate func(T) error
+}
+
+// RequestJSON asks the model for JSON matching the schema of T and decodes the answer into T.
+// The schema is derived from the json and description struct tags of T. Connectors implementing
+// types.JSONChatGenerator are asked for JSON directly. Answers that do not decode, miss required fields
+// or fail opts.Validate are sent back with the error so the model can correct them.
+// The returned Tzap holds the last answer under ContentKey.
+func RequestJSON[T any](t *Tzap, opts JSONOptions[T]) (T, *Tzap, error) {
+	var zero T
+	if t.err != nil {
+		return zero, t, t.err
+	}
+	schema := JSONSchemaOf(reflect.TypeOf((*T)(nil)).Elem())
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return zero, t, fmt.Errorf("RequestJSON: error encoding schema: %w", err)
+	}
+	retries := opts.Retries
+	if retries == 0 {
+		retries = defaultJSONRetries
+	}
+
+	current := t.AddSystemMessage(strings.TrimSpace(opts.Instruction + "\nAnswer only with JSON matching this JSON schema:\n" + string(schemaJSON)))
+	for attempt := 0; ; attempt++ {
+		request := chatRequest{}
+		if schema["type"] == "object" {
+			request.schema = schemaJSON
+		}
+		response, report, err := fetchChatResponse(current, request)
+		if err != nil {
+			return zero, current, fmt.Errorf("RequestJSON: %w", err)
+		}
+		answer := current.AddTzap(&Tzap{Name: "requestJSON", Data: types.MappedInterface{
+			string(ContentKey):       response.Content,
+			string(ContextReportKey): report,
+		}})
+
+		value, err := decodeJSONAnswer(response.Content, schema, opts.Validate)
+		if err == nil {
+			return value, answer, nil
+		}
+		if attempt >= retries {
+			return zero, answer, fmt.Errorf("RequestJSON: invalid answer after %d attempts: %w", attempt+1, err)
+		}
+		Logf(answer, "Invalid JSON answer (%s)", err.Error())
+		current = answer.
+			AddAssistantMessage(response.Content).
+			AddUserMessage("The answer is not valid: " + err.Error() + "\nAnswer again with the corrected JSON only.")
+	}
+}
+
+func decodeJSONAnswer[T any](content string, schema map[string]any, validate func(T) error) (T, error) {
+	var value T
+	raw := extractJSON(content)
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		return value, fmt.Errorf("could not decode JSON: %w", err)
+	}
+	var generic any
+	if err := json.Unmarshal([]byte(raw), &generic); err != nil {
+		return value, fmt.Errorf("could not decode JSON: %w", err)
+	}
+	if err := checkRequired(schema, generic, "$"); err != nil {
+		return value, err
+	}
+	if validate != nil {
+		if err := validate(value); err != nil {
+			return value, err
+		}
+	}
+	return value, nil
+}
+
+// extractJSON strips code fences and any text around the outermost JSON object or array.
+func extractJSON(content string) string {
+	start := strings.IndexAny(content, "{[")
+	if start == -1 {
+		return strings.TrimSpace(content)
+	}
+	closing := "}"
+	if content[start] == '[' {
+		closing = "]"
+	}
+	end := strings.LastIndex(content, closing)
+	if end < start {
+		return content[start:]
+	}
+	return content[start : end+1]
+}
+
+// checkRequired reports the first required property missing from value.
+func checkRequired(schema map[string]any, value any, path string) error {
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an object", path)
+		}
+		required, _ := schema["required"].([]string)
+		for _, name := range required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s: required field %q is missing", path, name)
+			}
+		}
+		properties, _ := schema["properties"].(map[string]any)
+		for name, property := range properties {
+			propertyValue, ok := object[name]
+			if !ok || propertyValue == nil {
+				continue
+			}
+			if err := checkRequired(property.(map[string]any), propertyValue, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case "array":
+		array, ok := value.([]any)
+		if !ok {
+			return fmt.Errorf("%s: expected an array", path)
+		}
+		items, _ := schema["items"].(map[string]any)
+		for i, item := range array {
+			if err := checkRequired(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// JSONSchemaOf returns a JSON schema for values of typ decoded with encoding/json.
+// Struct fields are named by their json tag and described by their description tag.
+// Fields that are neither pointers nor tagged omitempty are required.
+func JSONSchemaOf(typ reflect.Type) map[string]any {
+	return jsonSchemaOf(typ, map[reflect.Type]bool{})
+}
+
+func jsonSchemaOf(typ reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(typ.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(typ.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[typ] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[typ] = true
+		defer delete(visiting, typ)
+
+		properties := map[string]any{}
+		required := []string{}
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := jsonSchemaOf(field.Type, visiting)
+			if description := field.Tag.Get("description"); description != "" {
+				property["description"] = description
+			}
+			properties[name] = property
+			if field.Type.Kind() != reflect.Pointer && !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": properties, "required": required}
+	}
+	return map[string]any{}
+}
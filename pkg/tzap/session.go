@@ -0,0 +1,158 @@
+package tzap
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Session owns the mutable state shared by a tree of Tzaps: ids, the list of created Tzaps,
+// memories, filepath occurrences, the log buffer, the graphviz chat log and the usage of model calls.
+// Children inherit the Session of their parent. Chains started with NewWithConnector share the DefaultSession,
+// chains started with Session.NewWithConnector of a NewSession do not interfere with other chains in the process.
+type Session struct {
+	lock sync.Mutex
+
+	count int
+	// tzaps lists every Tzap created in the session, in creation order.
+	tzaps *[]*Tzap
+	// Mem holds the messages stored with Memory and Memorize.
+	Mem                 map[string]*types.Message
+	filepathOccurrences *sync.Map
+
+	logLock           sync.Mutex
+	messageBuffer     *[]string
+	lastExecutionTime *time.Time
+
+	graphVizLogThreads *[]GraphVizLogMessages
+
+	usage         []UsageRecord
+	usageAppended int
+}
+
+// DefaultSession is used by Tzaps without a session of their own, such as those created by NewWithConnector
+// and InternalNew, and by the package-level functions. It keeps its state in the deprecated package variables.
+var DefaultSession = &Session{
+	count:               1,
+	tzaps:               &GlobalTzaps,
+	Mem:                 Mem,
+	filepathOccurrences: &sync.Map{},
+	messageBuffer:       &MessageBuffer,
+	lastExecutionTime:   &LastExecutionTime,
+	graphVizLogThreads:  &GlobalGraphVizLogThreads,
+}
+
+// NewSession returns an empty Session.
+func NewSession() *Session {
+	return &Session{
+		count:               1,
+		tzaps:               &[]*Tzap{},
+		Mem:                 map[string]*types.Message{},
+		filepathOccurrences: &sync.Map{},
+		messageBuffer:       &[]string{},
+		lastExecutionTime:   &time.Time{},
+		graphVizLogThreads:  &[]GraphVizLogMessages{},
+	}
+}
+
+// NewWithConnector works like the package-level NewWithConnector but starts the chain in the session.
+func (s *Session) NewWithConnector(connector types.TzapConnector) *Tzap {
+	tg, conf := connector()
+	t := &Tzap{
+		Name:    "Connection",
+		Message: types.Message{},
+		Data:    types.MappedInterface{},
+		C:       config.NewContext(context.Background(), conf),
+		TG:      tg,
+
+		Session: s,
+	}
+	s.addId(t)
+	return t
+}
+
+// session returns the Session of the Tzap, falling back to DefaultSession.
+func (t *Tzap) session() *Session {
+	if t.Session == nil {
+		return DefaultSession
+	}
+	return t.Session
+}
+
+func (s *Session) addId(t *Tzap) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if t.Id > 0 {
+		println("Tzap already has an id", t.Id, t.Name)
+		panic(t)
+	}
+	t.Id = s.count
+	s.count += 1
+
+	*s.tzaps = append(*s.tzaps, t)
+}
+
+// Tzaps returns every Tzap created in the session, in creation order. The copy is safe to iterate while other
+// goroutines add tzaps.
+func (s *Session) Tzaps() []*Tzap {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*Tzap(nil), *s.tzaps...)
+}
+
+func (s *Session) setMemory(key string, message *types.Message) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.Mem[key] = message
+}
+
+func (s *Session) memory(key string) *types.Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.Mem[key]
+}
+
+// GetMemory returns the content of a memory key, or an empty string if the key does not exist.
+func (s *Session) GetMemory(key string) string {
+	memory := s.memory(key)
+	if memory == nil {
+		return ""
+	}
+	return memory.Content
+}
+
+// ResetFilepathOccurrences clears the filepath occurrences tracking of the session.
+func (s *Session) ResetFilepathOccurrences() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.filepathOccurrences = &sync.Map{}
+}
+
+// countGlobalMatchingFilepathValues counts the occurrences of the
+// given filepath value within the session.
+func (s *Session) countGlobalMatchingFilepathValues(filepathValue string) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	counter := 0
+	if value, ok := s.filepathOccurrences.Load(filepathValue); ok {
+		counter = value.(int)
+	}
+	s.filepathOccurrences.Store(filepathValue, counter+1)
+	return counter
+}
+
+func (s *Session) addGraphVizLogThread(thread GraphVizLogMessages) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	*s.graphVizLogThreads = append(*s.graphVizLogThreads, thread)
+}
+
+// graphVizLogThreadsSnapshot returns a copy of GraphVizLogThreads that is safe to iterate concurrently.
+func (s *Session) graphVizLogThreadsSnapshot() []GraphVizLogMessages {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]GraphVizLogMessages(nil), *s.graphVizLogThreads...)
+}
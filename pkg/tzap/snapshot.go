@@ -0,0 +1,336 @@
+package tzap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// SnapshotVersion is the version written to snapshot files. Restore rejects files of other versions.
+const SnapshotVersion = 1
+
+// snapshotFile is the JSON layout of a snapshot.
+type snapshotFile struct {
+	Version int                      `json:"version"`
+	Head    int                      `json:"head"`
+	Nodes   []snapshotNode           `json:"nodes"`
+	Memory  map[string]types.Message `json:"memory,omitempty"`
+}
+
+type snapshotNode struct {
+	Id                   int                      `json:"id"`
+	Parent               int                      `json:"parent,omitempty"`
+	Name                 string                   `json:"name"`
+	InitialSystemContent string                   `json:"initialSystemContent,omitempty"`
+	Message              types.Message            `json:"message"`
+	Data                 map[string]snapshotValue `json:"data,omitempty"`
+	// Skipped maps the Data keys left out of the snapshot to the type of their value.
+	Skipped   map[string]string `json:"skipped,omitempty"`
+	ErrorMode bool              `json:"errorMode,omitempty"`
+}
+
+// snapshotValue is an encoded Data value. Tzaps stored in Data, such as Map children, are kept as references to nodes.
+type snapshotValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+	Refs  []int           `json:"refs,omitempty"`
+}
+
+const (
+	snapshotTzapType  = "tzap"
+	snapshotTzapsType = "[]tzap"
+)
+
+type snapshotCodec struct {
+	name   string
+	encode func(any) (json.RawMessage, error)
+	decode func(json.RawMessage) (any, error)
+}
+
+var (
+	snapshotTypesLock   sync.RWMutex
+	snapshotTypesByName = map[string]snapshotCodec{}
+	snapshotTypesByType = map[reflect.Type]snapshotCodec{}
+)
+
+func init() {
+	RegisterSnapshotType[string]("string")
+	RegisterSnapshotType[int]("int")
+	RegisterSnapshotType[float64]("float64")
+	RegisterSnapshotType[bool]("bool")
+	RegisterSnapshotType[[]string]("[]string")
+	RegisterSnapshotType[types.Message]("message")
+	RegisterSnapshotType[[]types.Message]("[]message")
+	RegisterSnapshotType[float32]("float32")
+	RegisterSnapshotType[ContextReport]("contextReport")
+}
+
+// RegisterSnapshotType registers a Data value type that Snapshot may encode as JSON under name.
+// Snapshot leaves out Data values of unregistered types, such as connections to databases, so packages storing
+// their own serializable types in Data register them in init.
+func RegisterSnapshotType[T any](name string) {
+	snapshotTypesLock.Lock()
+	defer snapshotTypesLock.Unlock()
+	if name == snapshotTzapType || name == snapshotTzapsType {
+		panic(fmt.Sprintf("RegisterSnapshotType: %q is reserved", name))
+	}
+	if _, ok := snapshotTypesByName[name]; ok {
+		panic(fmt.Sprintf("RegisterSnapshotType: %q is already registered", name))
+	}
+	codec := snapshotCodec{
+		name: name,
+		encode: func(v any) (json.RawMessage, error) {
+			return json.Marshal(v)
+		},
+		decode: func(raw json.RawMessage) (any, error) {
+			var v T
+			if err := json.Unmarshal(raw, &v); err != nil {
+				return nil, err
+			}
+			return v, nil
+		},
+	}
+	snapshotTypesByName[name] = codec
+	snapshotTypesByType[reflect.TypeOf((*T)(nil)).Elem()] = codec
+}
+
+// Snapshot writes the tree reachable from t to filePath as versioned JSON: every ancestor of t and every Tzap
+// referenced from Data, such as Map children, together with the memories of the session. Data values of
+// unregistered types are left out and listed under the "skipped" key of their node.
+// Restore the file with Restore to continue the chain without repeating the completions that built it.
+func Snapshot(t *Tzap, filePath string) error {
+	data, err := MarshalSnapshot(t)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("Snapshot: error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// MarshalSnapshot returns the snapshot that Snapshot writes.
+func MarshalSnapshot(t *Tzap) ([]byte, error) {
+	// Tzaps are told apart by pointer, as the ids of a tree mixing sessions may collide.
+	visited := map[*Tzap]bool{}
+	reachable := []*Tzap{}
+	var visit func(*Tzap)
+	visit = func(tz *Tzap) {
+		for ; tz != nil; tz = tz.Parent {
+			if visited[tz] {
+				return
+			}
+			visited[tz] = true
+			reachable = append(reachable, tz)
+			for _, value := range tz.Data {
+				for _, ref := range dataTzaps(value) {
+					visit(ref)
+				}
+			}
+		}
+	}
+	visit(t)
+	sort.SliceStable(reachable, func(i, j int) bool { return reachable[i].Id < reachable[j].Id })
+	ids := make(map[*Tzap]int, len(reachable))
+	for i, tz := range reachable {
+		ids[tz] = i + 1
+	}
+
+	file := snapshotFile{Version: SnapshotVersion, Head: ids[t]}
+	for _, tz := range reachable {
+		node, err := snapshotTzap(tz, ids)
+		if err != nil {
+			return nil, err
+		}
+		file.Nodes = append(file.Nodes, node)
+	}
+
+	session := t.session()
+	session.lock.Lock()
+	if len(session.Mem) > 0 {
+		file.Memory = map[string]types.Message{}
+		for key, message := range session.Mem {
+			file.Memory[key] = *message
+		}
+	}
+	session.lock.Unlock()
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("Snapshot: error encoding snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// dataTzaps returns the Tzaps held by a Data value.
+func dataTzaps(value any) []*Tzap {
+	switch v := value.(type) {
+	case *Tzap:
+		return []*Tzap{v}
+	case []*Tzap:
+		return v
+	}
+	return nil
+}
+
+// snapshotTzap encodes t, whose node and the nodes of the Tzaps it refers to get the snapshot ids of ids.
+func snapshotTzap(t *Tzap, ids map[*Tzap]int) (snapshotNode, error) {
+	node := snapshotNode{
+		Id:                   ids[t],
+		Name:                 t.Name,
+		InitialSystemContent: t.InitialSystemContent,
+		Message:              t.Message,
+		ErrorMode:            t.errorMode,
+	}
+	if t.Parent != nil {
+		node.Parent = ids[t.Parent]
+	}
+	for key, value := range t.Data {
+		encoded, ok, err := snapshotData(value, ids)
+		if err != nil {
+			return node, fmt.Errorf("Snapshot: tzap %s (%d): data key %q: %w", t.Name, t.Id, key, err)
+		}
+		if !ok {
+			if node.Skipped == nil {
+				node.Skipped = map[string]string{}
+			}
+			node.Skipped[key] = fmt.Sprintf("%T", value)
+			continue
+		}
+		if node.Data == nil {
+			node.Data = map[string]snapshotValue{}
+		}
+		node.Data[key] = encoded
+	}
+	return node, nil
+}
+
+// snapshotData encodes value. It reports false for values of unregistered types.
+func snapshotData(value any, ids map[*Tzap]int) (snapshotValue, bool, error) {
+	switch v := value.(type) {
+	case *Tzap:
+		return snapshotValue{Type: snapshotTzapType, Refs: []int{ids[v]}}, true, nil
+	case []*Tzap:
+		refs := make([]int, len(v))
+		for i, tz := range v {
+			refs[i] = ids[tz]
+		}
+		return snapshotValue{Type: snapshotTzapsType, Refs: refs}, true, nil
+	}
+	snapshotTypesLock.RLock()
+	codec, ok := snapshotTypesByType[reflect.TypeOf(value)]
+	snapshotTypesLock.RUnlock()
+	if !ok {
+		return snapshotValue{}, false, nil
+	}
+	raw, err := codec.encode(value)
+	if err != nil {
+		return snapshotValue{}, false, err
+	}
+	return snapshotValue{Type: codec.name, Value: raw}, true, nil
+}
+
+// Restore rebuilds the tree written by Snapshot in a new session on connector and returns the Tzap Snapshot was called with.
+// Restored Tzaps get new ids in the same order as the original ids. Context changes other than the connector configuration are not restored.
+func Restore(filePath string, connector types.TzapConnector) (*Tzap, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Restore: error reading snapshot: %w", err)
+	}
+	return UnmarshalSnapshot(data, connector)
+}
+
+// UnmarshalSnapshot restores a snapshot returned by MarshalSnapshot. See Restore.
+func UnmarshalSnapshot(data []byte, connector types.TzapConnector) (*Tzap, error) {
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("Restore: error decoding snapshot: %w", err)
+	}
+	if file.Version != SnapshotVersion {
+		return nil, fmt.Errorf("Restore: unsupported snapshot version %d, expected %d", file.Version, SnapshotVersion)
+	}
+
+	tg, conf := connector()
+	c := config.NewContext(context.Background(), conf)
+	session := NewSession()
+	for key, message := range file.Memory {
+		message := message
+		session.Mem[key] = &message
+	}
+
+	sort.Slice(file.Nodes, func(i, j int) bool { return file.Nodes[i].Id < file.Nodes[j].Id })
+	restored := map[int]*Tzap{}
+	for _, node := range file.Nodes {
+		t := &Tzap{
+			Name:                 node.Name,
+			InitialSystemContent: node.InitialSystemContent,
+			Message:              node.Message,
+			Data:                 types.MappedInterface{},
+			C:                    c,
+			TG:                   tg,
+			Session:              session,
+			errorMode:            node.ErrorMode,
+		}
+		session.addId(t)
+		restored[node.Id] = t
+	}
+
+	for _, node := range file.Nodes {
+		t := restored[node.Id]
+		if node.Parent != 0 {
+			parent, ok := restored[node.Parent]
+			if !ok {
+				return nil, fmt.Errorf("Restore: tzap %s (%d): parent %d is missing", node.Name, node.Id, node.Parent)
+			}
+			t.Parent = parent
+		}
+		for key, value := range node.Data {
+			decoded, err := restoreData(value, restored)
+			if err != nil {
+				return nil, fmt.Errorf("Restore: tzap %s (%d): data key %q: %w", node.Name, node.Id, key, err)
+			}
+			t.Data[key] = decoded
+		}
+	}
+
+	head, ok := restored[file.Head]
+	if !ok {
+		return nil, fmt.Errorf("Restore: head tzap %d is missing", file.Head)
+	}
+	return head, nil
+}
+
+func restoreData(value snapshotValue, restored map[int]*Tzap) (any, error) {
+	switch value.Type {
+	case snapshotTzapType, snapshotTzapsType:
+		tzaps := make([]*Tzap, len(value.Refs))
+		for i, ref := range value.Refs {
+			t, ok := restored[ref]
+			if !ok {
+				return nil, fmt.Errorf("referenced tzap %d is missing", ref)
+			}
+			tzaps[i] = t
+		}
+		if value.Type == snapshotTzapType {
+			if len(tzaps) != 1 {
+				return nil, fmt.Errorf("expected one tzap reference, got %d", len(tzaps))
+			}
+			return tzaps[0], nil
+		}
+		return tzaps, nil
+	}
+	snapshotTypesLock.RLock()
+	codec, ok := snapshotTypesByName[value.Type]
+	snapshotTypesLock.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("type %q is not registered, see RegisterSnapshotType", value.Type)
+	}
+	return codec.decode(value.Value)
+}
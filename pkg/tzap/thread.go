@@ -12,37 +12,51 @@ import (
 )
 
 func GetThread(t *Tzap) []types.Message {
-	messages := getThread(t)
+	return plainMessages(GetContextThread(t))
+}
+
+// GetContextThread returns the thread of t like GetThread, with the embedding search scores stored under RelevanceKey.
+func GetContextThread(t *Tzap) []ContextMessage {
+	messages := getContextThread(t)
 	if t.InitialSystemContent != "" {
-		messages = append([]types.Message{{
+		messages = append([]ContextMessage{{Message: types.Message{
 			Role:    "system",
 			Content: t.InitialSystemContent,
-		}}, messages...)
+		}}}, messages...)
 	}
 	return messages
 }
-func getThread(t *Tzap) []types.Message {
-	var messages []types.Message
+func getContextThread(t *Tzap) []ContextMessage {
+	var messages []ContextMessage
 
 	if t.Parent != nil {
-		messages = GetThread(t.Parent)
+		messages = GetContextThread(t.Parent)
 	}
 
-	if t.Message.Content == "" || t.Message.Role == "" {
+	if !hasThreadMessage(t.Message) {
 		return messages
 	}
-	key, ok := t.Data["memory"].(string)
+	key, ok := MemoryKey.Lookup(t)
 	if ok && key != "" {
-		mV := Mem[key]
+		mV := t.session().memory(key)
 		if mV.Content != "" {
 			message := types.Message{
 				Role:    mV.Role,
 				Content: mV.Content,
 			}
-			messages = append(messages, message)
+			messages = append(messages, ContextMessage{Message: message})
 		}
 	}
-	return append(messages, t.Message)
+	relevance, embedded := RelevanceKey.Lookup(t)
+	return append(messages, ContextMessage{Message: t.Message, Embedded: embedded, Relevance: relevance})
+}
+
+// hasThreadMessage reports whether message belongs in the thread. Tool calls and tool results may have no content.
+func hasThreadMessage(message types.Message) bool {
+	if message.Role == "" {
+		return false
+	}
+	return message.Content != "" || message.ToolCall != nil || message.Role == openai.ChatMessageRoleFunction
 }
 func (t *Tzap) GetThread() []types.Message {
 	messages := GetThread(t)
@@ -60,7 +74,7 @@ func (t *Tzap) StoreThread(filePath string) *ErrorTzap {
 	messages := GetThread(t)
 	jsonBytes, err := json.Marshal(messages)
 	if err != nil {
-		panic(fmt.Errorf("error storing thread: %w", err))
+		return t.ErrorTzap(fmt.Errorf("StoreThread: error storing thread: %w", err))
 	}
 
 	if err := os.WriteFile(filePath, jsonBytes, 0644); err != nil {
@@ -82,12 +96,15 @@ func (t *Tzap) LoadThreadFile(filePath string) *ErrorTzap {
 }
 
 func (t *Tzap) LoadThread(messages []types.Message) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	for _, message := range messages {
 		if message.Role == openai.ChatMessageRoleSystem {
 			t = t.AddSystemMessage(message.Content)
 			continue
 		}
-		if message.Role == openai.ChatMessageRoleAssistant {
+		if message.Role == openai.ChatMessageRoleAssistant && message.ToolCall == nil {
 			t = t.AddAssistantMessage(message.Content)
 			continue
 		}
@@ -95,10 +112,18 @@ func (t *Tzap) LoadThread(messages []types.Message) *Tzap {
 			t = t.AddUserMessage(message.Content)
 			continue
 		}
+		if message.Role == openai.ChatMessageRoleFunction || message.ToolCall != nil {
+			t = t.AddTzap(&Tzap{Name: "toolMessage", Message: message})
+			continue
+		}
 	}
 	return t
 }
 
+// TruncateToMaxTokens keeps the newest messages that fit into wordLimit tokens.
+//
+// Deprecated: TruncateToMaxTokens drops the system prompt first and panics on errors. Use FitContextTokens, which
+// applies the configured ContextStrategy and returns errors.
 func TruncateToMaxTokens(tg types.TGenerator, messages []types.Message, wordLimit int) []types.Message {
 	var result []types.Message
 	tokenCount := 0
@@ -0,0 +1,79 @@
+package tzap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// defaultMaxToolIterations bounds the tool loop of RequestChatCompletion when config.Configuration.MaxToolIterations is not set.
+const defaultMaxToolIterations = 10
+
+// ToolHandler runs a tool with the JSON arguments chosen by the model and returns the result sent back to the model.
+type ToolHandler func(ctx context.Context, arguments string) (string, error)
+
+// Tool is a Go function the model may call during RequestChatCompletion.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments.
+	Parameters json.RawMessage
+	Handler    ToolHandler
+}
+
+// WithTool registers handler as a tool named name, whose arguments are described by jsonSchema.
+// RequestChatCompletion on the returned Tzap or its children offers the tool to the model.
+func (t *Tzap) WithTool(name, jsonSchema string, handler ToolHandler) *Tzap {
+	return t.AddTool(Tool{Name: name, Parameters: json.RawMessage(jsonSchema), Handler: handler})
+}
+
+// AddTool registers tool like WithTool, with a description for the model.
+func (t *Tzap) AddTool(tool Tool) *Tzap {
+	if t.err != nil {
+		return t
+	}
+	if !json.Valid(tool.Parameters) {
+		return t.Fail(fmt.Errorf("WithTool: tool %s: parameters are not a valid JSON schema", tool.Name))
+	}
+	toolTzap := t.AddTzap(&Tzap{Name: "WithTool"})
+	toolTzap.tool = &tool
+	return toolTzap
+}
+
+// GetTools returns the tools registered on t and its parents. A tool overrides parent tools with the same name.
+func GetTools(t *Tzap) []Tool {
+	var tools []Tool
+	seen := map[string]bool{}
+	for current := t; current != nil; current = current.Parent {
+		if current.tool == nil || seen[current.tool.Name] {
+			continue
+		}
+		seen[current.tool.Name] = true
+		tools = append([]Tool{*current.tool}, tools...)
+	}
+	return tools
+}
+
+func toolDefinitions(tools []Tool) []types.ToolDefinition {
+	definitions := make([]types.ToolDefinition, len(tools))
+	for i, tool := range tools {
+		definitions[i] = types.ToolDefinition{Name: tool.Name, Description: tool.Description, Parameters: tool.Parameters}
+	}
+	return definitions
+}
+
+// callTool runs the tool requested by the model. Unknown tools are reported back to the model so it can correct itself.
+func callTool(t *Tzap, tools []Tool, call *types.ToolCall) (string, error) {
+	for _, tool := range tools {
+		if tool.Name == call.Name {
+			result, err := tool.Handler(t.C, call.Arguments)
+			if err != nil {
+				return "", fmt.Errorf("tool %s: %w", call.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return fmt.Sprintf("error: there is no tool named %q", call.Name), nil
+}
@@ -3,23 +3,9 @@ package tzap
 import (
 	"context"
 
-	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/types"
 )
 
-var count = 1
-
-func addId(t *Tzap) {
-	if t.Id > 0 {
-		println("Tzap already has an id", t.Id, t.Name)
-		panic(t)
-	}
-	t.Id = count
-	count += 1
-
-	GlobalTzaps = append(GlobalTzaps, t)
-}
-
 // Tzap is a structure that holds data and methods related to Tzap objects.
 type Tzap struct {
 	Id                   int
@@ -33,6 +19,16 @@ type Tzap struct {
 	types.ITzap[*Tzap, any] `json:"-"`
 
 	Parent *Tzap
+	// Session owns the ids, memories and logs shared with the rest of the tree. Children inherit it.
+	Session *Session `json:"-"`
+
+	// err is the sticky error of an error-carrying chain. See WithErrorMode.
+	err       error
+	errorMode bool
+	// tool is the tool registered by WithTool. See GetTools.
+	tool *Tool
+	// workflow is the name of the innermost ApplyWorkflow. Children inherit it. See UsageRecord.
+	workflow string
 }
 
 // NewTzap creates a new Tzap with default values, and returns its pointer.
@@ -44,21 +40,14 @@ func InternalNew() *Tzap {
 		Data:    types.MappedInterface{},
 		C:       context.Background(),
 	}
-	addId(t)
+	t.session().addId(t)
 	return t
 }
 
+// NewWithConnector starts a chain in the DefaultSession. Use Session.NewWithConnector of a NewSession for chains
+// that must not share ids, memories and logs with other chains of the process.
 func NewWithConnector(connector types.TzapConnector) *Tzap {
-	tg, conf := connector()
-	t := &Tzap{
-		Name:    "Connection",
-		Message: types.Message{},
-		Data:    types.MappedInterface{},
-		C:       config.NewContext(context.Background(), conf),
-		TG:      tg,
-	}
-	addId(t)
-	return t
+	return DefaultSession.NewWithConnector(connector)
 }
 
 // CopyConnection returns a new Tzap with default values.
@@ -69,8 +58,11 @@ func (t *Tzap) CopyConnection() *Tzap {
 		Data:    types.MappedInterface{},
 		C:       t.C,
 		TG:      t.TG,
+
+		Session:   t.Session,
+		errorMode: t.errorMode,
 	}
-	addId(tc)
+	tc.session().addId(tc)
 	return tc
 }
 
@@ -79,18 +71,25 @@ func (t *Tzap) appendParentContext() *Tzap {
 	if t.Parent != nil {
 		t.C = t.Parent.C
 		t.TG = t.Parent.TG
+		t.Session = t.Parent.Session
+		t.errorMode = t.Parent.errorMode
+		t.workflow = t.Parent.workflow
 	}
 	return t
 }
 
 // onNewTzap is a helper method that appends the parent's context to the Tzap object.
 func (t *Tzap) onNewTzap() *Tzap {
-	addId(t)
-	return t.appendParentContext()
+	t.appendParentContext()
+	t.session().addId(t)
+	return t
 }
 
 // AddContextChange replaces the current Tzap's context with the provided context.
 func (t *Tzap) AddContextChange(fn func(context.Context) context.Context) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	newTzap := t.AddTzap(&Tzap{Name: "MutateContext"})
 	newTzap.C = fn(newTzap.C)
 	return newTzap
@@ -98,6 +97,9 @@ func (t *Tzap) AddContextChange(fn func(context.Context) context.Context) *Tzap
 
 // AddTzap (mostly internal use) initializes and adds a new Tzap child to the current Tzap object.
 func (t *Tzap) AddTzap(tc *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Logf(t, "Add tzap (%s)", tc.Name)
 	tc.Parent = t
 	return tc.onNewTzap()
@@ -105,6 +107,9 @@ func (t *Tzap) AddTzap(tc *Tzap) *Tzap {
 
 // CloneTzap (mostly internal use) clones a Tzap object and assigns values based on the provided Tzap object.
 func (previousTzap *Tzap) CloneTzap(suggestedTzap *Tzap) *Tzap {
+	if previousTzap.err != nil {
+		return previousTzap
+	}
 	Logf(previousTzap, "Clone tzap (%s)", suggestedTzap.Name)
 	baseTzap := &Tzap{
 		Parent:               previousTzap,
@@ -139,7 +144,11 @@ func (previousTzap *Tzap) CloneTzap(suggestedTzap *Tzap) *Tzap {
 // HijackTzap (mostly internal use) effectively de-attaches from previous Tzap by changing the own parent to parents parent.
 // This can be used AddUserMessage("H").LoadTaskOrRequestNewTask().Hijack() .() Tzap replaces the current Tzap's context and parent with the provided Tzap's context and parent.
 func (previousTzap *Tzap) HijackTzap(bypassWith *Tzap) *Tzap {
+	if previousTzap.err != nil {
+		return previousTzap
+	}
 	Logf(previousTzap, "Hijack tzap (%s)", bypassWith.Name)
 	bypassWith.Parent = previousTzap.Parent
+	bypassWith.Session = previousTzap.Session
 	return bypassWith.onNewTzap()
 }
@@ -0,0 +1,154 @@
+package tzap
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// UsageRecord is the usage of one model call together with the Tzap and the workflow that issued it.
+type UsageRecord struct {
+	types.Usage
+	TzapId int `json:"tzapId"`
+	// Workflow is the name of the innermost workflow the call was made in, empty outside workflows. Workflows applied
+	// with ApplyWorkflowFN are named after their function.
+	Workflow string    `json:"workflow,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// UsageTotal sums the usage of several calls.
+type UsageTotal struct {
+	Calls            int
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64
+	// Estimated is set when any of the calls was estimated.
+	Estimated bool
+}
+
+func (u *UsageTotal) add(usage types.Usage) {
+	u.Calls++
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.Cost += usage.Cost
+	u.Estimated = u.Estimated || usage.Estimated
+}
+
+func (u UsageTotal) String() string {
+	approximately := ""
+	if u.Estimated {
+		approximately = "~"
+	}
+	return fmt.Sprintf("%d calls, %s%d prompt + %s%d completion tokens, $%.4f",
+		u.Calls, approximately, u.PromptTokens, approximately, u.CompletionTokens, u.Cost)
+}
+
+// UsageContext returns the context of t that records the usage reported by connectors against t.
+// Pass it instead of t.C to TGenerator calls that should be accounted.
+func (t *Tzap) UsageContext() context.Context {
+	session := t.session()
+	id := t.Id
+	workflow := t.workflow
+	return types.WithUsageReporter(t.C, func(usage types.Usage) {
+		session.addUsage(UsageRecord{Usage: usage, TzapId: id, Workflow: workflow, Time: time.Now()})
+	})
+}
+
+// Usage returns the usage of the calls issued by t.
+func (t *Tzap) Usage() []types.Usage {
+	var usage []types.Usage
+	for _, record := range t.session().UsageRecords() {
+		if record.TzapId == t.Id {
+			usage = append(usage, record.Usage)
+		}
+	}
+	return usage
+}
+
+func (s *Session) addUsage(record UsageRecord) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.usage = append(s.usage, record)
+}
+
+// UsageRecords returns the usage of every call in the session, in the order the calls finished.
+func (s *Session) UsageRecords() []UsageRecord {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]UsageRecord(nil), s.usage...)
+}
+
+// UsageByWorkflow sums the usage of the session per workflow name. Calls outside workflows are under "".
+func (s *Session) UsageByWorkflow() map[string]UsageTotal {
+	totals := map[string]UsageTotal{}
+	for _, record := range s.UsageRecords() {
+		total := totals[record.Workflow]
+		total.add(record.Usage)
+		totals[record.Workflow] = total
+	}
+	return totals
+}
+
+// UsageSummary describes the total usage of the session and the usage per workflow. It is empty when no call reported usage.
+func (s *Session) UsageSummary() string {
+	byWorkflow := s.UsageByWorkflow()
+	if len(byWorkflow) == 0 {
+		return ""
+	}
+	var total UsageTotal
+	workflows := []string{}
+	for workflow, workflowTotal := range byWorkflow {
+		workflows = append(workflows, workflow)
+		total.Calls += workflowTotal.Calls
+		total.PromptTokens += workflowTotal.PromptTokens
+		total.CompletionTokens += workflowTotal.CompletionTokens
+		total.Cost += workflowTotal.Cost
+		total.Estimated = total.Estimated || workflowTotal.Estimated
+	}
+	sort.Strings(workflows)
+
+	var summary strings.Builder
+	summary.WriteString("Usage: " + total.String() + "\n")
+	for _, workflow := range workflows {
+		name := workflow
+		if name == "" {
+			name = "(no workflow)"
+		}
+		summary.WriteString("  " + name + ": " + byWorkflow[workflow].String() + "\n")
+	}
+	return summary.String()
+}
+
+// AppendUsage appends the usage records not appended before to filePath as JSON lines.
+func (s *Session) AppendUsage(filePath string) error {
+	s.lock.Lock()
+	records := s.usage[s.usageAppended:]
+	s.usageAppended = len(s.usage)
+	s.lock.Unlock()
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("error writing usage: %w", err)
+	}
+	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing usage: %w", err)
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("error writing usage: %w", err)
+		}
+	}
+	return nil
+}
@@ -1,10 +1,21 @@
 package tzap
 
-import "github.com/tzapio/tzap/pkg/types"
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/types"
+)
 
 // ApplyWorkflowP applies a given workflow Tzap instance to the current Tzap instance.
 // Returns the applied workflow with its Parent set to the current Tzap instance.
 func (t *Tzap) ApplyWorkflowP(workflow *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	at := t.CloneTzap(&Tzap{Name: "ApplyWorkflowS"})
 	Log(t, "Applying workflow")
 	workflow.Parent = at
@@ -13,25 +24,86 @@ func (t *Tzap) ApplyWorkflowP(workflow *Tzap) *Tzap {
 
 // ApplyWorkflowFN applies a function that takes a Tzap instance and returns a modified Tzap instance.
 // Returns the result of the given function applied to the current Tzap instance.
+// Usage of the function is attributed to the name of the function, such as "codegeneration.MakeCode".
 func (t *Tzap) ApplyWorkflowFN(nt func(*Tzap) *Tzap) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Applying workflow FN")
-	return nt(t.CloneTzap(&Tzap{Name: "ApplyWorkflow"}))
+	name := workflowFuncName(nt)
+	start := t.CloneTzap(&Tzap{Name: "ApplyWorkflow"})
+	start.workflow = name
+	workflowResult := start.recoverFail(func() *Tzap {
+		return nt(start)
+	})
+	t.endWorkflow(workflowResult, name)
+	return workflowResult
 }
 
 // WARNING: ApplyWorkflow clones messages from previous Tzap instances. This duplicates the message.
 func (t *Tzap) ApplyWorkflow(nt types.NamedWorkflow[*Tzap, *Tzap]) *Tzap {
+	if t.err != nil {
+		return t
+	}
 	Log(t, "Applying workflow")
-	workflowResult := nt.Workflow(t.CloneTzap(&Tzap{Name: "ApplyWorkflow (" + nt.Name + ") Start"}))
+	start := t.CloneTzap(&Tzap{Name: "ApplyWorkflow (" + nt.Name + ") Start"})
+	start.workflow = nt.Name
+	workflowResult := start.recoverFail(func() *Tzap {
+		return nt.Workflow(start)
+	})
 	endWorkflow := workflowResult.CloneTzap(&Tzap{Name: "ApplyWorkflow (" + nt.Name + ") End"})
+	if endWorkflow.err == nil {
+		endWorkflow.workflow = t.workflow
+	}
 	return endWorkflow
 }
 
+// ApplyErrorWorkflow applies a workflow returning an ErrorTzap and lets fn decide whether its error is fatal.
+// A fatal error, a sticky error of the returned Tzap or a panic of the workflow or fn panics, or in error mode
+// becomes the sticky error of the chain.
 func (t *Tzap) ApplyErrorWorkflow(nt types.NamedWorkflow[*Tzap, *ErrorTzap], fn func(*ErrorTzap) error) *Tzap {
-	et := nt.Workflow(t.CloneTzap(&Tzap{Name: "ApplyErrorWorkflow (" + nt.Name + ")"}))
-	err := fn(et)
-	if err != nil {
-		panic(err)
+	if t.err != nil {
+		return t
 	}
+	start := t.CloneTzap(&Tzap{Name: "ApplyErrorWorkflow (" + nt.Name + ")"})
+	start.workflow = nt.Name
+	workflowResult := start.recoverFail(func() *Tzap {
+		et := nt.Workflow(start)
+		if et == nil || et.Tzap == nil {
+			return start.Fail(fmt.Errorf("error workflow %s returned no tzap", nt.Name))
+		}
+		if et.Tzap.err != nil {
+			return et.Tzap
+		}
+		if err := fn(et); err != nil {
+			return et.Tzap.Fail(err)
+		}
+		return et.Tzap
+	})
+	t.endWorkflow(workflowResult, nt.Name)
+	return workflowResult
+}
 
-	return et.Tzap
+// endWorkflow attributes the calls made from workflowResult, returned by the workflow name started on t, to the
+// workflow of t again. Unlike ApplyWorkflow, ApplyWorkflowFN and ApplyErrorWorkflow return the Tzap of the
+// workflow itself, so only a result created in the workflow is changed. A failed result keeps the workflow it failed in.
+func (t *Tzap) endWorkflow(workflowResult *Tzap, name string) {
+	if workflowResult.err == nil && workflowResult != t && workflowResult.workflow == name {
+		workflowResult.workflow = t.workflow
+	}
+}
+
+// closureSuffix matches the suffixes the compiler adds to the names of function literals and method values.
+var closureSuffix = regexp.MustCompile(`(\.func\d+)+$|-fm$`)
+
+// workflowFuncName returns the name of fn without its import path, and without the suffixes of function literals,
+// so that a workflow returned by codegeneration.MakeCode is named "codegeneration.MakeCode".
+func workflowFuncName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return "ApplyWorkflowFN"
+	}
+	name := f.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	return closureSuffix.ReplaceAllString(name, "")
 }
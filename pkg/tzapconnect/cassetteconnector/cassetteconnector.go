@@ -0,0 +1,347 @@
+// Package cassetteconnector records the model calls of a TGenerator to a cassette file and replays them offline.
+//
+// Calls are keyed by a hash of their method and inputs. Recording stores GenerateChat, GenerateChatStream,
+// GenerateChatWithTools, GenerateChatJSON, FetchEmbedding, CountTokens, OffsetTokens and RawTokens. Every other call,
+// such as the embedding store, goes to the wrapped TGenerator.
+package cassetteconnector
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// CassetteVersion is the version written to cassette files. Loading rejects files of other versions.
+const CassetteVersion = 1
+
+// ErrNotRecorded is returned by a replaying connector for a call missing from its cassette.
+var ErrNotRecorded = errors.New("call not recorded in cassette")
+
+// Interaction is one recorded call.
+type Interaction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Cassette holds recorded interactions by the hash of their method and request.
+type Cassette struct {
+	lock         sync.Mutex
+	path         string
+	interactions map[string]Interaction
+}
+
+type cassetteFile struct {
+	Version      int                    `json:"version"`
+	Interactions map[string]Interaction `json:"interactions"`
+}
+
+// NewCassette returns an empty cassette that Save writes to path.
+func NewCassette(path string) *Cassette {
+	return &Cassette{path: path, interactions: map[string]Interaction{}}
+}
+
+// LoadCassette reads the cassette at path.
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cassette: %w", err)
+	}
+	var file cassetteFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("error decoding cassette %s: %w", path, err)
+	}
+	if file.Version != CassetteVersion {
+		return nil, fmt.Errorf("cassette %s has unsupported version %d, expected %d", path, file.Version, CassetteVersion)
+	}
+	cassette := NewCassette(path)
+	for key, interaction := range file.Interactions {
+		cassette.interactions[key] = interaction
+	}
+	return cassette, nil
+}
+
+// OpenCassette loads the cassette at path, or returns an empty one if the file does not exist yet.
+func OpenCassette(path string) (*Cassette, error) {
+	cassette, err := LoadCassette(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewCassette(path), nil
+	}
+	return cassette, err
+}
+
+// Len returns the number of recorded interactions.
+func (c *Cassette) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.interactions)
+}
+
+// Save writes the cassette to its path. Keys are sorted by encoding/json, so re-recording the same calls gives the same file.
+func (c *Cassette) Save() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.save()
+}
+
+func (c *Cassette) save() error {
+	data, err := json.MarshalIndent(cassetteFile{Version: CassetteVersion, Interactions: c.interactions}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding cassette: %w", err)
+	}
+	if dir := filepath.Dir(c.path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("error writing cassette: %w", err)
+		}
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("error writing cassette: %w", err)
+	}
+	return nil
+}
+
+// record stores an interaction and saves the cassette, so recordings survive a CLI exiting early.
+func (c *Cassette) record(method string, request any, response any) error {
+	key, requestJSON, err := hashRequest(method, request)
+	if err != nil {
+		return err
+	}
+	responseJSON, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("error encoding %s response: %w", method, err)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if recorded, ok := c.interactions[key]; ok && string(recorded.Response) == string(responseJSON) {
+		return nil
+	}
+	c.interactions[key] = Interaction{Method: method, Request: requestJSON, Response: responseJSON}
+	return c.save()
+}
+
+// replay decodes the recorded response of a call into response.
+func (c *Cassette) replay(method string, request any, response any) error {
+	key, requestJSON, err := hashRequest(method, request)
+	if err != nil {
+		return err
+	}
+	c.lock.Lock()
+	interaction, ok := c.interactions[key]
+	c.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("%s %s: %w (%s)", method, key[:12], ErrNotRecorded, abbreviate(string(requestJSON)))
+	}
+	if err := json.Unmarshal(interaction.Response, response); err != nil {
+		return fmt.Errorf("error decoding recorded %s response: %w", method, err)
+	}
+	return nil
+}
+
+func hashRequest(method string, request any) (string, json.RawMessage, error) {
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding %s request: %w", method, err)
+	}
+	sum := sha256.Sum256(append([]byte(method+"\n"), requestJSON...))
+	return hex.EncodeToString(sum[:]), requestJSON, nil
+}
+
+func abbreviate(s string) string {
+	if len(s) > 200 {
+		return s[:200] + "..."
+	}
+	return s
+}
+
+type chatRequest struct {
+	Messages []types.Message `json:"messages"`
+}
+type toolsRequest struct {
+	Messages []types.Message        `json:"messages"`
+	Tools    []types.ToolDefinition `json:"tools"`
+}
+type jsonRequest struct {
+	Messages []types.Message `json:"messages"`
+	Schema   json.RawMessage `json:"schema"`
+}
+type embeddingRequest struct {
+	Content []string `json:"content"`
+}
+type contentRequest struct {
+	Content string `json:"content"`
+}
+type offsetRequest struct {
+	Content string `json:"content"`
+	From    int    `json:"from"`
+	To      int    `json:"to"`
+}
+type offsetResponse struct {
+	Content string `json:"content"`
+	Offset  int    `json:"offset"`
+}
+
+// Recorder passes every call to the wrapped TGenerator and records the model calls to its cassette.
+// Like tzapconnect.Wrapped, it implements the optional chat interfaces of types. When the wrapped TGenerator
+// lacks one, streaming and JSON calls fall back to GenerateChat, and tool calls fail.
+type Recorder struct {
+	types.TGenerator
+	Cassette *Cassette
+}
+
+// RecordWithConfig wraps the TGenerator of connector in a Recorder writing to cassette.
+func RecordWithConfig(connector types.TzapConnector, cassette *Cassette) types.TzapConnector {
+	tg, conf := connector()
+	recorder := Recorder{TGenerator: tg, Cassette: cassette}
+	return func() (types.TGenerator, config.Configuration) {
+		return recorder, conf
+	}
+}
+
+func (r Recorder) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	content, err := r.TGenerator.GenerateChat(ctx, messages, stream)
+	if err != nil {
+		return content, err
+	}
+	return content, r.Cassette.record("GenerateChat", chatRequest{Messages: messages}, content)
+}
+func (r Recorder) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	var content string
+	var err error
+	if streamTG, ok := r.TGenerator.(types.StreamChatGenerator); ok {
+		content, err = streamTG.GenerateChatStream(ctx, messages, onDelta)
+	} else if content, err = r.TGenerator.GenerateChat(ctx, messages, false); err == nil && content != "" {
+		err = onDelta(content)
+	}
+	if err != nil {
+		return content, err
+	}
+	return content, r.Cassette.record("GenerateChatStream", chatRequest{Messages: messages}, content)
+}
+func (r Recorder) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	toolTG, ok := r.TGenerator.(types.ToolChatGenerator)
+	if !ok {
+		return types.ChatResponse{}, fmt.Errorf("the connector %T does not support tool calling", r.TGenerator)
+	}
+	response, err := toolTG.GenerateChatWithTools(ctx, messages, tools, stream)
+	if err != nil {
+		return response, err
+	}
+	return response, r.Cassette.record("GenerateChatWithTools", toolsRequest{Messages: messages, Tools: tools}, response)
+}
+func (r Recorder) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	var content string
+	var err error
+	if jsonTG, ok := r.TGenerator.(types.JSONChatGenerator); ok {
+		content, err = jsonTG.GenerateChatJSON(ctx, messages, schema, stream)
+	} else {
+		content, err = r.TGenerator.GenerateChat(ctx, messages, stream)
+	}
+	if err != nil {
+		return content, err
+	}
+	return content, r.Cassette.record("GenerateChatJSON", jsonRequest{Messages: messages, Schema: schema}, content)
+}
+func (r Recorder) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	embeddings, err := r.TGenerator.FetchEmbedding(ctx, content...)
+	if err != nil {
+		return embeddings, err
+	}
+	return embeddings, r.Cassette.record("FetchEmbedding", embeddingRequest{Content: content}, embeddings)
+}
+func (r Recorder) CountTokens(ctx context.Context, content string) (int, error) {
+	count, err := r.TGenerator.CountTokens(ctx, content)
+	if err != nil {
+		return count, err
+	}
+	return count, r.Cassette.record("CountTokens", contentRequest{Content: content}, count)
+}
+func (r Recorder) OffsetTokens(ctx context.Context, content string, from int, to int) (string, int, error) {
+	offsetContent, offset, err := r.TGenerator.OffsetTokens(ctx, content, from, to)
+	if err != nil {
+		return offsetContent, offset, err
+	}
+	request := offsetRequest{Content: content, From: from, To: to}
+	return offsetContent, offset, r.Cassette.record("OffsetTokens", request, offsetResponse{Content: offsetContent, Offset: offset})
+}
+func (r Recorder) RawTokens(ctx context.Context, content string) ([]string, error) {
+	tokens, err := r.TGenerator.RawTokens(ctx, content)
+	if err != nil {
+		return tokens, err
+	}
+	return tokens, r.Cassette.record("RawTokens", contentRequest{Content: content}, tokens)
+}
+
+// Replayer serves the model calls from its cassette and fails with ErrNotRecorded on calls it has not seen.
+// Other calls go to the wrapped TGenerator, which is usually a local embedding store without network access.
+// Streamed completions are replayed to onDelta in one part.
+type Replayer struct {
+	types.TGenerator
+	Cassette *Cassette
+}
+
+// ReplayWithConfig returns a connector replaying cassette. Calls that are not recorded go to store.
+func ReplayWithConfig(store types.TGenerator, cassette *Cassette, conf config.Configuration) types.TzapConnector {
+	replayer := Replayer{TGenerator: store, Cassette: cassette}
+	return func() (types.TGenerator, config.Configuration) {
+		return replayer, conf
+	}
+}
+
+func (r Replayer) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	var content string
+	err := r.Cassette.replay("GenerateChat", chatRequest{Messages: messages}, &content)
+	return content, err
+}
+func (r Replayer) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	var content string
+	if err := r.Cassette.replay("GenerateChatStream", chatRequest{Messages: messages}, &content); err != nil {
+		return content, err
+	}
+	if content == "" {
+		return content, nil
+	}
+	return content, onDelta(content)
+}
+func (r Replayer) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	var response types.ChatResponse
+	err := r.Cassette.replay("GenerateChatWithTools", toolsRequest{Messages: messages, Tools: tools}, &response)
+	return response, err
+}
+func (r Replayer) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	var content string
+	err := r.Cassette.replay("GenerateChatJSON", jsonRequest{Messages: messages, Schema: schema}, &content)
+	return content, err
+}
+func (r Replayer) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	var embeddings [][]float32
+	err := r.Cassette.replay("FetchEmbedding", embeddingRequest{Content: content}, &embeddings)
+	return embeddings, err
+}
+func (r Replayer) CountTokens(ctx context.Context, content string) (int, error) {
+	var count int
+	err := r.Cassette.replay("CountTokens", contentRequest{Content: content}, &count)
+	return count, err
+}
+func (r Replayer) OffsetTokens(ctx context.Context, content string, from int, to int) (string, int, error) {
+	var response offsetResponse
+	err := r.Cassette.replay("OffsetTokens", offsetRequest{Content: content, From: from, To: to}, &response)
+	return response.Content, response.Offset, err
+}
+func (r Replayer) RawTokens(ctx context.Context, content string) ([]string, error) {
+	var tokens []string
+	err := r.Cassette.replay("RawTokens", contentRequest{Content: content}, &tokens)
+	return tokens, err
+}
@@ -0,0 +1,123 @@
+package cassetteconnector_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzapconnect/cassetteconnector"
+	"github.com/tzapio/tzap/pkg/tzapconnect/stubconnector"
+)
+
+func TestRecordThenReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "cassettes", "session.json")
+	messages := []types.Message{{Role: "user", Content: "hi"}}
+
+	cassette, err := cassetteconnector.OpenCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, _ := cassetteconnector.RecordWithConfig(stubconnector.StubWithConfig(config.Configuration{}), cassette)()
+	if _, err := recorder.GenerateChat(ctx, messages, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.FetchEmbedding(ctx, "a", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.CountTokens(ctx, "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := cassetteconnector.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Len() != 3 {
+		t.Fatalf("expected 3 interactions, got %d", loaded.Len())
+	}
+	replayer, _ := cassetteconnector.ReplayWithConfig(stubconnector.StubConnector{}, loaded, config.Configuration{})()
+
+	content, err := replayer.GenerateChat(ctx, messages, true)
+	if err != nil || content != "Hello world" {
+		t.Errorf("expected recorded chat, got %q, %v", content, err)
+	}
+	embeddings, err := replayer.FetchEmbedding(ctx, "a", "b")
+	if err != nil || len(embeddings) != 1 || embeddings[0][5] != 5 {
+		t.Errorf("expected recorded embedding, got %v, %v", len(embeddings), err)
+	}
+	count, err := replayer.CountTokens(ctx, "hi")
+	if err != nil || count != len("Hello world") {
+		t.Errorf("expected recorded count, got %d, %v", count, err)
+	}
+}
+
+func TestRecordThenReplay_givenStreamToolAndJSONCalls_expectPassedThrough(t *testing.T) {
+	ctx := context.Background()
+	messages := []types.Message{{Role: "user", Content: "hi"}}
+	tools := []types.ToolDefinition{{Name: "lookup", Parameters: json.RawMessage(`{"type":"object"}`)}}
+	schema := json.RawMessage(`{"type":"object"}`)
+
+	cassette := cassetteconnector.NewCassette(filepath.Join(t.TempDir(), "session.json"))
+	recorder, _ := cassetteconnector.RecordWithConfig(stubconnector.StubWithConfig(config.Configuration{}), cassette)()
+	streamed := ""
+	if _, err := recorder.(types.StreamChatGenerator).GenerateChatStream(ctx, messages, func(delta string) error {
+		streamed += delta + "|"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if streamed != "Hello| world|" {
+		t.Errorf("expected the recording to stream, got %q", streamed)
+	}
+	if _, err := recorder.(types.ToolChatGenerator).GenerateChatWithTools(ctx, messages, tools, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := recorder.(types.JSONChatGenerator).GenerateChatJSON(ctx, messages, schema, false); err != nil {
+		t.Fatal(err)
+	}
+	if cassette.Len() != 3 {
+		t.Fatalf("expected 3 interactions, got %d", cassette.Len())
+	}
+
+	replayer, _ := cassetteconnector.ReplayWithConfig(stubconnector.StubConnector{}, cassette, config.Configuration{})()
+	replayed := ""
+	content, err := replayer.(types.StreamChatGenerator).GenerateChatStream(ctx, messages, func(delta string) error {
+		replayed += delta
+		return nil
+	})
+	if err != nil || content != "Hello world" || replayed != "Hello world" {
+		t.Errorf("expected the recorded stream, got %q streaming %q, %v", content, replayed, err)
+	}
+	response, err := replayer.(types.ToolChatGenerator).GenerateChatWithTools(ctx, messages, tools, false)
+	if err != nil || response.ToolCall == nil || response.ToolCall.Name != "lookup" {
+		t.Errorf("expected the recorded tool call, got %+v, %v", response, err)
+	}
+	content, err = replayer.(types.JSONChatGenerator).GenerateChatJSON(ctx, messages, schema, false)
+	if err != nil || content != "Hello world" {
+		t.Errorf("expected the recorded JSON answer, got %q, %v", content, err)
+	}
+	if _, err := replayer.(types.ToolChatGenerator).GenerateChatWithTools(ctx, messages, nil, false); !errors.Is(err, cassetteconnector.ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded for other tools, got %v", err)
+	}
+}
+
+func TestReplayUnrecordedCallFails(t *testing.T) {
+	ctx := context.Background()
+	replayer, _ := cassetteconnector.ReplayWithConfig(stubconnector.StubConnector{}, cassetteconnector.NewCassette(""), config.Configuration{})()
+
+	_, err := replayer.GenerateChat(ctx, []types.Message{{Role: "user", Content: "never recorded"}}, false)
+	if !errors.Is(err, cassetteconnector.ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+	if _, err := replayer.FetchEmbedding(ctx, "x"); !errors.Is(err, cassetteconnector.ErrNotRecorded) {
+		t.Errorf("expected ErrNotRecorded, got %v", err)
+	}
+	if _, err := replayer.ListAllEmbeddingsIds(ctx); err != nil {
+		t.Errorf("expected store calls to go to the wrapped connector, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package tzapconnect
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 
 	"github.com/tzapio/tzap/internal/logging/tl"
@@ -18,8 +19,9 @@ func WithConfig(openai_apikey string, conf config.Configuration) types.TzapConne
 		println(err)
 		os.Exit(1)
 	}
+	wrapped := Wrap(tg, DefaultMiddleware(conf)...)
 	return func() (types.TGenerator, config.Configuration) {
-		return tg, conf
+		return wrapped, conf
 	}
 }
 
@@ -47,7 +49,16 @@ func (pc PartialComposite) TextToSpeech(ctx context.Context, content, language,
 func (pc PartialComposite) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
 	return pc.OpenaiTgenerator.GenerateChat(ctx, messages, stream)
 }
-func (pc PartialComposite) FetchEmbedding(ctx context.Context, content ...string) ([][1536]float32, error) {
+func (pc PartialComposite) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	return pc.OpenaiTgenerator.GenerateChatStream(ctx, messages, onDelta)
+}
+func (pc PartialComposite) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	return pc.OpenaiTgenerator.GenerateChatWithTools(ctx, messages, tools, stream)
+}
+func (pc PartialComposite) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	return pc.OpenaiTgenerator.GenerateChatJSON(ctx, messages, schema, stream)
+}
+func (pc PartialComposite) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
 	return pc.OpenaiTgenerator.FetchEmbedding(ctx, content...)
 }
 func (pc PartialComposite) CountTokens(ctx context.Context, content string) (int, error) {
@@ -59,7 +70,7 @@ func (pc PartialComposite) OffsetTokens(ctx context.Context, content string, fro
 func (pc PartialComposite) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
 	return embedstore.EmbedStore.SearchWithEmbedding(ctx, embedding, k)
 }
-func (pc PartialComposite) AddEmbeddingDocument(ctx context.Context, docID string, embedding [1536]float32, metadata types.Metadata) error {
+func (pc PartialComposite) AddEmbeddingDocument(ctx context.Context, docID string, embedding []float32, metadata types.Metadata) error {
 	return embedstore.EmbedStore.AddEmbeddingDocument(ctx, docID, embedding, metadata)
 }
 func (pc PartialComposite) GetEmbeddingDocument(ctx context.Context, docID string) (types.Vector, bool, error) {
@@ -0,0 +1,63 @@
+package tzapconnect
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CallCount is what Counters counted for one method.
+type CallCount struct {
+	Calls    int
+	Errors   int
+	Duration time.Duration
+}
+
+// Counters counts calls, failed calls and time spent per method. Placed after Retry in the middleware chain it counts every attempt.
+type Counters struct {
+	lock   sync.Mutex
+	counts map[string]CallCount
+}
+
+func NewCounters() *Counters {
+	return &Counters{counts: map[string]CallCount{}}
+}
+
+// Middleware returns the middleware counting into c.
+func (c *Counters) Middleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call Call) (Result, error) {
+			start := time.Now()
+			result, err := next(ctx, call)
+
+			c.lock.Lock()
+			defer c.lock.Unlock()
+			count := c.counts[call.Method]
+			count.Calls++
+			if err != nil {
+				count.Errors++
+			}
+			count.Duration += time.Since(start)
+			c.counts[call.Method] = count
+			return result, err
+		}
+	}
+}
+
+// Get returns the counts of method.
+func (c *Counters) Get(method string) CallCount {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.counts[method]
+}
+
+// Snapshot returns the counts of every method called so far.
+func (c *Counters) Snapshot() map[string]CallCount {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	snapshot := make(map[string]CallCount, len(c.counts))
+	for method, count := range c.counts {
+		snapshot[method] = count
+	}
+	return snapshot
+}
@@ -0,0 +1,29 @@
+package tzapconnect
+
+import (
+	"context"
+
+	"github.com/tzapio/tzap/internal/logging/filelog"
+	"github.com/tzapio/tzap/internal/logging/tl"
+)
+
+// Log writes the messages and the response of chat calls to the request and response logs under config.LoggerOutput.
+// Other calls are only logged to tl.Logger, since embedding a project makes many of them.
+func Log() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call Call) (Result, error) {
+			if !call.IsChat() {
+				tl.Logger.Println(call.Method, len(call.Input), "inputs")
+				return next(ctx, call)
+			}
+			filelog.LogData(ctx, call.Messages, filelog.RequestLog)
+			result, err := next(ctx, call)
+			if err != nil {
+				filelog.LogData(ctx, err.Error(), filelog.ResponseLog)
+				return result, err
+			}
+			filelog.LogData(ctx, result.ChatResponse, filelog.ResponseLog)
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package tzapconnect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// Call is one model call passing through middleware. Method is the name of the TGenerator method,
+// such as "GenerateChat", "GenerateChatStream" or "FetchEmbedding". Only the fields used by the method are set.
+type Call struct {
+	Method   string
+	Messages []types.Message
+	Stream   bool
+	OnDelta  func(delta string) error
+	Tools    []types.ToolDefinition
+	Schema   json.RawMessage
+	// Input holds the FetchEmbedding content, or the TextToSpeech content.
+	Input    []string
+	Audio    *[]byte
+	Language string
+	Voice    string
+
+	tg types.TGenerator
+}
+
+// CountTokens counts the tokens of the messages and inputs of the call with the wrapped TGenerator.
+func (c Call) CountTokens(ctx context.Context) (int, error) {
+	total := 0
+	for _, message := range c.Messages {
+		count, err := c.tg.CountTokens(ctx, message.Content)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	for _, input := range c.Input {
+		count, err := c.tg.CountTokens(ctx, input)
+		if err != nil {
+			return 0, err
+		}
+		total += count
+	}
+	return total, nil
+}
+
+// IsChat reports whether the call is a chat completion.
+func (c Call) IsChat() bool {
+	return c.Messages != nil
+}
+
+// Result is what a call returned. Chat calls set ChatResponse, SpeechToText sets its Content.
+type Result struct {
+	types.ChatResponse
+	Embeddings [][]float32
+	Audio      *[]byte
+}
+
+// Handler makes a call.
+type Handler func(ctx context.Context, call Call) (Result, error)
+
+// Middleware wraps a Handler, for example to retry or log calls.
+type Middleware func(next Handler) Handler
+
+// Wrapped is a TGenerator whose model calls go through middleware. Calls to the embedding store
+// and the tokenizer go straight to the wrapped TGenerator.
+type Wrapped struct {
+	types.TGenerator
+	handler Handler
+}
+
+// Wrap returns tg with its model calls passing through mw. The first middleware is the outermost one.
+// Wrapped implements the optional chat interfaces of types. When tg lacks one, streaming and JSON calls
+// fall back to GenerateChat, and tool calls fail.
+func Wrap(tg types.TGenerator, mw ...Middleware) *Wrapped {
+	w := &Wrapped{TGenerator: tg}
+	handler := w.call
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	w.handler = handler
+	return w
+}
+
+// WrapConnector returns connector with its TGenerator wrapped by Wrap.
+func WrapConnector(connector types.TzapConnector, mw ...Middleware) types.TzapConnector {
+	tg, conf := connector()
+	wrapped := Wrap(tg, mw...)
+	return func() (types.TGenerator, config.Configuration) {
+		return wrapped, conf
+	}
+}
+
+// DefaultMiddleware is the middleware WithConfig wraps connectors in: logging, retries and the rate limits of conf.
+func DefaultMiddleware(conf config.Configuration) []Middleware {
+	return []Middleware{
+		Log(),
+		Retry(RetryOptions{MaxRetries: conf.MaxRetries}),
+		RateLimit(RateLimitOptions{RequestsPerMinute: conf.RequestsPerMinute, TokensPerMinute: conf.TokensPerMinute}),
+	}
+}
+
+func (w *Wrapped) do(ctx context.Context, call Call) (Result, error) {
+	call.tg = w.TGenerator
+	return w.handler(ctx, call)
+}
+
+// call is the innermost handler, calling the wrapped TGenerator.
+func (w *Wrapped) call(ctx context.Context, call Call) (Result, error) {
+	switch call.Method {
+	case "GenerateChat":
+		content, err := w.TGenerator.GenerateChat(ctx, call.Messages, call.Stream)
+		return Result{ChatResponse: types.ChatResponse{Content: content}}, err
+	case "GenerateChatStream":
+		if streamTG, ok := w.TGenerator.(types.StreamChatGenerator); ok {
+			content, err := streamTG.GenerateChatStream(ctx, call.Messages, call.OnDelta)
+			return Result{ChatResponse: types.ChatResponse{Content: content}}, err
+		}
+		content, err := w.TGenerator.GenerateChat(ctx, call.Messages, false)
+		if err == nil && content != "" {
+			err = call.OnDelta(content)
+		}
+		return Result{ChatResponse: types.ChatResponse{Content: content}}, err
+	case "GenerateChatWithTools":
+		toolTG, ok := w.TGenerator.(types.ToolChatGenerator)
+		if !ok {
+			return Result{}, fmt.Errorf("the connector %T does not support tool calling", w.TGenerator)
+		}
+		response, err := toolTG.GenerateChatWithTools(ctx, call.Messages, call.Tools, call.Stream)
+		return Result{ChatResponse: response}, err
+	case "GenerateChatJSON":
+		if jsonTG, ok := w.TGenerator.(types.JSONChatGenerator); ok {
+			content, err := jsonTG.GenerateChatJSON(ctx, call.Messages, call.Schema, call.Stream)
+			return Result{ChatResponse: types.ChatResponse{Content: content}}, err
+		}
+		content, err := w.TGenerator.GenerateChat(ctx, call.Messages, call.Stream)
+		return Result{ChatResponse: types.ChatResponse{Content: content}}, err
+	case "FetchEmbedding":
+		embeddings, err := w.TGenerator.FetchEmbedding(ctx, call.Input...)
+		return Result{Embeddings: embeddings}, err
+	case "TextToSpeech":
+		audio, err := w.TGenerator.TextToSpeech(ctx, call.Input[0], call.Language, call.Voice)
+		return Result{Audio: audio}, err
+	case "SpeechToText":
+		text, err := w.TGenerator.SpeechToText(ctx, call.Audio, call.Language)
+		return Result{ChatResponse: types.ChatResponse{Content: text}}, err
+	}
+	return Result{}, fmt.Errorf("unknown call %q", call.Method)
+}
+
+func (w *Wrapped) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	result, err := w.do(ctx, Call{Method: "GenerateChat", Messages: nonNil(messages), Stream: stream})
+	return result.Content, err
+}
+func (w *Wrapped) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	result, err := w.do(ctx, Call{Method: "GenerateChatStream", Messages: nonNil(messages), Stream: true, OnDelta: onDelta})
+	return result.Content, err
+}
+func (w *Wrapped) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	result, err := w.do(ctx, Call{Method: "GenerateChatWithTools", Messages: nonNil(messages), Tools: tools, Stream: stream})
+	return result.ChatResponse, err
+}
+func (w *Wrapped) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	result, err := w.do(ctx, Call{Method: "GenerateChatJSON", Messages: nonNil(messages), Schema: schema, Stream: stream})
+	return result.Content, err
+}
+func (w *Wrapped) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	result, err := w.do(ctx, Call{Method: "FetchEmbedding", Input: content})
+	return result.Embeddings, err
+}
+func (w *Wrapped) TextToSpeech(ctx context.Context, content, language, voice string) (*[]byte, error) {
+	result, err := w.do(ctx, Call{Method: "TextToSpeech", Input: []string{content}, Language: language, Voice: voice})
+	return result.Audio, err
+}
+func (w *Wrapped) SpeechToText(ctx context.Context, audioContent *[]byte, language string) (string, error) {
+	result, err := w.do(ctx, Call{Method: "SpeechToText", Audio: audioContent, Language: language})
+	return result.Content, err
+}
+
+// nonNil keeps IsChat true for chat calls without messages.
+func nonNil(messages []types.Message) []types.Message {
+	if messages == nil {
+		return []types.Message{}
+	}
+	return messages
+}
@@ -0,0 +1,150 @@
+package tzapconnect_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzapconnect"
+)
+
+// failingTG fails GenerateChat with errs in order, then answers "ok".
+type failingTG struct {
+	types.UnimplementedTGenerator
+	errs  []error
+	calls int
+}
+
+func (tg *failingTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	tg.calls++
+	if tg.calls <= len(tg.errs) {
+		return "", tg.errs[tg.calls-1]
+	}
+	return "ok", nil
+}
+
+func (tg *failingTG) CountTokens(ctx context.Context, content string) (int, error) {
+	return len(content), nil
+}
+
+func TestRetry_givenTemporaryErrors_expectRetriedWithRetryAfter(t *testing.T) {
+	tg := &failingTG{errs: []error{
+		&types.APIError{StatusCode: 429, RetryAfter: 20 * time.Millisecond, Err: errors.New("slow down")},
+		&types.APIError{StatusCode: 500, Err: errors.New("oops")},
+	}}
+	counters := tzapconnect.NewCounters()
+	wrapped := tzapconnect.Wrap(tg, tzapconnect.Retry(tzapconnect.RetryOptions{BaseDelay: time.Millisecond}), counters.Middleware())
+
+	start := time.Now()
+	content, err := wrapped.GenerateChat(context.Background(), nil, false)
+	if err != nil || content != "ok" {
+		t.Fatalf("expected ok after retries, got %q, %v", content, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected to wait for Retry-After, waited %s", elapsed)
+	}
+	if count := counters.Get("GenerateChat"); count.Calls != 3 || count.Errors != 2 {
+		t.Errorf("expected 3 attempts with 2 errors, got %+v", count)
+	}
+}
+
+func TestRetry_givenPermanentError_expectNoRetry(t *testing.T) {
+	tg := &failingTG{errs: []error{&types.APIError{StatusCode: 400, Err: errors.New("bad request")}}}
+	wrapped := tzapconnect.Wrap(tg, tzapconnect.Retry(tzapconnect.RetryOptions{BaseDelay: time.Millisecond}))
+
+	_, err := wrapped.GenerateChat(context.Background(), nil, false)
+	apiErr := &types.APIError{}
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("expected the api error, got %v", err)
+	}
+	if tg.calls != 1 {
+		t.Errorf("expected one call, got %d", tg.calls)
+	}
+}
+
+func TestRetry_givenMaxRetries_expectLastError(t *testing.T) {
+	temporary := &types.APIError{StatusCode: 503, Err: errors.New("unavailable")}
+	tg := &failingTG{errs: []error{temporary, temporary, temporary}}
+	wrapped := tzapconnect.Wrap(tg, tzapconnect.Retry(tzapconnect.RetryOptions{MaxRetries: 1, BaseDelay: time.Millisecond}))
+
+	if _, err := wrapped.GenerateChat(context.Background(), nil, false); !errors.Is(err, temporary) {
+		t.Errorf("expected the temporary error, got %v", err)
+	}
+	if tg.calls != 2 {
+		t.Errorf("expected two calls, got %d", tg.calls)
+	}
+}
+
+// partialStreamTG delivers one delta and then fails with a temporary error.
+type partialStreamTG struct {
+	types.UnimplementedTGenerator
+	calls int
+}
+
+func (tg *partialStreamTG) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	tg.calls++
+	if err := onDelta("Hel"); err != nil {
+		return "", err
+	}
+	return "Hel", &types.APIError{StatusCode: 500, Err: errors.New("connection reset")}
+}
+
+func TestRetry_givenDeliveredDelta_expectNoRetry(t *testing.T) {
+	tg := &partialStreamTG{}
+	wrapped := tzapconnect.Wrap(tg, tzapconnect.Retry(tzapconnect.RetryOptions{BaseDelay: time.Millisecond}))
+
+	_, err := wrapped.GenerateChatStream(context.Background(), nil, func(delta string) error { return nil })
+	if err == nil {
+		t.Fatal("expected the stream error")
+	}
+	if tg.calls != 1 {
+		t.Errorf("expected no retry after a delta was delivered, got %d calls", tg.calls)
+	}
+}
+
+func TestWrap_givenNonStreamingTG_expectStreamFallback(t *testing.T) {
+	wrapped := tzapconnect.Wrap(&failingTG{})
+	var deltas []string
+	content, err := wrapped.GenerateChatStream(context.Background(), nil, func(delta string) error {
+		deltas = append(deltas, delta)
+		return nil
+	})
+	if err != nil || content != "ok" || len(deltas) != 1 || deltas[0] != "ok" {
+		t.Errorf("expected one delta with the answer, got %q %v %v", content, deltas, err)
+	}
+	if _, err := wrapped.GenerateChatWithTools(context.Background(), nil, nil, false); err == nil {
+		t.Error("expected tool calls to fail on a connector without tool support")
+	}
+}
+
+func TestRateLimit_givenTokensPerMinute_expectDelay(t *testing.T) {
+	// 60000 tokens per minute refill one token per millisecond.
+	wrapped := tzapconnect.Wrap(&failingTG{}, tzapconnect.RateLimit(tzapconnect.RateLimitOptions{TokensPerMinute: 60000}))
+	messages := []types.Message{{Role: "user", Content: string(make([]byte, 60000))}}
+	if _, err := wrapped.GenerateChat(context.Background(), messages, false); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	messages = []types.Message{{Role: "user", Content: string(make([]byte, 20))}}
+	if _, err := wrapped.GenerateChat(context.Background(), messages, false); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 15*time.Millisecond {
+		t.Errorf("expected to wait for the bucket to refill, waited %s", elapsed)
+	}
+}
+
+func TestRateLimit_givenCancelledContext_expectError(t *testing.T) {
+	wrapped := tzapconnect.Wrap(&failingTG{}, tzapconnect.RateLimit(tzapconnect.RateLimitOptions{RequestsPerMinute: 1}))
+	if _, err := wrapped.GenerateChat(context.Background(), nil, false); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := wrapped.GenerateChat(ctx, nil, false); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline error, got %v", err)
+	}
+}
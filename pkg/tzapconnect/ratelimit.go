@@ -0,0 +1,88 @@
+package tzapconnect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimitOptions configures RateLimit. 0 means unlimited.
+type RateLimitOptions struct {
+	RequestsPerMinute int
+	// TokensPerMinute limits the tokens sent, counted with Call.CountTokens.
+	TokensPerMinute int
+}
+
+// RateLimit delays calls to stay within the requests and tokens per minute of opts.
+// The limits are token buckets shared by every call through the returned middleware, so bursts up to a minute's worth are allowed.
+func RateLimit(opts RateLimitOptions) Middleware {
+	requests := newTokenBucket(opts.RequestsPerMinute)
+	tokens := newTokenBucket(opts.TokensPerMinute)
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call Call) (Result, error) {
+			if err := requests.wait(ctx, 1); err != nil {
+				return Result{}, err
+			}
+			if tokens != nil {
+				count, err := call.CountTokens(ctx)
+				if err != nil {
+					return Result{}, fmt.Errorf("rate limit: error counting tokens: %w", err)
+				}
+				if err := tokens.wait(ctx, float64(count)); err != nil {
+					return Result{}, err
+				}
+			}
+			return next(ctx, call)
+		}
+	}
+}
+
+// tokenBucket holds up to perMinute tokens and refills them evenly over a minute. A nil bucket never waits.
+type tokenBucket struct {
+	lock      sync.Mutex
+	capacity  float64
+	available float64
+	last      time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{capacity: float64(perMinute), available: float64(perMinute), last: time.Now()}
+}
+
+// wait blocks until n tokens are available and takes them. A request larger than the bucket waits
+// for a full bucket and leaves it in debt, delaying the following requests.
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.lock.Lock()
+		now := time.Now()
+		b.available += now.Sub(b.last).Minutes() * b.capacity
+		if b.available > b.capacity {
+			b.available = b.capacity
+		}
+		b.last = now
+		need := n
+		if need > b.capacity {
+			need = b.capacity
+		}
+		if b.available >= need {
+			b.available -= n
+			b.lock.Unlock()
+			return nil
+		}
+		delay := time.Duration((need - b.available) / b.capacity * float64(time.Minute))
+		b.lock.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
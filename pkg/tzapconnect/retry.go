@@ -0,0 +1,74 @@
+package tzapconnect
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/types"
+)
+
+// RetryOptions configures Retry.
+type RetryOptions struct {
+	// MaxRetries is how often a failed call is retried. 0 means 3, below 0 means none.
+	MaxRetries int
+	// BaseDelay is the delay before the first retry. It doubles with every further retry. 0 means 1 second.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between retries. 0 means 1 minute.
+	MaxDelay time.Duration
+}
+
+// Retry retries calls failing with a temporary *types.APIError, such as rate limits and server errors.
+// It waits as long as the API asked for with Retry-After, or backs off exponentially with jitter.
+// Streamed calls are not retried once a part of the answer was delivered.
+func Retry(opts RetryOptions) Middleware {
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 3
+	}
+	if opts.BaseDelay == 0 {
+		opts.BaseDelay = time.Second
+	}
+	if opts.MaxDelay == 0 {
+		opts.MaxDelay = time.Minute
+	}
+	return func(next Handler) Handler {
+		return func(ctx context.Context, call Call) (Result, error) {
+			delivered := false
+			if onDelta := call.OnDelta; onDelta != nil {
+				call.OnDelta = func(delta string) error {
+					delivered = true
+					return onDelta(delta)
+				}
+			}
+			for attempt := 0; ; attempt++ {
+				result, err := next(ctx, call)
+				apiErr := &types.APIError{}
+				if err == nil || attempt >= opts.MaxRetries || delivered || ctx.Err() != nil ||
+					!errors.As(err, &apiErr) || !apiErr.Temporary() {
+					return result, err
+				}
+				delay := apiErr.RetryAfter
+				if delay <= 0 {
+					delay = backoff(opts.BaseDelay, opts.MaxDelay, attempt)
+				}
+				tl.UILogger.Printf("%s failed (%v). Retry %d of %d in %s.\n", call.Method, err, attempt+1, opts.MaxRetries, delay.Round(time.Millisecond))
+				select {
+				case <-ctx.Done():
+					return result, err
+				case <-time.After(delay):
+				}
+			}
+		}
+	}
+}
+
+// backoff returns a random delay between half and all of base doubled attempt times, capped at max.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	delay := base << attempt
+	if delay > max || delay <= 0 {
+		delay = max
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
 )
 
 type StubConnector struct {
@@ -26,6 +27,34 @@ func (StubConnector) SpeechToText(ctx context.Context, audioContent *[]byte, lan
 func (StubConnector) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
 	return "Hello world", nil
 }
+
+// GenerateChatStream streams "Hello world" one word at a time.
+func (StubConnector) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	for _, delta := range []string{"Hello", " world"} {
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+	return "Hello world", nil
+}
+
+// GenerateChatWithTools calls the first tool with empty arguments until a tool result follows the last user message,
+// then answers with the content of that result, so tool loops can be exercised offline.
+func (StubConnector) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+search:
+	for i := len(messages) - 1; i >= 0; i-- {
+		switch messages[i].Role {
+		case openai.ChatMessageRoleFunction:
+			return types.ChatResponse{Content: messages[i].Content}, nil
+		case openai.ChatMessageRoleUser:
+			break search
+		}
+	}
+	if len(tools) == 0 {
+		return types.ChatResponse{Content: "Hello world"}, nil
+	}
+	return types.ChatResponse{ToolCall: &types.ToolCall{Name: tools[0].Name, Arguments: "{}"}}, nil
+}
 func (StubConnector) CountTokens(ctx context.Context, content string) (int, error) {
 	return len("Hello world"), nil
 }
@@ -35,13 +64,13 @@ func (StubConnector) OffsetTokens(ctx context.Context, content string, from int,
 func (StubConnector) RawTokens(ctx context.Context, content string) ([]string, error) {
 	return []string{}, nil
 }
-func (StubConnector) FetchEmbedding(ctx context.Context, content ...string) ([][1536]float32, error) {
-	return [][1536]float32{{0, 1, 2, 3, 4, 5}}, nil
+func (StubConnector) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	return [][]float32{{0, 1, 2, 3, 4, 5}}, nil
 }
 func (StubConnector) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
 	return types.SearchResults{}, nil
 }
-func (StubConnector) AddEmbeddingDocument(ctx context.Context, docID string, embedding [1536]float32, metadata types.Metadata) error {
+func (StubConnector) AddEmbeddingDocument(ctx context.Context, docID string, embedding []float32, metadata types.Metadata) error {
 	return nil
 }
 func (StubConnector) GetEmbeddingDocument(ctx context.Context, docID string) (types.Vector, bool, error) {
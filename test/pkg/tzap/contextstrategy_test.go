@@ -0,0 +1,111 @@
+package tzap_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// newStrategyTzap returns a tzap whose mockTG counts 50 tokens per message.
+func newStrategyTzap(strategy string, limit int) *tzap.Tzap {
+	return tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return &mockTG{}, config.Configuration{AutoMode: true, ContextStrategy: strategy, TruncateLimit: limit}
+	})
+}
+
+func contents(messages []types.Message) string {
+	var parts []string
+	for _, message := range messages {
+		parts = append(parts, message.Content)
+	}
+	return strings.Join(parts, ",")
+}
+
+func Test_FitContext_givenNewest_expectSystemPinnedAndNewestKept(t *testing.T) {
+	tt := newStrategyTzap(tzap.ContextStrategyNewest, 150)
+	thread := tzap.GetContextThread(tt.
+		AddSystemMessage("system").
+		AddUserMessage("u1").
+		AddAssistantMessage("a1").
+		AddUserMessage("u2"))
+
+	fitted, report, err := tt.FitContext(thread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(fitted); got != "system,a1,u2" {
+		t.Errorf("expected system,a1,u2, got %s", got)
+	}
+	if len(report.Dropped) != 1 || report.Dropped[0].Content != "u1" || report.Tokens != 150 {
+		t.Errorf("expected u1 to be reported dropped at 150 tokens, got %+v", report)
+	}
+}
+
+func Test_FitContext_givenRelevance_expectLowestScoringEmbeddingDropped(t *testing.T) {
+	tt := newStrategyTzap(tzap.ContextStrategyRelevance, 150)
+	high := tt.AddSystemMessage("system").AddSystemMessage("high")
+	tzap.RelevanceKey.Set(high, 0.9)
+	low := high.AddSystemMessage("low")
+	tzap.RelevanceKey.Set(low, 0.2)
+
+	fitted, report, err := tt.FitContext(tzap.GetContextThread(low.AddUserMessage("question")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(fitted); got != "system,high,question" {
+		t.Errorf("expected system,high,question, got %s", got)
+	}
+	if len(report.Dropped) != 1 || !report.Dropped[0].Embedded || report.Dropped[0].Content != "low" {
+		t.Errorf("expected the low scoring embedding to be reported dropped, got %+v", report.Dropped)
+	}
+}
+
+func Test_FitContext_givenSummarize_expectOldestTurnsSummarized(t *testing.T) {
+	tt := newStrategyTzap(tzap.ContextStrategySummarize, 200)
+	thread := tzap.GetContextThread(tt.
+		AddSystemMessage("system").
+		AddUserMessage("u1").
+		AddAssistantMessage("a1").
+		AddUserMessage("u2").
+		AddAssistantMessage("a2"))
+
+	fitted, report, err := tt.FitContext(thread)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fitted) != 4 || fitted[0].Content != "system" || fitted[2].Content != "u2" || fitted[3].Content != "a2" {
+		t.Fatalf("expected system, summary, u2, a2, got %s", contents(fitted))
+	}
+	if !strings.HasPrefix(fitted[1].Content, "Summary of the earlier conversation:") || !strings.Contains(fitted[1].Content, "user: u1") {
+		t.Errorf("expected a summary of u1 and a1, got %q", fitted[1].Content)
+	}
+	if len(report.Summarized) != 2 || len(report.Dropped) != 0 {
+		t.Errorf("expected 2 summarized and no dropped messages, got %+v", report)
+	}
+}
+
+func Test_RequestChatCompletion_givenTruncateLimit_expectContextReport(t *testing.T) {
+	requested := newStrategyTzap(tzap.ContextStrategyNewest, 100).
+		AddSystemMessage("system").
+		AddUserMessage("old").
+		AddUserMessage("new").
+		RequestChatCompletion()
+
+	if got := tzap.ContentKey.Must(requested); got != "r=system;c=system|r=user;c=new" {
+		t.Errorf("expected the old message to be left out, got %q", got)
+	}
+	report, ok := tzap.ContextReportKey.Lookup(requested)
+	if !ok || !report.Changed() {
+		t.Errorf("expected a context report with dropped messages, got %+v", report)
+	}
+}
+
+func Test_FitContext_givenUnknownStrategy_expectError(t *testing.T) {
+	tt := newStrategyTzap("unknown", 100)
+	if _, _, err := tt.FitContext(tzap.ContextMessages([]types.Message{{Role: "user", Content: "hi"}})); err == nil {
+		t.Fatal("expected an unknown strategy error")
+	}
+}
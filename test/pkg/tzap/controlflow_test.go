@@ -1,7 +1,12 @@
 package tzap_test
 
 import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
@@ -86,3 +91,104 @@ func Test_Map_givenChildrenTzaps_expectMappedChildren(t *testing.T) {
 		}
 	}
 }
+
+func Test_ParallelMap_givenChildrenTzaps_expectOrderedMappedChildren(t *testing.T) {
+	tt := tzap.InternalNew()
+	var children []*tzap.Tzap
+	var expected []string
+	for i := 0; i < 20; i++ {
+		content := fmt.Sprintf("Child%d", i)
+		children = append(children, tzap.InternalNew().AddUserMessage(content))
+		expected = append(expected, content+" Mapped")
+	}
+	tt.Data["children"] = children
+
+	var running, maxRunning int32
+	mapped := tt.ParallelMap(3, func(t *tzap.Tzap) *tzap.Tzap {
+		now := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			seen := atomic.LoadInt32(&maxRunning)
+			if now <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, now) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return t.AddUserMessage(t.Message.Content + " Mapped")
+	})
+
+	mappedChildren := mapped.Data["children"].([]*tzap.Tzap)
+	if len(mappedChildren) != len(expected) {
+		t.Fatalf("Expected %d children, but got %d", len(expected), len(mappedChildren))
+	}
+	for i, child := range mappedChildren {
+		if child.Message.Content != expected[i] {
+			t.Errorf("Expected content to be '%s', but got '%s'", expected[i], child.Message.Content)
+		}
+	}
+	if maxRunning > 3 {
+		t.Errorf("Expected at most 3 concurrent workers, but got %d", maxRunning)
+	}
+}
+
+// ctxMockTG answers like mockTG but fails requests whose context is done.
+type ctxMockTG struct {
+	mockTG
+}
+
+func (tg *ctxMockTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return tg.mockTG.GenerateChat(ctx, messages, stream)
+}
+
+func Test_ParallelMap_givenMappedChildren_expectChainingRequests(t *testing.T) {
+	tt := tzap.InternalNew()
+	tt.TG = &ctxMockTG{}
+	tt.Data["children"] = []*tzap.Tzap{tt.AddUserMessage("Child0"), tt.AddUserMessage("Child1"), tt.AddUserMessage("Child2")}
+
+	err := tzap.HandlePanic(func() {
+		mapped := tt.ParallelMap(2, func(t *tzap.Tzap) *tzap.Tzap {
+			return t.AddUserMessage("First").RequestChatCompletion()
+		})
+		for _, child := range mapped.Data["children"].([]*tzap.Tzap) {
+			if err := child.C.Err(); err != nil {
+				t.Errorf("Expected the context of a mapped child to outlive the parallel run, got %v", err)
+			}
+		}
+		mapped.Map(func(t *tzap.Tzap) *tzap.Tzap {
+			return t.AddUserMessage("Second").RequestChatCompletion()
+		})
+	})
+	if err != nil {
+		t.Errorf("Expected chaining requests after ParallelMap to succeed, got %v", err)
+	}
+}
+
+func Test_ParallelEach_givenFailingChild_expectPanicAndCancelledSiblings(t *testing.T) {
+	tt := tzap.InternalNew()
+	var children []*tzap.Tzap
+	for i := 0; i < 10; i++ {
+		children = append(children, tzap.InternalNew().AddUserMessage(fmt.Sprintf("Child%d", i)))
+	}
+	tt.Data["children"] = children
+
+	var started int32
+	err := tzap.HandlePanic(func() {
+		tt.ParallelEach(2, func(t *tzap.Tzap) {
+			atomic.AddInt32(&started, 1)
+			if t.Message.Content == "Child0" {
+				panic(fmt.Errorf("MOCK ERROR"))
+			}
+			<-t.C.Done()
+		})
+	})
+
+	if err == nil || !strings.HasSuffix(err.Error(), "MOCK ERROR") {
+		t.Errorf("Expected err to end with 'MOCK ERROR', but got '%v'", err)
+	}
+	if started == int32(len(children)) {
+		t.Errorf("Expected remaining children to be skipped after the first error")
+	}
+}
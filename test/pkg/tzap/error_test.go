@@ -1,6 +1,7 @@
 package tzap_test
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"testing"
@@ -40,3 +41,172 @@ func Test_ErrorTzap_ErrorNotHandled_ReturnsError(t *testing.T) {
 		t.Errorf("Expected err to be 'MOCK ERROR', but got '%s'", err.Error())
 	}
 }
+
+type failingChatTG struct {
+	mockTG
+}
+
+func (tg *failingChatTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	return "", fmt.Errorf("MOCK NETWORK ERROR")
+}
+
+func Test_WithErrorMode_RequestFails_ChainCarriesError(t *testing.T) {
+	// Given
+	tzapObj := tzap.InternalNew()
+	tzapObj.TG = &failingChatTG{}
+	mutated := false
+
+	// When
+	result := tzapObj.
+		WithErrorMode().
+		AddUserMessage("Hello!").
+		RequestChatCompletion().
+		AddSystemMessage("Not added").
+		MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
+			mutated = true
+			return t
+		})
+
+	// Expect
+	if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK NETWORK ERROR") {
+		t.Errorf("Expected err to end with 'MOCK NETWORK ERROR', but got '%v'", result.Err())
+	}
+	if mutated {
+		t.Errorf("Expected MutationTzap to be skipped after the error")
+	}
+	if result.Message.Content == "Not added" {
+		t.Errorf("Expected AddSystemMessage to be skipped after the error")
+	}
+	err := tzap.HandlePanic(func() { result.Must() })
+	if err == nil {
+		t.Errorf("Expected Must to panic with the sticky error")
+	}
+}
+
+func Test_WithErrorMode_ErrorWorkflow_FoldsIntoStickyError(t *testing.T) {
+	// Given
+	tzapObj := tzap.InternalNew().WithErrorMode()
+	workflow := types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
+		Name: "Failing",
+		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
+			return t.ErrorTzap(fmt.Errorf("MOCK ERROR"))
+		},
+	}
+
+	// When
+	result := tzapObj.ApplyErrorWorkflow(workflow, func(et *tzap.ErrorTzap) error {
+		return et.Err
+	})
+
+	// Expect
+	if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK ERROR") {
+		t.Errorf("Expected err to end with 'MOCK ERROR', but got '%v'", result.Err())
+	}
+	if tzapObj.Err() != nil {
+		t.Errorf("Expected the tzap before the failure to have no error, but got '%v'", tzapObj.Err())
+	}
+}
+
+func Test_WithErrorMode_WorkflowPanics_PanicRecoveredAsError(t *testing.T) {
+	// Given
+	tzapObj := tzap.InternalNew().WithErrorMode()
+
+	// When
+	result := tzapObj.ApplyWorkflowFN(func(t *tzap.Tzap) *tzap.Tzap {
+		panic("MOCK PANIC")
+	})
+
+	// Expect
+	if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK PANIC") {
+		t.Errorf("Expected err to end with 'MOCK PANIC', but got '%v'", result.Err())
+	}
+}
+
+func newErrorModeChildren(contents ...string) *tzap.Tzap {
+	tt := tzap.InternalNew().WithErrorMode()
+	var children []*tzap.Tzap
+	for _, content := range contents {
+		children = append(children, tt.AddUserMessage(content))
+	}
+	tt.Data = types.MappedInterface{"children": children}
+	return tt
+}
+
+func Test_WithErrorMode_ControlFlowPanics_PanicRecoveredAsError(t *testing.T) {
+	for name, apply := range map[string]func(*tzap.Tzap) *tzap.Tzap{
+		"Map": func(tt *tzap.Tzap) *tzap.Tzap {
+			return tt.Map(func(t *tzap.Tzap) *tzap.Tzap { panic("MOCK PANIC") })
+		},
+		"Reduce": func(tt *tzap.Tzap) *tzap.Tzap {
+			return tt.Reduce(func(acc *tzap.Tzap, t *tzap.Tzap) *tzap.Tzap { panic("MOCK PANIC") })
+		},
+		"Accumulate": func(tt *tzap.Tzap) *tzap.Tzap {
+			return tt.Accumulate(func(t *tzap.Tzap) *tzap.Tzap { panic("MOCK PANIC") })
+		},
+		"Each": func(tt *tzap.Tzap) *tzap.Tzap {
+			return tt.Each(func(t *tzap.Tzap) { panic("MOCK PANIC") })
+		},
+	} {
+		var result *tzap.Tzap
+		if err := tzap.HandlePanic(func() { result = apply(newErrorModeChildren("a", "b")) }); err != nil {
+			t.Errorf("%s: expected the panic to be recovered, got %v", name, err)
+			continue
+		}
+		if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK PANIC") {
+			t.Errorf("%s: expected err to end with 'MOCK PANIC', but got '%v'", name, result.Err())
+		}
+	}
+}
+
+func Test_WithErrorMode_AccumulateAndReduceFail_StopAtFirstError(t *testing.T) {
+	accumulated := []string{}
+	result := newErrorModeChildren("a", "fail", "c").Accumulate(func(t *tzap.Tzap) *tzap.Tzap {
+		accumulated = append(accumulated, t.Message.Content)
+		if t.Message.Content == "fail" {
+			return t.Fail(fmt.Errorf("MOCK ERROR"))
+		}
+		return t
+	})
+	if result.Err() == nil || strings.Join(accumulated, ",") != "a,fail" {
+		t.Errorf("Expected Accumulate to stop at the failing child, got %v and '%v'", accumulated, result.Err())
+	}
+
+	reduced := []string{}
+	result = newErrorModeChildren("a", "fail", "c").Reduce(func(acc *tzap.Tzap, t *tzap.Tzap) *tzap.Tzap {
+		reduced = append(reduced, t.Message.Content)
+		if t.Message.Content == "fail" {
+			return acc.Fail(fmt.Errorf("MOCK ERROR"))
+		}
+		return acc
+	})
+	if result.Err() == nil || strings.Join(reduced, ",") != "a,fail" {
+		t.Errorf("Expected Reduce to stop at the failing child, got %v and '%v'", reduced, result.Err())
+	}
+}
+
+func Test_WithErrorMode_ErrorWorkflowFails_StickyErrorKept(t *testing.T) {
+	handled := false
+	result := tzap.InternalNew().WithErrorMode().ApplyErrorWorkflow(types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
+		Name: "Failing",
+		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
+			return t.Fail(fmt.Errorf("MOCK ERROR")).ErrorTzap(nil)
+		},
+	}, func(et *tzap.ErrorTzap) error {
+		handled = true
+		return nil
+	})
+	if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK ERROR") {
+		t.Errorf("Expected the sticky error of the workflow, but got '%v'", result.Err())
+	}
+	if handled {
+		t.Errorf("Expected fn to be skipped for a workflow carrying a sticky error")
+	}
+
+	result = tzap.InternalNew().WithErrorMode().ApplyErrorWorkflow(types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
+		Name:     "Panicking",
+		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap { panic("MOCK PANIC") },
+	}, func(et *tzap.ErrorTzap) error { return et.Err })
+	if result.Err() == nil || !strings.HasSuffix(result.Err().Error(), "MOCK PANIC") {
+		t.Errorf("Expected the panic recovered as error, but got '%v'", result.Err())
+	}
+}
@@ -45,13 +45,13 @@ func (tg *mockTG) RawTokens(ctx context.Context, content string) ([]string, erro
 	// Return pre-defined value for testing purposes
 	return []string{}, nil
 }
-func (tg *mockTG) FetchEmbedding(ctx context.Context, content ...string) ([][1536]float32, error) {
-	return [][1536]float32{}, nil
+func (tg *mockTG) FetchEmbedding(ctx context.Context, content ...string) ([][]float32, error) {
+	return [][]float32{}, nil
 }
 func (tg *mockTG) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
 	return types.SearchResults{}, nil
 }
-func (tg *mockTG) AddEmbeddingDocument(ctx context.Context, docID string, embedding [1536]float32, metadata types.Metadata) error {
+func (tg *mockTG) AddEmbeddingDocument(ctx context.Context, docID string, embedding []float32, metadata types.Metadata) error {
 	return nil
 }
 func (tg *mockTG) GetEmbeddingDocument(ctx context.Context, docID string) (types.Vector, bool, error) {
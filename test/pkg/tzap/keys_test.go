@@ -0,0 +1,50 @@
+package tzap_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+func Test_Key_SetThenGet_ReturnsTypedValue(t *testing.T) {
+	tt := tzap.InternalNew()
+	countKey := tzap.Key[int]("count")
+
+	countKey.Set(tt, 3)
+	count, err := countKey.Get(tt)
+
+	if err != nil {
+		t.Fatalf("Expected no error, but got '%v'", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected count to be 3, but got %d", count)
+	}
+}
+
+func Test_Key_Missing_ErrorNamesTzapPath(t *testing.T) {
+	tt := tzap.InternalNew().AddUserMessage("Hello")
+
+	_, err := tzap.ContentKey.Get(tt)
+
+	if err == nil {
+		t.Fatalf("Expected an error for a missing key")
+	}
+	if !strings.Contains(err.Error(), "ConnectionLess-AddUserMessage") || !strings.Contains(err.Error(), `"content"`) {
+		t.Errorf("Expected error to name the tzap path and key, but got '%s'", err.Error())
+	}
+}
+
+func Test_Key_WrongType_ErrorNamesTypes(t *testing.T) {
+	tt := tzap.InternalNew()
+	tt.Data["content"] = 42
+
+	_, err := tzap.ContentKey.Get(tt)
+
+	if err == nil || !strings.Contains(err.Error(), "holds int, expected string") {
+		t.Errorf("Expected a type mismatch error, but got '%v'", err)
+	}
+	if _, ok := tzap.ContentKey.Lookup(tt); ok {
+		t.Errorf("Expected Lookup to report a type mismatch as missing")
+	}
+}
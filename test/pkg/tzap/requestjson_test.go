@@ -0,0 +1,112 @@
+package tzap_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+type jsonRelease struct {
+	Title string   `json:"title"`
+	Notes string   `json:"notes" description:"markdown notes"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// scriptedChatTG answers with answers in turn and records the requests it received.
+type scriptedChatTG struct {
+	mockTG
+	answers  []string
+	requests [][]types.Message
+}
+
+func (tg *scriptedChatTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	tg.requests = append(tg.requests, messages)
+	if len(tg.requests) > len(tg.answers) {
+		return "", errors.New("no more answers")
+	}
+	return tg.answers[len(tg.requests)-1], nil
+}
+
+// jsonModeTG records the schema it was asked to answer with.
+type jsonModeTG struct {
+	scriptedChatTG
+	schema json.RawMessage
+}
+
+func (tg *jsonModeTG) GenerateChatJSON(ctx context.Context, messages []types.Message, schema json.RawMessage, stream bool) (string, error) {
+	tg.schema = schema
+	return tg.GenerateChat(ctx, messages, stream)
+}
+
+func newJSONTzap(tg types.TGenerator) *tzap.Tzap {
+	return tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{AutoMode: true}
+	})
+}
+
+func Test_JSONSchemaOf_givenStruct_expectTaggedProperties(t *testing.T) {
+	schema := tzap.JSONSchemaOf(reflect.TypeOf(jsonRelease{}))
+	data, _ := json.Marshal(schema)
+	expected := `{"properties":{"notes":{"description":"markdown notes","type":"string"},"tags":{"items":{"type":"string"},"type":"array"},"title":{"type":"string"}},"required":["title","notes"],"type":"object"}`
+	if string(data) != expected {
+		t.Errorf("expected schema %s, got %s", expected, data)
+	}
+}
+
+func Test_RequestJSON_givenInvalidAnswers_expectRepromptWithError(t *testing.T) {
+	tg := &scriptedChatTG{answers: []string{
+		"not json",
+		`{"title": "v1"}`,
+		"```json\n{\"title\": \"v1\", \"notes\": \"* fix\"}\n```",
+	}}
+	release, answer, err := tzap.RequestJSON(newJSONTzap(tg).AddUserMessage("release"), tzap.JSONOptions[jsonRelease]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if release.Title != "v1" || release.Notes != "* fix" {
+		t.Errorf("unexpected release %+v", release)
+	}
+	if !strings.HasPrefix(tzap.ContentKey.Must(answer), "```json") {
+		t.Errorf("expected the raw answer under the content key")
+	}
+	if len(tg.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(tg.requests))
+	}
+	last := tg.requests[2][len(tg.requests[2])-1]
+	if !strings.Contains(last.Content, `required field "notes" is missing`) {
+		t.Errorf("expected the validation error to be sent back, got %q", last.Content)
+	}
+	if system := tg.requests[0][1]; !strings.Contains(system.Content, `"required":["title","notes"]`) {
+		t.Errorf("expected the schema in the prompt, got %q", system.Content)
+	}
+}
+
+func Test_RequestJSON_givenValidateAlwaysFails_expectErrorAfterRetries(t *testing.T) {
+	tg := &scriptedChatTG{answers: []string{`{"title":"a","notes":"b"}`, `{"title":"a","notes":"b"}`}}
+	_, _, err := tzap.RequestJSON(newJSONTzap(tg).AddUserMessage("release"), tzap.JSONOptions[jsonRelease]{
+		Retries: 1,
+		Validate: func(r jsonRelease) error {
+			return errors.New("title is too short")
+		},
+	})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts: title is too short") {
+		t.Fatalf("expected a validation error after 2 attempts, got %v", err)
+	}
+}
+
+func Test_RequestJSON_givenJSONModeConnector_expectSchemaPassed(t *testing.T) {
+	tg := &jsonModeTG{scriptedChatTG: scriptedChatTG{answers: []string{`{"title":"a","notes":"b"}`}}}
+	if _, _, err := tzap.RequestJSON(newJSONTzap(tg).AddUserMessage("release"), tzap.JSONOptions[jsonRelease]{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(tg.schema), `"title"`) {
+		t.Errorf("expected the schema to be passed to the connector, got %s", tg.schema)
+	}
+}
@@ -0,0 +1,77 @@
+package tzap_test
+
+import (
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+func sessionConnector() (types.TGenerator, config.Configuration) {
+	return &mockTG{}, config.Configuration{AutoMode: true}
+}
+
+func newSessionTzap() *tzap.Tzap {
+	return tzap.NewSession().NewWithConnector(sessionConnector)
+}
+
+func Test_NewWithConnector_givenTwoChains_expectDefaultSessionShared(t *testing.T) {
+	first := tzap.NewWithConnector(sessionConnector)
+	second := tzap.NewWithConnector(sessionConnector)
+	if first.Session != tzap.DefaultSession || second.Session != tzap.DefaultSession {
+		t.Fatal("expected NewWithConnector to start chains in the default session")
+	}
+
+	first.Memory("user", "defaultShared").AddUserMessage("first").Memorize("defaultShared")
+	if got := tzap.GetMemory("defaultShared"); got != "first" {
+		t.Errorf("expected the package-level GetMemory to see the memory of the chain, got %q", got)
+	}
+	if got := tzap.Mem["defaultShared"]; got == nil || got.Content != "first" {
+		t.Errorf("expected the deprecated Mem to hold the memory of the chain, got %v", got)
+	}
+	tzaps := tzap.DefaultSession.Tzaps()
+	if len(tzap.GlobalTzaps) != len(tzaps) || tzap.GlobalTzaps[len(tzaps)-1] != tzaps[len(tzaps)-1] {
+		t.Errorf("expected the deprecated GlobalTzaps to list the tzaps of the default session")
+	}
+}
+
+func Test_SessionNewWithConnector_givenTwoChains_expectIsolatedSessions(t *testing.T) {
+	first := newSessionTzap()
+	second := newSessionTzap()
+	if first.Session == second.Session {
+		t.Fatal("expected each chain to run in its own session")
+	}
+	if first.Id != 1 || second.Id != 1 {
+		t.Errorf("expected ids to start at 1 per session, got %d and %d", first.Id, second.Id)
+	}
+
+	first.Memory("user", "shared").AddUserMessage("first").Memorize("shared")
+	second.Memory("user", "shared").AddUserMessage("second").Memorize("shared")
+
+	if got := first.Session.GetMemory("shared"); got != "first" {
+		t.Errorf("expected first session memory 'first', got %q", got)
+	}
+	if got := second.Session.GetMemory("shared"); got != "second" {
+		t.Errorf("expected second session memory 'second', got %q", got)
+	}
+}
+
+func Test_AddTzap_givenSessionTzap_expectChildrenInheritSession(t *testing.T) {
+	root := newSessionTzap()
+	child := root.AddUserMessage("hello")
+	clone := child.CloneTzap(&tzap.Tzap{Name: "Clone"})
+	hijack := clone.HijackTzap(&tzap.Tzap{Name: "Hijack"})
+
+	for _, tz := range []*tzap.Tzap{child, clone, hijack, root.CopyConnection()} {
+		if tz.Session != root.Session {
+			t.Errorf("expected %s to inherit the root session", tz.Name)
+		}
+	}
+	if len(root.Session.Tzaps()) != 5 {
+		t.Errorf("expected 5 tzaps in the session, got %d", len(root.Session.Tzaps()))
+	}
+	if graph := root.Session.FillGraphVizGraph(); len(graph.Nodes) != 5 {
+		t.Errorf("expected 5 graph nodes, got %d", len(graph.Nodes))
+	}
+}
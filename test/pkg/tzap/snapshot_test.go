@@ -0,0 +1,151 @@
+package tzap_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+func mockConnector() (types.TGenerator, config.Configuration) {
+	return &mockTG{}, config.Configuration{AutoMode: true}
+}
+
+func Test_Snapshot_givenMappedTree_expectRestoredTree(t *testing.T) {
+	root := tzap.NewWithConnector(mockConnector).
+		AddSystemMessage("system").
+		Memory("user", "notes")
+	root.Session.Mem["notes"].Content = "remembered"
+
+	parent := root.AddUserMessage("parent")
+	tzap.ContentKey.Set(parent, "parent content")
+	parent.Data["count"] = 3
+	tzap.ChildrenKey.Set(parent, []*tzap.Tzap{
+		root.AddUserMessage("first"),
+		root.AddUserMessage("second"),
+	})
+	head := parent.Map(func(child *tzap.Tzap) *tzap.Tzap {
+		return child.AddAssistantMessage("mapped " + child.Message.Content)
+	})
+
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := tzap.Snapshot(head, file); err != nil {
+		t.Fatal(err)
+	}
+	restored, err := tzap.Restore(file, mockConnector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := tzap.GetNames(restored), tzap.GetNames(head); strings.Join(got, "-") != strings.Join(want, "-") {
+		t.Errorf("expected names %v, got %v", want, got)
+	}
+	if restored.Session == head.Session {
+		t.Error("expected the restored tree to live in a new session")
+	}
+	if got := restored.Session.GetMemory("notes"); got != "remembered" {
+		t.Errorf("expected memory 'remembered', got %q", got)
+	}
+
+	children := tzap.ChildrenKey.Must(restored)
+	if len(children) != 2 {
+		t.Fatalf("expected 2 mapped children, got %d", len(children))
+	}
+	for i, want := range []string{"mapped first", "mapped second"} {
+		if children[i].Message.Content != want {
+			t.Errorf("expected child %d content %q, got %q", i, want, children[i].Message.Content)
+		}
+		if children[i].Parent.Message.Content != strings.TrimPrefix(want, "mapped ") {
+			t.Errorf("expected child %d to keep its parent", i)
+		}
+	}
+
+	restoredParent := restored.Parent
+	if got := tzap.ContentKey.Must(restoredParent); got != "parent content" {
+		t.Errorf("expected content 'parent content', got %q", got)
+	}
+	if got := restoredParent.Data["count"]; got != 3 {
+		t.Errorf("expected count 3, got %v", got)
+	}
+	thread := restored.GetThread()
+	if len(thread) == 0 || thread[0].Role != "system" {
+		t.Errorf("expected the system message to be restored, got %v", thread)
+	}
+
+	continued := tzap.ContentKey.Must(restored.AddUserMessage("continue").RequestChatCompletion())
+	if !strings.HasPrefix(continued, "r=system;c=system") {
+		t.Errorf("expected restored chain to continue with the connector, got %q", continued)
+	}
+}
+
+func Test_Snapshot_givenUnregisteredData_expectSkippedAndRecorded(t *testing.T) {
+	tt := tzap.NewWithConnector(mockConnector).AddUserMessage("hello")
+	tzap.Key[struct{ A int }]("unknown").Set(tt, struct{ A int }{A: 1})
+	tzap.ContentKey.Set(tt, "kept")
+
+	data, err := tzap.MarshalSnapshot(tt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"unknown": "struct { A int }"`) {
+		t.Errorf("expected the skipped key recorded, got %s", data)
+	}
+	restored, err := tzap.UnmarshalSnapshot(data, mockConnector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restored.Data["unknown"]; ok {
+		t.Error("expected the skipped key to be left out")
+	}
+	if got := tzap.ContentKey.Must(restored); got != "kept" {
+		t.Errorf("expected content 'kept', got %q", got)
+	}
+}
+
+func Test_Snapshot_givenChildrenOfOtherSessions_expectEveryTzapKept(t *testing.T) {
+	parent := tzap.NewSession().NewWithConnector(mockConnector).AddUserMessage("parent")
+	other := tzap.NewSession().NewWithConnector(mockConnector)
+	// The tzaps of both sessions share ids.
+	tzap.ChildrenKey.Set(parent, []*tzap.Tzap{
+		other.AddUserMessage("first"),
+		other.AddUserMessage("second"),
+	})
+
+	data, err := tzap.MarshalSnapshot(parent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := tzap.UnmarshalSnapshot(data, mockConnector)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.Message.Content != "parent" || restored.Parent == nil || restored.Parent.Parent != nil {
+		t.Errorf("expected the parent chain restored, got %v", tzap.GetNames(restored))
+	}
+	children := tzap.ChildrenKey.Must(restored)
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children, got %d", len(children))
+	}
+	for i, want := range []string{"first", "second"} {
+		if children[i].Message.Content != want {
+			t.Errorf("expected child %d content %q, got %q", i, want, children[i].Message.Content)
+		}
+		if children[i].Parent == restored.Parent {
+			t.Errorf("expected child %d to keep the root of its own session", i)
+		}
+	}
+}
+
+func Test_Restore_givenOtherVersion_expectError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := os.WriteFile(file, []byte(`{"version": 99, "head": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tzap.Restore(file, mockConnector); err == nil {
+		t.Fatal("expected an unsupported version error")
+	}
+}
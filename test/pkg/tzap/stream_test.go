@@ -0,0 +1,78 @@
+package tzap_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// streamMockTG streams deltas one by one and stops at the first callback error.
+type streamMockTG struct {
+	mockTG
+	deltas []string
+	sent   int
+}
+
+func (tg *streamMockTG) GenerateChatStream(ctx context.Context, messages []types.Message, onDelta func(delta string) error) (string, error) {
+	for _, delta := range tg.deltas {
+		tg.sent++
+		if err := onDelta(delta); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(tg.deltas, ""), nil
+}
+
+// newStreamTzap starts a chain in a session of its own, so that tests reading the usage of the session only see their own calls.
+func newStreamTzap(tg types.TGenerator) *tzap.Tzap {
+	return tzap.NewSession().NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{AutoMode: true}
+	})
+}
+
+func Test_RequestChatCompletionStream_givenStreamingConnector_expectDeltas(t *testing.T) {
+	tg := &streamMockTG{deltas: []string{"Hel", "lo", " world"}}
+	var received []string
+	requested := newStreamTzap(tg).AddUserMessage("hi").RequestChatCompletionStream(func(delta string) error {
+		received = append(received, delta)
+		return nil
+	})
+
+	if strings.Join(received, "|") != "Hel|lo| world" {
+		t.Errorf("expected three deltas, got %v", received)
+	}
+	if got := tzap.ContentKey.Must(requested); got != "Hello world" {
+		t.Errorf("expected content 'Hello world', got %q", got)
+	}
+}
+
+func Test_RequestChatCompletionStream_givenCallbackError_expectCancelledAndFailed(t *testing.T) {
+	tg := &streamMockTG{deltas: []string{"a", "b", "c"}}
+	stop := errors.New("client went away")
+	requested := newStreamTzap(tg).WithErrorMode().AddUserMessage("hi").RequestChatCompletionStream(func(delta string) error {
+		return stop
+	})
+
+	if !errors.Is(requested.Err(), stop) {
+		t.Fatalf("expected the callback error, got %v", requested.Err())
+	}
+	if tg.sent != 1 {
+		t.Errorf("expected the stream to stop after the first delta, got %d", tg.sent)
+	}
+}
+
+func Test_RequestChatCompletionStream_givenNonStreamingConnector_expectSingleDelta(t *testing.T) {
+	var received []string
+	newStreamTzap(&mockTG{}).AddUserMessage("hi").RequestChatCompletionStream(func(delta string) error {
+		received = append(received, delta)
+		return nil
+	})
+	if len(received) != 1 || received[0] != "r=user;c=hi" {
+		t.Errorf("expected the whole answer as one delta, got %v", received)
+	}
+}
@@ -0,0 +1,110 @@
+package tzap_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// toolMockTG calls each tool in calls in turn, then answers with the tool results it received.
+type toolMockTG struct {
+	mockTG
+	calls    []types.ToolCall
+	requests [][]types.Message
+}
+
+func (tg *toolMockTG) GenerateChatWithTools(ctx context.Context, messages []types.Message, tools []types.ToolDefinition, stream bool) (types.ChatResponse, error) {
+	tg.requests = append(tg.requests, messages)
+	if len(tg.requests) <= len(tg.calls) {
+		call := tg.calls[len(tg.requests)-1]
+		return types.ChatResponse{ToolCall: &call}, nil
+	}
+	var results []string
+	for _, message := range messages {
+		if message.Role == openai.ChatMessageRoleFunction {
+			results = append(results, message.Name+"="+message.Content)
+		}
+	}
+	return types.ChatResponse{Content: strings.Join(results, ",")}, nil
+}
+
+func newToolTzap(tg types.TGenerator, maxIterations int) *tzap.Tzap {
+	return tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{AutoMode: true, MaxToolIterations: maxIterations}
+	})
+}
+
+func addTool(t *tzap.Tzap) *tzap.Tzap {
+	return t.WithTool("add", `{"type":"object","properties":{"a":{"type":"integer"},"b":{"type":"integer"}}}`,
+		func(ctx context.Context, arguments string) (string, error) {
+			var args struct{ A, B int }
+			if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+				return "", err
+			}
+			return string(rune('0' + args.A + args.B)), nil
+		})
+}
+
+func Test_RequestChatCompletion_givenToolCalls_expectResultsSentBack(t *testing.T) {
+	tg := &toolMockTG{calls: []types.ToolCall{
+		{Name: "add", Arguments: `{"a":1,"b":2}`},
+		{Name: "missing", Arguments: `{}`},
+	}}
+	requested := addTool(newToolTzap(tg, 0)).AddUserMessage("add 1 and 2").RequestChatCompletion()
+
+	if got := tzap.ContentKey.Must(requested); got != `add=3,missing=error: there is no tool named "missing"` {
+		t.Errorf("unexpected answer %q", got)
+	}
+	if len(tg.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(tg.requests))
+	}
+	second := tg.requests[1]
+	call := second[len(second)-2]
+	if call.Role != openai.ChatMessageRoleAssistant || call.ToolCall == nil || call.ToolCall.Name != "add" {
+		t.Errorf("expected the tool call to be sent back, got %+v", call)
+	}
+}
+
+func Test_RequestChatCompletion_givenEndlessToolCalls_expectMaxIterationsError(t *testing.T) {
+	tg := &toolMockTG{}
+	for i := 0; i < 5; i++ {
+		tg.calls = append(tg.calls, types.ToolCall{Name: "add", Arguments: `{"a":1,"b":1}`})
+	}
+	requested := addTool(newToolTzap(tg, 2)).WithErrorMode().AddUserMessage("loop").RequestChatCompletion()
+
+	if err := requested.Err(); err == nil || !strings.Contains(err.Error(), "no answer after 2 tool calls") {
+		t.Fatalf("expected a max iterations error, got %v", err)
+	}
+	if len(tg.requests) != 3 {
+		t.Errorf("expected 3 requests, got %d", len(tg.requests))
+	}
+}
+
+func Test_RequestChatCompletion_givenFailingTool_expectError(t *testing.T) {
+	tg := &toolMockTG{calls: []types.ToolCall{{Name: "fail", Arguments: `{}`}}}
+	requested := newToolTzap(tg, 0).
+		WithTool("fail", `{"type":"object"}`, func(ctx context.Context, arguments string) (string, error) {
+			return "", errors.New("broken")
+		}).
+		WithErrorMode().
+		AddUserMessage("fail").
+		RequestChatCompletion()
+
+	if err := requested.Err(); err == nil || !strings.Contains(err.Error(), "tool fail: broken") {
+		t.Fatalf("expected the tool error, got %v", err)
+	}
+}
+
+func Test_RequestChatCompletion_givenToolsWithoutSupport_expectError(t *testing.T) {
+	requested := addTool(newToolTzap(&mockTG{}, 0)).WithErrorMode().AddUserMessage("hi").RequestChatCompletion()
+	if err := requested.Err(); err == nil || !strings.Contains(err.Error(), "does not support tool calling") {
+		t.Fatalf("expected an unsupported connector error, got %v", err)
+	}
+}
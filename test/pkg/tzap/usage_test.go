@@ -0,0 +1,106 @@
+package tzap_test
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/types/openai"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// usageMockTG reports 1000 prompt and 500 completion tokens of gpt-4 for every chat.
+type usageMockTG struct {
+	mockTG
+}
+
+func (tg *usageMockTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	types.ReportUsage(ctx, types.NewUsage("GenerateChat", openai.GPT4, 1000, 500))
+	return "answer", nil
+}
+
+func Test_Usage_givenWorkflow_expectAttributedAndAggregated(t *testing.T) {
+	root := newStreamTzap(&usageMockTG{})
+	var asked *tzap.Tzap
+	result := root.ApplyWorkflow(types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "ask",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			asked = t.AddUserMessage("question")
+			return asked.RequestChatCompletion()
+		},
+	})
+	result.AddUserMessage("follow up").RequestChatCompletion()
+
+	if usage := asked.Usage(); len(usage) != 1 || usage[0].PromptTokens != 1000 {
+		t.Errorf("expected the usage on the issuing tzap, got %+v", usage)
+	}
+	// gpt-4 costs $0.03 per 1000 prompt and $0.06 per 1000 completion tokens.
+	if cost := asked.Usage()[0].Cost; math.Abs(cost-0.06) > 1e-9 {
+		t.Errorf("expected cost 0.06, got %f", cost)
+	}
+
+	byWorkflow := root.Session.UsageByWorkflow()
+	if byWorkflow["ask"].Calls != 1 || byWorkflow[""].Calls != 1 {
+		t.Errorf("expected one call inside and one outside the workflow, got %+v", byWorkflow)
+	}
+	if summary := root.Session.UsageSummary(); !strings.Contains(summary, "Usage: 2 calls, 2000 prompt + 1000 completion tokens, $0.1200") {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func askQuestion(t *tzap.Tzap) *tzap.Tzap {
+	return t.AddUserMessage("question").RequestChatCompletion()
+}
+
+func Test_Usage_givenWorkflowFNAndErrorWorkflow_expectAttributed(t *testing.T) {
+	root := newStreamTzap(&usageMockTG{})
+	result := root.ApplyWorkflowFN(askQuestion).
+		ApplyErrorWorkflow(types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
+			Name: "check",
+			Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
+				return askQuestion(t).ErrorTzap(nil)
+			},
+		}, func(et *tzap.ErrorTzap) error { return et.Err })
+	if result.Err() != nil {
+		t.Fatal(result.Err())
+	}
+	result.AddUserMessage("follow up").RequestChatCompletion()
+
+	byWorkflow := root.Session.UsageByWorkflow()
+	if byWorkflow["tzap_test.askQuestion"].Calls != 1 || byWorkflow["check"].Calls != 1 || byWorkflow[""].Calls != 1 {
+		t.Errorf("expected one call in each workflow and one outside, got %+v", byWorkflow)
+	}
+}
+
+func Test_Usage_givenAppendUsage_expectJSONLinesOnce(t *testing.T) {
+	root := newStreamTzap(&usageMockTG{})
+	root.AddUserMessage("question").RequestChatCompletion()
+	path := filepath.Join(t.TempDir(), "usage.jsonl")
+
+	if err := root.Session.AppendUsage(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Session.AppendUsage(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %d", len(lines))
+	}
+	var record tzap.UsageRecord
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record.Model != openai.GPT4 || record.CompletionTokens != 500 || record.TzapId == 0 {
+		t.Errorf("unexpected record %+v", record)
+	}
+}
@@ -1,9 +1,9 @@
 package codegeneration
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
-	"strings"
 
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
@@ -11,9 +11,26 @@ import (
 )
 
 type CodeGeneration struct {
-	Code     string
-	FilePath string
-	Type     string
+	Code     string `json:"code"`
+	FilePath string `json:"filePath"`
+	Type     string `json:"type" description:"full code OR partial code"`
+}
+
+// CodeGenerations is the JSON answer of GenerateCodeAndApplyWorkflow.
+type CodeGenerations struct {
+	Files []CodeGeneration `json:"files"`
+}
+
+func validateCodeGenerations(generations CodeGenerations) error {
+	if len(generations.Files) == 0 {
+		return errors.New("files is empty, extract at least one file")
+	}
+	for i, file := range generations.Files {
+		if file.FilePath == "" {
+			return fmt.Errorf("files[%d].filePath is empty", i)
+		}
+	}
+	return nil
 }
 
 func GenerateCodeAndApplyWorkflow() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
@@ -24,55 +41,39 @@ func GenerateCodeAndApplyWorkflow() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]
 				WorkTzap(func(t *tzap.Tzap) {
 					// Load the file content
 					t.IsolatedTzap(func(ti *tzap.Tzap) {
-						codeContent, ok := t.Data["content"].(string)
-						if !ok {
-							panic("Could not extract content")
+						codeContent, err := tzap.ContentKey.Get(t)
+						if err != nil {
+							panic(err)
 						}
 						println("code:" + codeContent)
-						ti.
-							AddSystemMessage("You are rewriting code into JSON. It is not allowed to use newline in the json.",
-								`Template:
-- {"code":"{code}","filePath":"{filePath}", "type": "{full code OR partial code}"}
-`).
+						generations, extracted, err := tzap.RequestJSON(ti.
 							AddAssistantMessage(codeContent).
-							AddUserMessage("Extract as JSON").
-							RequestChatCompletion(). // Run the completion
-							WorkTzap(func(t *tzap.Tzap) {
-								completion, ok := t.Data["content"].(string)
-								if !ok {
-									panic("Could not extract content")
-								}
-								codeSegments := strings.Split(completion, "\n")
-
-								var jsonObjects []CodeGeneration
-								for _, codeSeg := range codeSegments {
-									obj := CodeGeneration{}
-									codeIndex := strings.Index(codeSeg, "{")
-									if codeIndex == -1 {
-										continue
-									}
-									if err := json.Unmarshal([]byte(codeSeg[codeIndex:]), &obj); err != nil {
-										continue
-									}
-									jsonObjects = append(jsonObjects, obj)
-								}
-								for _, jsonObject := range jsonObjects {
-									// Use the GPTAsFunction worfklow to transform the JSON object
-									if _, err := os.Stat(jsonObject.FilePath); os.IsNotExist(err) {
-										os.WriteFile(jsonObject.FilePath, []byte(jsonObject.Type), 0644)
-										return
-									}
-									oldFileContent, err := os.ReadFile(jsonObject.FilePath)
-									if err != nil {
-										panic(err)
-									}
-									sysPrompt := "Transfer the changes onto the user response. You are now editing:" +
-										jsonObject.FilePath + "\n\nchanges:\n" + jsonObject.Code
-									t.ApplyWorkflow(gptasfunction.GPTAsFunction(sysPrompt,
-										string(oldFileContent))).
-										StoreCompletion(jsonObject.FilePath)
-								}
+							AddUserMessage("Extract as JSON"),
+							tzap.JSONOptions[CodeGenerations]{
+								Instruction: "You are rewriting code into JSON.",
+								Validate:    validateCodeGenerations,
 							})
+						if err != nil {
+							panic(err)
+						}
+						for _, jsonObject := range generations.Files {
+							// Use the GPTAsFunction worfklow to transform the JSON object
+							if _, err := os.Stat(jsonObject.FilePath); os.IsNotExist(err) {
+								if err := os.WriteFile(jsonObject.FilePath, []byte(jsonObject.Code), 0644); err != nil {
+									panic(err)
+								}
+								continue
+							}
+							oldFileContent, err := os.ReadFile(jsonObject.FilePath)
+							if err != nil {
+								panic(err)
+							}
+							sysPrompt := "Transfer the changes onto the user response. You are now editing:" +
+								jsonObject.FilePath + "\n\nchanges:\n" + jsonObject.Code
+							extracted.ApplyWorkflow(gptasfunction.GPTAsFunction(sysPrompt,
+								string(oldFileContent))).
+								StoreCompletion(jsonObject.FilePath)
+						}
 					})
 				})
 
@@ -11,11 +11,18 @@ import (
 
 func MakeCodeExtReplacer(language, extensionIn, extensionOut, mission, task string) func(t *tzap.Tzap) *tzap.Tzap {
 	return func(t *tzap.Tzap) *tzap.Tzap {
-		filein := t.Data["filepath"].(string)
+		filein, err := tzap.FilepathKey.Get(t)
+		if err != nil {
+			return t.Fail(err)
+		}
 		if path.Ext(filein) != extensionIn {
 			return t
 		}
-		fileout := strings.TrimSuffix(t.Data["filepath"].(string), extensionIn) + extensionOut
+		content, err := tzap.ContentKey.Get(t)
+		if err != nil {
+			return t.Fail(err)
+		}
+		fileout := strings.TrimSuffix(filein, extensionIn) + extensionOut
 
 		return t.
 			HijackTzap(&tzap.Tzap{Name: "MakeCodeGO"}).
@@ -32,14 +39,14 @@ func MakeCodeExtReplacer(language, extensionIn, extensionOut, mission, task stri
 			AddSystemMessage(
 				"####",
 				"####file: "+filein+"\n",
-				t.Data["content"].(string),
+				content,
 			).
 			MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
 				if _, err := os.Stat(fileout); err == nil {
 					return t.AddSystemMessage(
 						"####",
 						"####file: "+fileout+"\n",
-						t.Data["content"].(string),
+						content,
 					)
 				}
 				return t
@@ -0,0 +1,37 @@
+package embedworkflows
+
+import (
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed/mmr"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// candidates returns how many results to fetch for a search keeping k: n when it is larger, so that diversify has
+// results to choose from, and all of them for k below 0.
+func candidates(k int, n int) int {
+	if k < 0 || n < 0 {
+		return -1
+	}
+	if n > k {
+		return n
+	}
+	return k
+}
+
+// diversify picks k of results with the Diversity and MaxChunksPerFile of the configuration, see mmr.Rerank.
+// When diversity is set, results without vectors, such as BM25 results, take them from the embedding index.
+func diversify(t *tzap.Tzap, results types.SearchResults, k int) types.SearchResults {
+	conf := config.FromContext(t.C)
+	if conf.Diversity > 0 {
+		for i, result := range results.Results {
+			if len(result.Vector.Values) > 0 {
+				continue
+			}
+			if vector, exists, err := t.TG.GetEmbeddingDocument(t.C, result.Vector.ID); err == nil && exists {
+				results.Results[i].Vector.Values = vector.Values
+			}
+		}
+	}
+	return mmr.Rerank(results, conf.Diversity, conf.MaxChunksPerFile, k)
+}
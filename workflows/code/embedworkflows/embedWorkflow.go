@@ -15,6 +15,7 @@ func EmbedWorkflow(searchResults types.SearchResults) types.NamedWorkflow[*tzap.
 				)
 				for _, result := range searchResults.Results {
 					t = t.AddSystemMessage(result.Vector.Metadata.SplitPart)
+					tzap.RelevanceKey.Set(t, result.Similarity)
 				}
 			}
 			return t
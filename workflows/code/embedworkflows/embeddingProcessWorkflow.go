@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/tzapio/tzap/pkg/config"
 	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
 	"github.com/tzapio/tzap/pkg/util/stdin"
@@ -16,10 +18,10 @@ func LoadAndFetchEmbeddings(files []types.FileReader, embedder *embed.Embedder,
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
 			return t.MutationTzap(func(t *tzap.Tzap) *tzap.Tzap {
 				return t.
-						ApplyWorkflow(PrepareEmbedFilesWorkflow(files, embedder)).
-						ApplyWorkflow(ConfirmEmbeddingSearch(yes)).
-						ApplyWorkflow(FetchOrCachedEmbeddingForFilesWorkflow(files)).
-						ApplyWorkflow(SaveAndLoadEmbeddingsToDB())
+					ApplyWorkflow(PrepareEmbedFilesWorkflow(files, embedder)).
+					ApplyWorkflow(ConfirmEmbeddingSearch(yes)).
+					ApplyWorkflow(FetchOrCachedEmbeddingForFilesWorkflow(files)).
+					ApplyWorkflow(SaveAndLoadEmbeddingsToDB())
 			})
 		},
 	}
@@ -29,18 +31,23 @@ func ConfirmEmbeddingSearch(yes bool) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "confirmEmbeddingSearch",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
-			uncachedEmbeddings, ok := t.Data["uncachedEmbeddings"].(*types.Embeddings)
-			if !ok {
-				panic("Loading embeddings went wrong")
+			uncachedEmbeddings, err := UncachedEmbeddingsKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
 			}
 			if len(uncachedEmbeddings.Vectors) > 19 {
-				price := float64(len(uncachedEmbeddings.Vectors)*400) * 0.0004 / 1000
+				tokens := len(uncachedEmbeddings.Vectors) * 400
+				model, err := models.Resolve(config.FromContext(t.C).EmbedModel, models.KindEmbedding)
+				if err != nil {
+					return t.Fail(err)
+				}
 				if !yes {
 					ok := stdin.ConfirmPrompt(fmt.Sprintf(
-						"Embeddings - You are about to fetch %d embeddings. Proceed? Estimation tokens: %d. Price is: $0.0004 per 1000 tokens. Estimating %.4f USD",
+						"Embeddings - You are about to fetch %d embeddings. Proceed? Estimation tokens: %d. Price is: $%.4f per 1000 tokens. Estimating %.4f USD",
 						len(uncachedEmbeddings.Vectors),
-						len(uncachedEmbeddings.Vectors)*400,
-						price))
+						tokens,
+						model.InputPrice,
+						model.Cost(tokens, 0)))
 					if !ok {
 						println("Fetching embeddings aborted by user")
 						os.Exit(0)
@@ -0,0 +1,23 @@
+package embedworkflows
+
+import (
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// Data keys set and read by the embedding workflows.
+var (
+	EmbedderKey           = tzap.Key[*embed.Embedder]("embedder")
+	RawFileEmbeddingsKey  = tzap.Key[*types.Embeddings]("rawFileEmbeddings")
+	UncachedEmbeddingsKey = tzap.Key[*types.Embeddings]("uncachedEmbeddings")
+	EmbeddingsKey         = tzap.Key[*types.Embeddings]("embeddings")
+	SearchResultsKey      = tzap.Key[types.SearchResults]("searchResults")
+	QueryResultKey        = tzap.Key[types.QueryRequest]("queryResult")
+)
+
+func init() {
+	tzap.RegisterSnapshotType[*types.Embeddings]("embeddings")
+	tzap.RegisterSnapshotType[types.SearchResults]("searchResults")
+	tzap.RegisterSnapshotType[types.QueryRequest]("queryRequest")
+}
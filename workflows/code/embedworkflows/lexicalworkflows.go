@@ -0,0 +1,102 @@
+package embedworkflows
+
+import (
+	"fmt"
+
+	"github.com/tzapio/tzap/internal/logging/tl"
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/embed"
+	"github.com/tzapio/tzap/pkg/embed/bm25"
+	"github.com/tzapio/tzap/pkg/embed/hybrid"
+	"github.com/tzapio/tzap/pkg/project"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// LoadLexicalIndex updates the BM25 index of the project with the files changed since it was last updated.
+// The chunks are the ones the embedding index is built from. It makes no model calls.
+// embedder must track the edit times of the lexical index, see project.Project.GetLexicalTimestampCache.
+func LoadLexicalIndex(files []types.FileReader, embedder *embed.Embedder) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "loadLexicalIndex",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			tl.Logger.Println("Preparing lexical index from files", len(files))
+			changedFileContents, unchangedFileTimestamps := embedder.CheckFileCache(files)
+			lexicalIndex := project.GetProjectFromContext(t.C).GetLexicalIndex()
+			var stored []types.Metadata
+			for _, document := range lexicalIndex.GetAll() {
+				stored = append(stored, document.Value.Metadata)
+			}
+			if err := embedder.CheckChunkers(t, files, stored, changedFileContents, unchangedFileTimestamps); err != nil {
+				return t.Fail(err)
+			}
+			chunks := embedder.PrepareEmbeddingsFromFiles(t, changedFileContents)
+			added, removed, err := bm25.Update(lexicalIndex, chunks.Vectors, unchangedFileTimestamps)
+			if err != nil {
+				return t.Fail(err)
+			}
+			tl.Logger.Println("Lexical index updated. Added", added, "removed", removed)
+			if err := embedder.CacheFilestamps(chunks, files); err != nil {
+				return t.Fail(err)
+			}
+			return t
+		},
+	}
+}
+
+// SearchLexicalWorkflow searches the chunks of the BM25 index of the project selected by filter for query. Like
+// SearchFilesWorkflow it keeps the top k of the n best results, and sets SearchResultsKey and QueryResultKey.
+func SearchLexicalWorkflow(query string, filter types.MetadataFilter, k int, n int) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "searchLexicalWorkflow",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			searchResults := bm25.Search(project.GetProjectFromContext(t.C).GetLexicalIndex(), query, filter, candidates(k, n))
+			searchResults = diversify(t, searchResults, k)
+
+			searched := t.AddTzap(&tzap.Tzap{Name: "searchResults", Data: types.MappedInterface{}})
+			QueryResultKey.Set(searched, types.QueryRequest{TopK: k, IncludeMetadata: true, Queries: []types.QueryFilter{{Filter: filter}}})
+			SearchResultsKey.Set(searched, searchResults)
+			return searched
+		},
+	}
+}
+
+// HybridSearchWorkflow searches the embedding index with query and the BM25 index with queryText, both restricted
+// to the chunks selected by the Filter of query, and fuses both rankings with the HybridWeight of the configuration.
+// Like SearchFilesWorkflow it keeps the top k of the fused results, and sets SearchResultsKey and QueryResultKey.
+// Each index contributes its n best results.
+func HybridSearchWorkflow(query types.QueryRequest, queryText string, k int, n int) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "hybridSearchWorkflow",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			if len(query.Queries) == 0 {
+				return t.Fail(fmt.Errorf("hybrid search needs a query embedding"))
+			}
+			vectorResults, err := searchQueries(t, query, n)
+			if err != nil {
+				return t.Fail(err)
+			}
+			lexicalResults := bm25.Search(project.GetProjectFromContext(t.C).GetLexicalIndex(), queryText, query.Queries[0].Filter, n)
+			fused := hybrid.Fuse(vectorResults, lexicalResults, config.FromContext(t.C).HybridWeight, -1)
+			fused = diversify(t, fused, k)
+
+			searched := t.AddTzap(&tzap.Tzap{Name: "searchResults", Data: types.MappedInterface{}})
+			QueryResultKey.Set(searched, query)
+			SearchResultsKey.Set(searched, fused)
+			return searched
+		},
+	}
+}
+
+// ResolveSearchBackend returns the search backend of the configuration of t. SearchBackendAuto resolves to
+// SearchBackendHybrid when the project of t has a BM25 index, and to SearchBackendEmbedding otherwise.
+func ResolveSearchBackend(t *tzap.Tzap) string {
+	backend := config.FromContext(t.C).SearchBackend
+	if backend != config.SearchBackendAuto {
+		return backend
+	}
+	if len(project.GetProjectFromContext(t.C).GetLexicalIndex().GetAll()) > 0 {
+		return config.SearchBackendHybrid
+	}
+	return config.SearchBackendEmbedding
+}
@@ -1,8 +1,6 @@
 package embedworkflows
 
 import (
-	"fmt"
-
 	"github.com/tzapio/tzap/internal/logging/tl"
 	"github.com/tzapio/tzap/pkg/embed"
 	"github.com/tzapio/tzap/pkg/types"
@@ -15,11 +13,25 @@ func PrepareEmbedFilesWorkflow(files []types.FileReader, embedder *embed.Embedde
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
 			tl.Logger.Println("Preparing embeddings from files", len(files))
 			changedFileContents, unchangedFileTimestamps := embedder.CheckFileCache(files)
+			stored, err := t.TG.ListAllEmbeddingsIds(t.C)
+			if err != nil {
+				return t.Fail(err)
+			}
+			storedMetadata := make([]types.Metadata, len(stored.Results))
+			for i, result := range stored.Results {
+				storedMetadata[i] = result.Vector.Metadata
+			}
+			if err := embedder.CheckChunkers(t, files, storedMetadata, changedFileContents, unchangedFileTimestamps); err != nil {
+				return t.Fail(err)
+			}
 			rawFileEmbeddings := embedder.PrepareEmbeddingsFromFiles(t, changedFileContents)
 			embedder.CleanOldEmbeddings(t, rawFileEmbeddings, unchangedFileTimestamps)
-			uncachedEmbeddings := embedder.GetUncachedEmbeddings(rawFileEmbeddings)
-			data := types.MappedInterface{"rawFileEmbeddings": rawFileEmbeddings, "uncachedEmbeddings": uncachedEmbeddings, "embedder": embedder}
-			return t.AddTzap(&tzap.Tzap{Name: "prepareEmbedFilesTzap", Data: data})
+			uncachedEmbeddings := embedder.GetUncachedEmbeddings(t, rawFileEmbeddings)
+			prepared := t.AddTzap(&tzap.Tzap{Name: "prepareEmbedFilesTzap", Data: types.MappedInterface{}})
+			RawFileEmbeddingsKey.Set(prepared, rawFileEmbeddings)
+			UncachedEmbeddingsKey.Set(prepared, uncachedEmbeddings)
+			EmbedderKey.Set(prepared, embedder)
+			return prepared
 		},
 	}
 }
@@ -28,37 +40,38 @@ func FetchOrCachedEmbeddingForFilesWorkflow(files []types.FileReader) types.Name
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "fetchOrCachedEmbeddingForFilesWorkflow",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
-			embedder, ok := t.Data["embedder"].(*embed.Embedder)
-			if !ok {
-				panic(fmt.Errorf("loading embedder went wrong"))
+			embedder, err := EmbedderKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
 			}
-			uncachedEmbeddings, ok := t.Data["uncachedEmbeddings"].(*types.Embeddings)
-			if !ok {
-				panic(fmt.Errorf("loading embeddings went wrong"))
+			uncachedEmbeddings, err := UncachedEmbeddingsKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
 			}
 			if len(uncachedEmbeddings.Vectors) > 0 {
 				if err := embedder.FetchThenCacheNewEmbeddings(t, files, uncachedEmbeddings); err != nil {
-					panic(err)
+					return t.Fail(err)
 				}
 				if err := embedder.CacheFilestamps(uncachedEmbeddings, files); err != nil {
-					panic(err)
+					return t.Fail(err)
 				}
 			}
-			rawFileEmbeddings, ok := t.Data["rawFileEmbeddings"].(*types.Embeddings)
-			if !ok {
-				panic("Loading embeddings went wrong")
+			rawFileEmbeddings, err := RawFileEmbeddingsKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
 			}
-			cachedEmbeddings, err := embedder.GetCachedEmbeddings(files, rawFileEmbeddings)
+			cachedEmbeddings, err := embedder.GetCachedEmbeddings(t, files, rawFileEmbeddings)
 			if err != nil {
-				panic(err)
+				return t.Fail(err)
 			}
 			if len(cachedEmbeddings.Vectors) == 0 {
 				if err := embedder.CacheFilestamps(cachedEmbeddings, files); err != nil {
-					panic(err)
+					return t.Fail(err)
 				}
 			}
-			data := types.MappedInterface{"embeddings": cachedEmbeddings}
-			return t.AddTzap(&tzap.Tzap{Name: "fetchOrCachedEmbeddingForFilesTzap", Data: data})
+			fetched := t.AddTzap(&tzap.Tzap{Name: "fetchOrCachedEmbeddingForFilesTzap", Data: types.MappedInterface{}})
+			EmbeddingsKey.Set(fetched, cachedEmbeddings)
+			return fetched
 		},
 	}
 }
@@ -66,14 +79,14 @@ func SaveAndLoadEmbeddingsToDB() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "saveAndLoadEmbeddingsToDB",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
-			embeddings, ok := t.Data["embeddings"].(*types.Embeddings)
-			if !ok {
-				panic("Loading embeddings went wrong")
+			embeddings, err := EmbeddingsKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
 			}
 			for _, vector := range embeddings.Vectors {
 				err := t.TG.AddEmbeddingDocument(t.C, vector.ID, vector.Values, vector.Metadata)
 				if err != nil {
-					panic(err)
+					return t.Fail(err)
 				}
 			}
 			// Store in local vector db (if default tzapconnector)
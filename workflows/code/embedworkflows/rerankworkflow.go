@@ -0,0 +1,123 @@
+package embedworkflows
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+)
+
+// rerankChunkLength is how many characters of each chunk the model sees.
+const rerankChunkLength = 1500
+
+const rerankInstruction = `You rank search results. The user gives a query and numbered chunks of files found for it.
+Score how relevant each chunk is to the query, from 0 for unrelated to 100 for exactly what the query asks for.
+Score every chunk.`
+
+type rerankAnswer struct {
+	Scores []chunkScore `json:"scores" description:"one score per chunk"`
+}
+
+type chunkScore struct {
+	Chunk int `json:"chunk" description:"number of the chunk"`
+	Score int `json:"score" description:"relevance to the query from 0 to 100"`
+}
+
+// RerankWorkflow asks the RerankModel of the configuration to score the relevance of the SearchResultsKey of t to
+// query, in one call for all of them, and keeps the k best by score, all of them for k below 0. Each kept result holds
+// its score in RerankScore; results the model left unscored follow the scored ones in their order.
+// Like the search workflows it sets SearchResultsKey, and keeps the QueryResultKey of t.
+func RerankWorkflow(query string, k int) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
+		Name: "rerankWorkflow",
+		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
+			searchResults, err := SearchResultsKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
+			}
+			results := searchResults.Results
+			scores := map[int]int{}
+			if len(results) > 0 {
+				answer, err := requestRerankScores(t, query, results)
+				if err != nil {
+					return t.Fail(err)
+				}
+				for _, s := range answer.Scores {
+					scores[s.Chunk-1] = s.Score
+				}
+			}
+
+			order := make([]int, len(results))
+			for i := range order {
+				order[i] = i
+			}
+			sort.SliceStable(order, func(a, b int) bool {
+				scoreA, scoredA := scores[order[a]]
+				scoreB, scoredB := scores[order[b]]
+				if scoredA != scoredB {
+					return scoredA
+				}
+				return scoreA > scoreB
+			})
+			if k > -1 && len(order) > k {
+				order = order[:k]
+			}
+			reranked := types.SearchResults{}
+			for _, i := range order {
+				result := results[i]
+				if score, ok := scores[i]; ok {
+					result.RerankScore = &score
+				}
+				reranked.Results = append(reranked.Results, result)
+			}
+
+			rerankedT := t.AddTzap(&tzap.Tzap{Name: "rerankedResults", Data: types.MappedInterface{}})
+			if query, ok := QueryResultKey.Lookup(t); ok {
+				QueryResultKey.Set(rerankedT, query)
+			}
+			SearchResultsKey.Set(rerankedT, reranked)
+			return rerankedT
+		},
+	}
+}
+
+func requestRerankScores(t *tzap.Tzap, query string, results []types.SearchResult) (rerankAnswer, error) {
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Query: %s\n", query)
+	for i, result := range results {
+		chunk := result.Vector.Metadata.SplitPart
+		if len(chunk) > rerankChunkLength {
+			chunk = chunk[:rerankChunkLength]
+		}
+		fmt.Fprintf(&prompt, "\nChunk %d, %s:%d\n%s\n", i+1, result.Vector.Metadata.Filename, result.Vector.Metadata.LineStart, chunk)
+	}
+
+	scoring := t.CopyConnection().
+		AddContextChange(func(ctx context.Context) context.Context {
+			conf := config.FromContext(ctx)
+			if conf.RerankModel != "" {
+				conf.OpenAIModel = conf.RerankModel
+			}
+			return config.NewContext(ctx, conf)
+		}).
+		AddUserMessage(prompt.String())
+	answer, _, err := tzap.RequestJSON(scoring, tzap.JSONOptions[rerankAnswer]{
+		Instruction: rerankInstruction,
+		Validate: func(answer rerankAnswer) error {
+			for _, s := range answer.Scores {
+				if s.Chunk < 1 || s.Chunk > len(results) {
+					return fmt.Errorf("chunk %d does not exist, chunks are numbered 1 to %d", s.Chunk, len(results))
+				}
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return rerankAnswer{}, fmt.Errorf("rerank: %w", err)
+	}
+	return answer, nil
+}
@@ -0,0 +1,80 @@
+package embedworkflows_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+	"github.com/tzapio/tzap/workflows/code/embedworkflows"
+)
+
+// scoringTG answers every chat with answer and records the model and prompt it was asked with.
+type scoringTG struct {
+	types.TGenerator
+	answer string
+	model  string
+	prompt string
+}
+
+func (tg *scoringTG) GenerateChat(ctx context.Context, messages []types.Message, stream bool) (string, error) {
+	tg.model = config.FromContext(ctx).OpenAIModel
+	for _, message := range messages {
+		tg.prompt += message.Content + "\n"
+	}
+	return tg.answer, nil
+}
+
+func (tg *scoringTG) CountTokens(ctx context.Context, content string) (int, error) {
+	return len(content) / 4, nil
+}
+
+func chunk(id, filename string) types.SearchResult {
+	return types.SearchResult{Vector: types.Vector{ID: id, Metadata: types.Metadata{Filename: filename, SplitPart: "content of " + id}}}
+}
+
+func TestRerankWorkflow_givenScores_expectTopKByScore(t *testing.T) {
+	tg := &scoringTG{answer: `{"scores": [{"chunk": 1, "score": 20}, {"chunk": 2, "score": 90}, {"chunk": 3, "score": 55}]}`}
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{OpenAIModel: "gpt-4", RerankModel: "gpt-3.5-turbo"}
+	})
+	searched := tz.AddTzap(&tzap.Tzap{Name: "searchResults", Data: types.MappedInterface{}})
+	embedworkflows.QueryResultKey.Set(searched, types.QueryRequest{TopK: 2})
+	embedworkflows.SearchResultsKey.Set(searched, types.SearchResults{Results: []types.SearchResult{
+		chunk("a", "a.go"), chunk("b", "b.go"), chunk("c", "c.go"), chunk("d", "d.go"),
+	}})
+
+	reranked := searched.ApplyWorkflow(embedworkflows.RerankWorkflow("where is b", 2))
+	if err := reranked.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if tg.model != "gpt-3.5-turbo" {
+		t.Errorf("expected the rerank model to score, got %s", tg.model)
+	}
+	if !strings.Contains(tg.prompt, "Chunk 4, d.go:0\ncontent of d") {
+		t.Errorf("expected every chunk in the prompt, got %s", tg.prompt)
+	}
+	results := embedworkflows.SearchResultsKey.Must(reranked).Results
+	if len(results) != 2 || results[0].Vector.ID != "b" || results[1].Vector.ID != "c" {
+		t.Fatalf("expected b and c, got %+v", results)
+	}
+	if results[0].RerankScore == nil || *results[0].RerankScore != 90 {
+		t.Errorf("expected the score of b, got %v", results[0].RerankScore)
+	}
+	if query := embedworkflows.QueryResultKey.Must(reranked); query.TopK != 2 {
+		t.Errorf("expected the query to be kept, got %+v", query)
+	}
+}
+
+func TestRerankWorkflow_givenNoSearchResults_expectError(t *testing.T) {
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return &scoringTG{}, config.Configuration{}
+	}).WithErrorMode()
+
+	reranked := tz.ApplyWorkflow(embedworkflows.RerankWorkflow("where is b", 2))
+	if reranked.Err() == nil {
+		t.Error("expected an error for a tzap without search results")
+	}
+}
@@ -1,59 +1,79 @@
 package embedworkflows
 
 import (
+	"errors"
+	"sort"
+
 	"github.com/tzapio/tzap/internal/logging/tl"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
 )
 
 // k is amount of embeddings to be included.
-// When using inspiration files, embeddings are likely to be duplicated and as such are filtered out. n is used to increase how many embeddings are fetched but are trimmed to only contain top K after filtering.
-func SearchFilesWorkflow(query types.QueryRequest, excludeFiles []string, k int, n int) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
+// Files to leave out, such as inspiration files already in the thread, go in the Filter of the query, which is applied before ranking.
+// n is used to increase how many embeddings are fetched, which are trimmed to the top k after ranking. Diversity
+// picks the top k from them, see Configuration.Diversity.
+// A query with several embeddings, such as expanded queries, searches with each and merges the results.
+func SearchFilesWorkflow(query types.QueryRequest, k int, n int) types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap]{
 		Name: "searchFilesWorkflow",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
 			tl.Logger.Println("searchFilesWorkflow")
 			if len(query.Queries) == 0 {
-				panic("empty embeddings")
-			}
-
-			if len(query.Queries) > 1 {
-				panic("should only return one embedding")
+				return t.Fail(errors.New("searchFilesWorkflow: query has no embeddings"))
 			}
-			embedding := query.Queries[0]
-			searchResults, err := t.TG.SearchWithEmbedding(t.C, embedding, n)
+			searchResults, err := searchQueries(t, query, candidates(k, n))
 			if err != nil {
-				panic(err)
+				return t.Fail(err)
 			}
-			filteredResults := filterSearchResults(searchResults, excludeFiles, k)
+			searchResults = diversify(t, searchResults, k)
 
-			data := types.MappedInterface{
-				"queryResult":   query,
-				"searchResults": filteredResults,
-			}
 			tl.Logger.Println("searchFilesWorkflow ending")
-			return t.AddTzap(&tzap.Tzap{Name: "searchResults", Data: data})
+			searched := t.AddTzap(&tzap.Tzap{Name: "searchResults", Data: types.MappedInterface{}})
+			QueryResultKey.Set(searched, query)
+			SearchResultsKey.Set(searched, searchResults)
+			return searched
 		},
 	}
 }
 
-func filterSearchResults(searchResults types.SearchResults, excludedFiles []string, k int) types.SearchResults {
-	filteredResults := []types.SearchResult{}
-	for _, result := range searchResults.Results {
-		fileName := result.Vector.Metadata.Filename
-		isExcluded := false
-		for _, excludedFile := range excludedFiles {
-			if fileName == excludedFile {
-				isExcluded = true
-				break
-			}
-		}
-		if !isExcluded {
-			filteredResults = append(filteredResults, result)
+// searchQueries searches the embedding index with each embedding of query for k results, and merges the results
+// into one ranking in which each chunk has the highest similarity it has with any of the embeddings.
+func searchQueries(t *tzap.Tzap, query types.QueryRequest, k int) (types.SearchResults, error) {
+	if len(query.Queries) == 1 {
+		return t.TG.SearchWithEmbedding(t.C, query.Queries[0], k)
+	}
+	var rankings []types.SearchResults
+	for _, embedding := range query.Queries {
+		results, err := t.TG.SearchWithEmbedding(t.C, embedding, k)
+		if err != nil {
+			return types.SearchResults{}, err
 		}
-		if len(filteredResults) >= k && k > -1 {
-			break
+		rankings = append(rankings, results)
+	}
+	return mergeRankings(rankings, k), nil
+}
+
+// mergeRankings merges rankings into the k results most similar to any of their queries, all of them for k below 0.
+func mergeRankings(rankings []types.SearchResults, k int) types.SearchResults {
+	byID := map[string]int{}
+	merged := types.SearchResults{}
+	for _, ranking := range rankings {
+		for _, result := range ranking.Results {
+			i, ok := byID[result.Vector.ID]
+			if !ok {
+				byID[result.Vector.ID] = len(merged.Results)
+				merged.Results = append(merged.Results, result)
+			} else if result.Similarity > merged.Results[i].Similarity {
+				merged.Results[i] = result
+			}
 		}
 	}
-	return types.SearchResults{Results: filteredResults}
+	sort.SliceStable(merged.Results, func(i, j int) bool {
+		return merged.Results[i].Similarity > merged.Results[j].Similarity
+	})
+	if k > -1 && len(merged.Results) > k {
+		merged.Results = merged.Results[:k]
+	}
+	return merged
 }
@@ -0,0 +1,89 @@
+package embedworkflows_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/types"
+	"github.com/tzapio/tzap/pkg/tzap"
+	"github.com/tzapio/tzap/workflows/code/embedworkflows"
+)
+
+// rankingTG returns the ranking of rankings at the index given by the first value of the query.
+type rankingTG struct {
+	types.TGenerator
+	rankings []types.SearchResults
+}
+
+func (tg *rankingTG) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
+	return tg.rankings[int(embedding.Values[0])], nil
+}
+
+// countingTG records the k of the searches and returns that many results.
+type countingTG struct {
+	types.TGenerator
+	ks []int
+}
+
+func (tg *countingTG) SearchWithEmbedding(ctx context.Context, embedding types.QueryFilter, k int) (types.SearchResults, error) {
+	tg.ks = append(tg.ks, k)
+	results := types.SearchResults{}
+	for i := 0; i < k; i++ {
+		results.Results = append(results.Results, scored(string(rune('a'+i)), 1-float32(i)/10))
+	}
+	return results, nil
+}
+
+func scored(id string, similarity float32) types.SearchResult {
+	return types.SearchResult{Vector: types.Vector{ID: id, Metadata: types.Metadata{Filename: id + ".go"}}, Similarity: similarity}
+}
+
+func TestSearchFilesWorkflow_givenSeveralQueries_expectMergedResults(t *testing.T) {
+	tg := &rankingTG{rankings: []types.SearchResults{
+		{Results: []types.SearchResult{scored("a", 0.8), scored("b", 0.7)}},
+		{Results: []types.SearchResult{scored("c", 0.9), scored("a", 0.85)}},
+	}}
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{}
+	})
+	query := types.QueryRequest{Queries: []types.QueryFilter{{Values: []float32{0}}, {Values: []float32{1}}}}
+
+	searched := tz.ApplyWorkflow(embedworkflows.SearchFilesWorkflow(query, 2, 2))
+	results := embedworkflows.SearchResultsKey.Must(searched).Results
+	if len(results) != 2 || results[0].Vector.ID != "c" || results[1].Vector.ID != "a" {
+		t.Fatalf("expected c and a, got %+v", results)
+	}
+	if results[1].Similarity != 0.85 {
+		t.Errorf("expected the best similarity of a, got %f", results[1].Similarity)
+	}
+}
+
+func TestSearchFilesWorkflow_givenN_expectNFetchedAndKKept(t *testing.T) {
+	tg := &countingTG{}
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return tg, config.Configuration{}
+	})
+	query := types.QueryRequest{Queries: []types.QueryFilter{{Values: []float32{0}}}}
+
+	searched := tz.ApplyWorkflow(embedworkflows.SearchFilesWorkflow(query, 2, 5))
+	if len(tg.ks) != 1 || tg.ks[0] != 5 {
+		t.Errorf("expected one search for 5 results, got %v", tg.ks)
+	}
+	results := embedworkflows.SearchResultsKey.Must(searched).Results
+	if len(results) != 2 || results[0].Vector.ID != "a" || results[1].Vector.ID != "b" {
+		t.Errorf("expected the top 2 results a and b, got %+v", results)
+	}
+}
+
+func TestSearchFilesWorkflow_givenNoEmbeddings_expectError(t *testing.T) {
+	tz := tzap.NewWithConnector(func() (types.TGenerator, config.Configuration) {
+		return &countingTG{}, config.Configuration{}
+	}).WithErrorMode()
+
+	searched := tz.ApplyWorkflow(embedworkflows.SearchFilesWorkflow(types.QueryRequest{}, 2, 2))
+	if searched.Err() == nil || !strings.Contains(searched.Err().Error(), "no embeddings") {
+		t.Errorf("expected an error for a query without embeddings, got %v", searched.Err())
+	}
+}
@@ -17,8 +17,8 @@ func InspirationWorkflow(inspirationFiles []string) types.NamedWorkflow[*tzap.Tz
 				LoadFiles(inspirationFiles).
 				Reduce(func(t *tzap.Tzap, child *tzap.Tzap) *tzap.Tzap {
 					return t.AddSystemMessage(
-						"####file: "+child.Data["filepath"].(string),
-						child.Data["content"].(string))
+						"####file: "+tzap.FilepathKey.Must(child),
+						tzap.ContentKey.Must(child))
 				})
 		},
 	}
@@ -8,6 +8,9 @@ import (
 	"github.com/tzapio/tzap/pkg/tzap"
 )
 
+// GitDiffKey holds the staged diff read by GitDiff.
+var GitDiffKey = tzap.Key[string]("git-diff")
+
 func GitDiff() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	workflowF := func(t *tzap.Tzap) *tzap.ErrorTzap {
 		diff := exec.Command("git", "diff",
@@ -25,7 +28,7 @@ func GitDiff() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 			return t.ErrorTzap(fmt.Errorf("could not get diff: %v", err))
 		}
 
-		t.Data["git-diff"] = string(out)
+		GitDiffKey.Set(t, string(out))
 		return t.ErrorTzap(nil)
 	}
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
@@ -38,7 +41,10 @@ func ValidateDiff() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
 		Name: "ValidateDiff",
 		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
-			diff := t.Data["git-diff"].(string)
+			diff, err := GitDiffKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
 
 			if diff == "" {
 				return t.ErrorTzap(fmt.Errorf("diff is empty. Stage files to continue"))
@@ -52,7 +58,10 @@ func GitCommit() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
 		Name: "GitCommit",
 		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
-			content := t.Data["content"].(string)
+			content, err := tzap.ContentKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
 
 			cmd := exec.Command("git", "commit", "-m", content)
 			if err := cmd.Run(); err != nil {
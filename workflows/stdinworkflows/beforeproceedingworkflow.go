@@ -75,7 +75,11 @@ func BeforeCompletionWorkflow() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 					HandleError(func(et *tzap.ErrorTzap) error {
 						return et.Err
 					}).RequestChatCompletion()
-				outContent = jt.Data["content"].(string)
+				content, err := tzap.ContentKey.Get(jt)
+				if err != nil {
+					panic(err)
+				}
+				outContent = content
 			})
 			return t.AddTzap(&tzap.Tzap{
 				Name: "BeforeCompletionWorkflow",
@@ -97,8 +101,11 @@ func BeforeProceedingWorkflow() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 			if config.AutoMode {
 				return t
 			}
-			priorContent := t.Data["content"].(string)
-			t.Data["content"] = BeforeProceeding(priorContent)
+			priorContent, err := tzap.ContentKey.Get(t)
+			if err != nil {
+				return t.Fail(err)
+			}
+			tzap.ContentKey.Set(t, BeforeProceeding(priorContent))
 			return t
 		},
 	}
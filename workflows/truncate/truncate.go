@@ -4,13 +4,20 @@ import (
 	"fmt"
 
 	"github.com/tzapio/tzap/pkg/config"
+	"github.com/tzapio/tzap/pkg/models"
 	"github.com/tzapio/tzap/pkg/types"
 	"github.com/tzapio/tzap/pkg/tzap"
+	"github.com/tzapio/tzap/workflows/code/git"
 )
 
-const (
-	maxTokensForGPT4    = 8000
-	maxTokensForDefault = 4000
+// defaultContextSize is used for models missing from the models registry.
+const defaultContextSize = 4000
+
+// Data keys set and read by the truncate workflows.
+var (
+	ContextSizeKey   = tzap.Key[int]("contextSize")
+	HeaderTokensKey  = tzap.Key[int]("headerTokens")
+	ContentTokensKey = tzap.Key[int]("contentTokens")
 )
 
 func SetContextSize() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
@@ -18,13 +25,7 @@ func SetContextSize() types.NamedWorkflow[*tzap.Tzap, *tzap.Tzap] {
 		Name: "setContextSize",
 		Workflow: func(t *tzap.Tzap) *tzap.Tzap {
 			settings := config.FromContext(t.C)
-			var contextSize int
-			if settings.OpenAIModel == "gpt4" {
-				contextSize = maxTokensForGPT4
-			} else {
-				contextSize = maxTokensForDefault
-			}
-			t.Data["contextSize"] = contextSize
+			ContextSizeKey.Set(t, models.ContextWindow(settings.OpenAIModel, defaultContextSize))
 
 			return t
 		}}
@@ -34,7 +35,10 @@ func CountTokens() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
 		Name: "countTokens",
 		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
-			diff := t.Data["git-diff"].(string)
+			diff, err := git.GitDiffKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
 			headerCount, err := t.CountTokens(t.Parent.InitialSystemContent)
 			if err != nil {
 				return t.ErrorTzap(fmt.Errorf("could not count tokens: %v", err))
@@ -43,8 +47,8 @@ func CountTokens() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 			if err != nil {
 				return t.ErrorTzap(fmt.Errorf("could not count tokens: %v", err))
 			}
-			t.Data["headerTokens"] = headerCount
-			t.Data["contentTokens"] = contentTokens
+			HeaderTokensKey.Set(t, headerCount)
+			ContentTokensKey.Set(t, contentTokens)
 
 			return t.ErrorTzap(nil)
 		}}
@@ -54,20 +58,32 @@ func TruncateTokens() types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap] {
 	return types.NamedWorkflow[*tzap.Tzap, *tzap.ErrorTzap]{
 		Name: "truncateTokens",
 		Workflow: func(t *tzap.Tzap) *tzap.ErrorTzap {
-			contextSize := t.Data["contextSize"].(int)
-			headerTokens := t.Data["headerTokens"].(int)
-			contentTokens := t.Data["contentTokens"].(int)
+			contextSize, err := ContextSizeKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
+			headerTokens, err := HeaderTokensKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
+			contentTokens, err := ContentTokensKey.Get(t)
+			if err != nil {
+				return t.ErrorTzap(err)
+			}
 
 			max := contextSize - headerTokens - 1500
 			if contentTokens >= max {
 				offsetStart := 0
 				offsetEnd := 0 + max
-				diff := t.Data["git-diff"].(string)
+				diff, err := git.GitDiffKey.Get(t)
+				if err != nil {
+					return t.ErrorTzap(err)
+				}
 				truncatedDiff, _, err := t.OffsetTokens(diff, offsetStart, offsetEnd)
 				if err != nil {
 					return t.ErrorTzap(fmt.Errorf("could not offset tokens: %v", err))
 				}
-				t.Data["git-diff"] = truncatedDiff
+				git.GitDiffKey.Set(t, truncatedDiff)
 			}
 
 			return t.ErrorTzap(nil)